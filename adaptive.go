@@ -0,0 +1,144 @@
+package promise2
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ConcurrencyController điều chỉnh số task chạy đồng thời hiệu dụng của pool
+// dựa trên latency và lỗi quan sát được (tối đa bằng số workers)
+type ConcurrencyController interface {
+	// Observe nhận kết quả một task vừa chạy xong và limit hiện tại, trả về limit mới
+	Observe(latency time.Duration, err error, limit int) int
+}
+
+// WithConcurrencyController bật điều chỉnh concurrency tự động cho pool
+// Pool bắt đầu với limit bằng số workers, controller có thể giảm/tăng trong [1, workers]
+func WithConcurrencyController(c ConcurrencyController) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.controller = c
+	}
+}
+
+// AIMDController tăng limit cộng dồn khi task nhanh và thành công,
+// giảm theo cấp số nhân khi task lỗi hoặc chậm hơn LatencyThreshold
+type AIMDController struct {
+	// LatencyThreshold là latency tối đa được coi là khỏe mạnh (0 = bỏ qua latency)
+	LatencyThreshold time.Duration
+	// Increase là số slot cộng thêm sau mỗi task khỏe mạnh (mặc định 1)
+	Increase int
+	// Backoff là hệ số nhân khi quá tải (mặc định 0.5)
+	Backoff float64
+}
+
+// Observe implement ConcurrencyController
+func (c *AIMDController) Observe(latency time.Duration, err error, limit int) int {
+	overloaded := err != nil || (c.LatencyThreshold > 0 && latency > c.LatencyThreshold)
+	if overloaded {
+		backoff := c.Backoff
+		if backoff <= 0 || backoff >= 1 {
+			backoff = 0.5
+		}
+		return int(float64(limit) * backoff)
+	}
+
+	increase := c.Increase
+	if increase <= 0 {
+		increase = 1
+	}
+	return limit + increase
+}
+
+// GradientController điều chỉnh limit theo tỉ lệ giữa latency tốt nhất
+// và latency trung bình gần đây: latency tăng thì limit giảm tương ứng
+type GradientController struct {
+	// Smoothing là hệ số EWMA cho latency gần đây (mặc định 0.2)
+	Smoothing float64
+
+	mu         sync.Mutex
+	minLatency time.Duration
+	avgLatency float64
+}
+
+// Observe implement ConcurrencyController
+func (c *GradientController) Observe(latency time.Duration, err error, limit int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return limit / 2
+	}
+
+	smoothing := c.Smoothing
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	if c.minLatency == 0 || latency < c.minLatency {
+		c.minLatency = latency
+	}
+	if c.avgLatency == 0 {
+		c.avgLatency = float64(latency)
+	} else {
+		c.avgLatency = smoothing*float64(latency) + (1-smoothing)*c.avgLatency
+	}
+	if c.avgLatency == 0 {
+		return limit + 1
+	}
+
+	gradient := math.Max(0.5, math.Min(1, float64(c.minLatency)/c.avgLatency))
+	return int(float64(limit)*gradient + math.Sqrt(float64(limit)))
+}
+
+// dynamicLimiter là semaphore với limit thay đổi được lúc chạy
+type dynamicLimiter struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int
+	max   int
+	inUse int
+}
+
+// newDynamicLimiter tạo limiter với limit ban đầu bằng max
+func newDynamicLimiter(max int) *dynamicLimiter {
+	l := &dynamicLimiter{limit: max, max: max}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire chờ tới khi số slot đang dùng nhỏ hơn limit
+func (l *dynamicLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.inUse >= l.limit {
+		l.cond.Wait()
+	}
+	l.inUse++
+}
+
+// release trả slot
+func (l *dynamicLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inUse--
+	l.cond.Signal()
+}
+
+// setLimit đặt limit mới trong khoảng [1, max]
+func (l *dynamicLimiter) setLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = max(1, min(limit, l.max))
+	l.cond.Broadcast()
+}
+
+// current trả về limit hiện tại
+func (l *dynamicLimiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit
+}
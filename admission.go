@@ -0,0 +1,59 @@
+package promise2
+
+import "context"
+
+// TaskInfo mô tả một task đang được submit, dùng bởi hook của WithAdmission
+type TaskInfo struct {
+	// Method là hàm submit được gọi: "Submit", "SubmitCtx", "SubmitClass", ...
+	Method string
+	// Ctx là ctx của SubmitCtx (context.Background với các hàm submit khác)
+	Ctx context.Context
+	// Submitter và Priority lấy từ ctx (WithSubmitter, WithPriority)
+	Submitter string
+	Priority  int
+	// Label là label đặt bằng WithLabel khi submit
+	Label string
+	// Name là tên task của SubmitTask, Key là key của SubmitAffinity hoặc class của SubmitClass
+	Name string
+	Key  string
+}
+
+// WithAdmission gọi admission trước khi task được đưa vào queue; lỗi trả về reject
+// Promise ngay với chính lỗi đó. Dùng cho quota, kiểm tra quyền, giới hạn theo tenant...
+// Khác với WithLoadShedder, admission áp dụng cả cho task của WithReserved
+func WithAdmission(admission func(TaskInfo) error) PoolOption {
+	return func(c *poolConfig) {
+		c.admission = admission
+	}
+}
+
+// admit kiểm tra task mới có được nhận vào pool hay không: admission rồi load shedder
+func (p *WorkerPool[T]) admit(info TaskInfo) error {
+	if err := p.checkAdmission(info); err != nil {
+		return err
+	}
+	if p.shedder != nil && p.shedder(p.Stats()) {
+		return ErrShedded
+	}
+	return nil
+}
+
+// checkAdmission gọi hook của WithAdmission nếu có
+func (p *WorkerPool[T]) checkAdmission(info TaskInfo) error {
+	if p.admission == nil {
+		return nil
+	}
+	if info.Ctx == nil {
+		info.Ctx = context.Background()
+	}
+	return p.admission(info)
+}
+
+// taskInfo tạo TaskInfo cho task submit không kèm ctx
+func taskInfo(method string, cfg *taskConfig) TaskInfo {
+	info := TaskInfo{Method: method}
+	if cfg != nil {
+		info.Label = cfg.label
+	}
+	return info
+}
@@ -0,0 +1,99 @@
+package promise2
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// maxCreationFrames là số frame tối đa được ghi lại khi tạo Promise ở debug mode
+const maxCreationFrames = 16
+
+// packageDir là thư mục chứa source của package, dùng để bỏ các frame nội bộ khỏi stack
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// PromiseFrame là một bước trong ancestry của Promise
+type PromiseFrame struct {
+	ID        uint64
+	Op        string
+	Label     string
+	State     Status
+	CreatedAt time.Time
+	// Stack là các frame (func file:line) của code ứng dụng đã tạo Promise
+	Stack []string
+}
+
+// String mô tả frame trên một dòng kèm vị trí tạo Promise
+func (f PromiseFrame) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#%d %s", f.ID, f.Op)
+	if f.Label != "" {
+		fmt.Fprintf(&sb, " %q", f.Label)
+	}
+	fmt.Fprintf(&sb, " %s", f.State)
+	if len(f.Stack) > 0 {
+		fmt.Fprintf(&sb, " created at %s", f.Stack[0])
+	}
+	return sb.String()
+}
+
+// Ancestry trả về các bước từ Promise gốc tới Promise này (theo Promise cha đầu tiên),
+// mỗi bước kèm stack nơi nó được tạo. Trả về nil nếu Promise được tạo khi debug tắt
+func (p *Promise[T]) Ancestry() []PromiseFrame {
+	if p.node == nil {
+		return nil
+	}
+	return p.node.ancestry()
+}
+
+// ancestry trả về PromiseFrame của các node từ gốc tới n
+func (n *debugNode) ancestry() []PromiseFrame {
+	chain := n.chain()
+	frames := make([]PromiseFrame, len(chain))
+	for i, node := range chain {
+		s := node.snapshot()
+		frames[i] = PromiseFrame{
+			ID:        s.id,
+			Op:        s.op,
+			Label:     s.label,
+			State:     s.state,
+			CreatedAt: s.created,
+			Stack:     formatStack(node.pcs),
+		}
+	}
+	return frames
+}
+
+// captureStack ghi lại program counters của goroutine đang tạo Promise
+func captureStack() []uintptr {
+	pcs := make([]uintptr, maxCreationFrames)
+	n := runtime.Callers(4, pcs)
+	return pcs[:n]
+}
+
+// formatStack chuyển program counters thành các frame của code ứng dụng,
+// bỏ qua frame nội bộ của package và runtime
+func formatStack(pcs []uintptr) []string {
+	if len(pcs) == 0 {
+		return nil
+	}
+
+	var stack []string
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		internal := filepath.Dir(frame.File) == packageDir && !strings.HasSuffix(frame.File, "_test.go")
+		if !internal && !strings.HasPrefix(frame.Function, "runtime.") && !strings.HasPrefix(frame.Function, "testing.") {
+			stack = append(stack, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
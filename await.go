@@ -0,0 +1,83 @@
+package promise2
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// AwaitAll chờ mọi promise fulfill và trả về các giá trị theo thứ tự, giống
+// All(ctx, ps...).Await(ctx) nhưng không tạo goroutine hay Promise trung gian:
+// mỗi promise chỉ đăng ký một callback khi settle, caller chờ trên một channel
+// Dùng cho hot path; lỗi đầu tiên (theo thời gian settle) được trả về ngay, bọc index và label
+// Nếu ctx kết thúc trước, lỗi là *AwaitError của promise chưa settle đầu tiên
+func AwaitAll[T any](ctx context.Context, ps []*Promise[T]) ([]T, error) {
+	start := time.Now()
+	remaining := atomic.Int64{}
+	remaining.Store(int64(len(ps)))
+	failed := atomic.Int64{}
+	failed.Store(-1)
+	wake := make(chan struct{}, 1)
+
+	signal := func() {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+	settled := func(idx int, p *Promise[T]) {
+		if p.result.Err != nil && failed.CompareAndSwap(-1, int64(idx)) {
+			signal()
+		}
+		if remaining.Add(-1) == 0 {
+			signal()
+		}
+	}
+	for i, p := range ps {
+		// Promise đã settle thì đếm luôn, không cần đăng ký callback
+		select {
+		case <-p.done:
+			settled(i, p)
+			continue
+		default:
+		}
+
+		idx, p := i, p
+		p.onSettle(func() { settled(idx, p) })
+	}
+
+	if len(ps) > 0 {
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			for i, p := range ps {
+				select {
+				case <-p.done:
+					continue
+				default:
+				}
+				return nil, indexedError(i, p, &AwaitError{
+					Label:   p.Label(),
+					Pending: time.Since(start),
+					Err:     ctx.Err(),
+				})
+			}
+		}
+	}
+
+	if idx := failed.Load(); idx >= 0 {
+		p := ps[idx]
+		_, err := p.consume()
+		return nil, indexedError(int(idx), p, err)
+	}
+
+	values := make([]T, len(ps))
+	for i, p := range ps {
+		val, err := p.consume()
+		if err != nil {
+			return nil, indexedError(i, p, err)
+		}
+		values[i] = val
+	}
+	return values, nil
+}
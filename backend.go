@@ -0,0 +1,78 @@
+package promise2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ExecBackend thực thi một TaskDescriptor và trả về kết quả đã encode (JSON)
+// Implement interface này để gửi task tới remote workers (NATS, Redis, ...)
+// trong khi phía gọi vẫn dùng SubmitTask/Await như khi chạy local
+type ExecBackend interface {
+	Exec(ctx context.Context, desc TaskDescriptor) ([]byte, error)
+}
+
+// WithExecBackend chuyển việc thực thi các task submit qua SubmitTask/Recover sang backend
+// Kết quả từ backend được decode JSON vào T; workers của pool vẫn giới hạn số task đồng thời
+// ctx truyền cho Exec bị hủy khi CancelAll được gọi hoặc Shutdown hết hạn, như ctx của SubmitCtx
+func WithExecBackend(backend ExecBackend) PoolOption {
+	return func(c *poolConfig) {
+		c.backend = backend
+	}
+}
+
+// HandlerBackend là ExecBackend chạy handlers đã đăng ký trong process hiện tại
+// Dùng ở phía remote worker để phục vụ descriptors, hoặc trong tests
+type HandlerBackend struct {
+	mu       sync.RWMutex
+	handlers map[string]func(ctx context.Context, payload []byte) (any, error)
+}
+
+// NewHandlerBackend tạo một HandlerBackend rỗng
+func NewHandlerBackend() *HandlerBackend {
+	return &HandlerBackend{
+		handlers: make(map[string]func(ctx context.Context, payload []byte) (any, error)),
+	}
+}
+
+// Handle đăng ký handler cho các task mang tên name
+func (b *HandlerBackend) Handle(name string, handler func(ctx context.Context, payload []byte) (any, error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[name] = handler
+}
+
+// Exec chạy handler tương ứng và encode kết quả thành JSON
+func (b *HandlerBackend) Exec(ctx context.Context, desc TaskDescriptor) ([]byte, error) {
+	b.mu.RLock()
+	handler, ok := b.handlers[desc.Name]
+	b.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTask, desc.Name)
+	}
+
+	val, err := handler(ctx, desc.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(val)
+}
+
+// backendHandler tạo handler gọi backend với ctx và decode kết quả vào T
+func backendHandler[T any](ctx context.Context, backend ExecBackend, name string) func(payload []byte) (T, error) {
+	return func(payload []byte) (T, error) {
+		var val T
+		out, err := backend.Exec(ctx, TaskDescriptor{Name: name, Payload: payload})
+		if err != nil {
+			return val, err
+		}
+		if err := json.Unmarshal(out, &val); err != nil {
+			return val, fmt.Errorf("decode result of task %q: %w", name, err)
+		}
+		return val, nil
+	}
+}
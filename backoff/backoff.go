@@ -0,0 +1,245 @@
+// Package backoff cung cấp các chính sách chờ giữa các lần retry
+// (constant, exponential, decorrelated jitter, giới hạn số lần, giới hạn delay,
+// retry budget) dùng được cả trong promise2 lẫn code ứng dụng.
+//
+//	policy := backoff.Capped(&backoff.Exponential{Initial: 100 * time.Millisecond}, 5*time.Second)
+//	for attempt := 1; ; attempt++ {
+//		err := call()
+//		if err == nil {
+//			break
+//		}
+//		delay, ok := policy.Next(attempt)
+//		if !ok {
+//			return err
+//		}
+//		time.Sleep(delay)
+//	}
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Policy quyết định thời gian chờ trước lần thử tiếp theo
+// attempt là số lần đã thất bại (bắt đầu từ 1); ok = false nghĩa là dừng retry
+type Policy interface {
+	Next(attempt int) (delay time.Duration, ok bool)
+}
+
+// PolicyFunc cho phép dùng function như Policy
+type PolicyFunc func(attempt int) (time.Duration, bool)
+
+// Next implement Policy
+func (f PolicyFunc) Next(attempt int) (time.Duration, bool) {
+	return f(attempt)
+}
+
+// Constant chờ một khoảng cố định giữa các lần thử
+type Constant struct {
+	Delay time.Duration
+}
+
+// Next implement Policy
+func (c Constant) Next(attempt int) (time.Duration, bool) {
+	return c.Delay, true
+}
+
+// Exponential tăng delay theo cấp số nhân: Initial * Multiplier^(attempt-1)
+// Jitter (0..1) là tỉ lệ delay được random hóa để tránh thundering herd
+type Exponential struct {
+	Initial    time.Duration
+	Multiplier float64
+	Max        time.Duration
+	Jitter     float64
+}
+
+// Next implement Policy
+func (e *Exponential) Next(attempt int) (time.Duration, bool) {
+	if attempt < 1 {
+		attempt = 1
+	}
+	multiplier := e.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+
+	delay := float64(e.Initial) * math.Pow(multiplier, float64(attempt-1))
+	if e.Max > 0 && delay > float64(e.Max) {
+		delay = float64(e.Max)
+	}
+	if e.Jitter > 0 {
+		jitter := math.Min(e.Jitter, 1)
+		delay = delay*(1-jitter) + delay*jitter*randFloat()
+	}
+	return time.Duration(delay), true
+}
+
+// DecorrelatedJitter là thuật toán "decorrelated jitter" của AWS:
+// delay = random(Base, prev*3), giới hạn bởi Max
+// Giữ trạng thái giữa các lần gọi nên mỗi chuỗi retry cần một instance riêng (xem Fresh);
+// promise2 tự gọi Fresh cho mỗi task và mỗi lần Retry
+type DecorrelatedJitter struct {
+	Base time.Duration
+	Max  time.Duration
+
+	mu   sync.Mutex
+	prev time.Duration
+}
+
+// Next implement Policy
+func (d *DecorrelatedJitter) Next(attempt int) (time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if attempt <= 1 || d.prev < d.Base {
+		d.prev = d.Base
+	}
+
+	upper := float64(d.prev) * 3
+	delay := time.Duration(float64(d.Base) + (upper-float64(d.Base))*randFloat())
+	if d.Max > 0 && delay > d.Max {
+		delay = d.Max
+	}
+	d.prev = delay
+	return delay, true
+}
+
+// Fresh implement Stateful: trả về bản mới với Base/Max như d và chưa có trạng thái
+func (d *DecorrelatedJitter) Fresh() Policy {
+	return &DecorrelatedJitter{Base: d.Base, Max: d.Max}
+}
+
+// Stateful là Policy giữ trạng thái giữa các lần gọi Next
+type Stateful interface {
+	Policy
+	// Fresh trả về Policy mới với trạng thái ban đầu, dùng cho một chuỗi retry riêng
+	Fresh() Policy
+}
+
+// Fresh trả về Policy dùng riêng cho một chuỗi retry: bản mới nếu p (hoặc policy được bọc
+// bởi Capped, Limit, WithBudget, RespectRetryAfter) giữ trạng thái, ngược lại chính p
+// Budget của WithBudget vẫn được dùng chung
+func Fresh(p Policy) Policy {
+	if s, ok := p.(Stateful); ok {
+		return s.Fresh()
+	}
+	return p
+}
+
+// Capped giới hạn delay của policy không vượt quá max
+func Capped(p Policy, max time.Duration) Policy {
+	return &capped{p: p, max: max}
+}
+
+type capped struct {
+	p   Policy
+	max time.Duration
+}
+
+func (c *capped) Next(attempt int) (time.Duration, bool) {
+	delay, ok := c.p.Next(attempt)
+	if delay > c.max {
+		delay = c.max
+	}
+	return delay, ok
+}
+
+func (c *capped) Fresh() Policy {
+	return &capped{p: Fresh(c.p), max: c.max}
+}
+
+// Limit dừng retry sau maxAttempts lần thất bại
+func Limit(p Policy, maxAttempts int) Policy {
+	return &limited{p: p, maxAttempts: maxAttempts}
+}
+
+type limited struct {
+	p           Policy
+	maxAttempts int
+}
+
+func (l *limited) Next(attempt int) (time.Duration, bool) {
+	if attempt >= l.maxAttempts {
+		return 0, false
+	}
+	return l.p.Next(attempt)
+}
+
+func (l *limited) Fresh() Policy {
+	return &limited{p: Fresh(l.p), maxAttempts: l.maxAttempts}
+}
+
+// Budget là retry budget dùng chung giữa nhiều chuỗi retry (token bucket):
+// tối đa Burst lần retry liên tiếp, hồi lại PerSecond lần retry mỗi giây
+// Khi budget cạn, retry dừng ngay để tránh retry storm lúc downstream đang lỗi
+type Budget struct {
+	mu        sync.Mutex
+	tokens    float64
+	burst     float64
+	perSecond float64
+	last      time.Time
+}
+
+// NewBudget tạo Budget với burst token ban đầu và tốc độ hồi perSecond
+func NewBudget(burst int, perSecond float64) *Budget {
+	return &Budget{
+		tokens:    float64(burst),
+		burst:     float64(burst),
+		perSecond: perSecond,
+		last:      time.Now(),
+	}
+}
+
+// Allow lấy một token retry, trả về false khi budget đã cạn
+func (b *Budget) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.perSecond)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// WithBudget chỉ cho phép retry khi budget còn token
+func WithBudget(p Policy, budget *Budget) Policy {
+	return &budgeted{p: p, budget: budget}
+}
+
+type budgeted struct {
+	p      Policy
+	budget *Budget
+}
+
+func (b *budgeted) Next(attempt int) (time.Duration, bool) {
+	delay, ok := b.p.Next(attempt)
+	if !ok || !b.budget.Allow() {
+		return 0, false
+	}
+	return delay, true
+}
+
+func (b *budgeted) Fresh() Policy {
+	return &budgeted{p: Fresh(b.p), budget: b.budget}
+}
+
+var (
+	randMu  sync.Mutex
+	randSrc = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// randFloat trả về số ngẫu nhiên trong [0, 1), an toàn khi gọi đồng thời
+func randFloat() float64 {
+	randMu.Lock()
+	defer randMu.Unlock()
+
+	return randSrc.Float64()
+}
@@ -0,0 +1,104 @@
+package backoff
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestExponential kiểm tra delay tăng theo cấp số nhân và bị giới hạn bởi Max
+func TestExponential(t *testing.T) {
+	policy := &Exponential{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, want := range expected {
+		delay, ok := policy.Next(i + 1)
+		if !ok || delay != want*time.Millisecond {
+			t.Fatalf("attempt %d: expected %s, got %s (ok=%v)", i+1, want*time.Millisecond, delay, ok)
+		}
+	}
+}
+
+// TestDecorrelatedJitter kiểm tra delay nằm trong [Base, Max]
+func TestDecorrelatedJitter(t *testing.T) {
+	policy := &DecorrelatedJitter{Base: 10 * time.Millisecond, Max: time.Second}
+	for attempt := 1; attempt <= 20; attempt++ {
+		delay, ok := policy.Next(attempt)
+		if !ok || delay < 10*time.Millisecond || delay > time.Second {
+			t.Fatalf("attempt %d: delay %s out of range", attempt, delay)
+		}
+	}
+}
+
+// TestLimitCappedBudget kiểm tra các policy bọc
+func TestLimitCappedBudget(t *testing.T) {
+	policy := Limit(Capped(Constant{Delay: time.Second}, 100*time.Millisecond), 3)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		delay, ok := policy.Next(attempt)
+		if !ok || delay != 100*time.Millisecond {
+			t.Fatalf("attempt %d: expected 100ms, got %s (ok=%v)", attempt, delay, ok)
+		}
+	}
+	if _, ok := policy.Next(3); ok {
+		t.Fatal("expected Limit to stop after 3 attempts")
+	}
+
+	budget := NewBudget(2, 0)
+	budgeted := WithBudget(Constant{}, budget)
+	for i := 0; i < 2; i++ {
+		if _, ok := budgeted.Next(1); !ok {
+			t.Fatalf("expected retry %d to be allowed", i)
+		}
+	}
+	if _, ok := budgeted.Next(1); ok {
+		t.Fatal("expected budget to be exhausted")
+	}
+}
+
+// TestRespectRetryAfter kiểm tra delay lấy từ Retry-After trong lỗi
+func TestRespectRetryAfter(t *testing.T) {
+	if d, ok := ParseRetryAfter("3"); !ok || d != 3*time.Second {
+		t.Fatalf("expected 3s, got %s (ok=%v)", d, ok)
+	}
+
+	policy := RespectRetryAfter(Limit(Constant{Delay: time.Millisecond}, 3), 2*time.Second)
+	err := fmt.Errorf("call api: %w", &RetryAfterError{Err: errors.New("429"), After: 5 * time.Second})
+
+	if d, ok := Delay(policy, 1, err); !ok || d != 2*time.Second {
+		t.Fatalf("expected capped Retry-After 2s, got %s (ok=%v)", d, ok)
+	}
+	if d, ok := Delay(policy, 1, errors.New("plain")); !ok || d != time.Millisecond {
+		t.Fatalf("expected policy delay 1ms, got %s (ok=%v)", d, ok)
+	}
+	if _, ok := Delay(policy, 3, err); ok {
+		t.Fatal("expected Limit to still stop retries")
+	}
+}
+
+// TestFresh kiểm tra Fresh tạo bản riêng cho policy có trạng thái, kể cả khi bị bọc
+func TestFresh(t *testing.T) {
+	shared := &DecorrelatedJitter{Base: time.Millisecond, Max: time.Hour}
+	for attempt := 1; attempt <= 10; attempt++ {
+		shared.Next(attempt)
+	}
+
+	policy := RespectRetryAfter(WithBudget(Limit(Capped(shared, time.Hour), 5), NewBudget(10, 0)), 0)
+	fresh := Fresh(policy)
+	if fresh == policy {
+		t.Fatal("expected Fresh to copy a wrapped stateful policy")
+	}
+	// Bản mới bắt đầu lại từ Base nên lần thử đầu nằm trong [Base, 3*Base]
+	if d, ok := fresh.Next(2); !ok || d > 3*time.Millisecond {
+		t.Fatalf("expected fresh state, got %s (ok=%v)", d, ok)
+	}
+	if _, ok := fresh.(ErrorDelayer); !ok {
+		t.Fatal("expected Fresh to keep the Retry-After hook")
+	}
+
+	constant := Limit(Constant{Delay: time.Millisecond}, 3)
+	if _, ok := Fresh(constant).Next(3); ok {
+		t.Fatal("expected Fresh to keep Limit")
+	}
+}
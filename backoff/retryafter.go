@@ -0,0 +1,102 @@
+package backoff
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryAfterError bọc lỗi từ downstream kèm thời gian chờ do server yêu cầu
+// (ví dụ header Retry-After của HTTP 429/503)
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter trả về thời gian chờ server yêu cầu
+func (e *RetryAfterError) RetryAfter() time.Duration {
+	return e.After
+}
+
+// ParseRetryAfter đọc giá trị header Retry-After (số giây hoặc HTTP-date)
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// DelayFromError tìm trong chain lỗi một lỗi có method RetryAfter() time.Duration
+// (như *RetryAfterError) và trả về thời gian chờ tương ứng
+func DelayFromError(err error) (time.Duration, bool) {
+	var ra interface{ RetryAfter() time.Duration }
+	if !errors.As(err, &ra) {
+		return 0, false
+	}
+	return ra.RetryAfter(), true
+}
+
+// ErrorDelayer là hook tùy chọn của Policy: quyết định delay dựa trên lỗi vừa xảy ra
+type ErrorDelayer interface {
+	DelayFromError(err error) (time.Duration, bool)
+}
+
+// Delay tính delay cho lần thử tiếp theo có xét đến lỗi
+// Policy vẫn quyết định có retry hay không; nếu Policy implement ErrorDelayer
+// và hook trả về ok thì delay của hook được dùng thay cho delay của Policy
+func Delay(p Policy, attempt int, err error) (time.Duration, bool) {
+	delay, ok := p.Next(attempt)
+	if !ok {
+		return 0, false
+	}
+	if d, isDelayer := p.(ErrorDelayer); isDelayer && err != nil {
+		if hinted, found := d.DelayFromError(err); found {
+			return hinted, true
+		}
+	}
+	return delay, true
+}
+
+// RespectRetryAfter bọc p để ưu tiên Retry-After trong lỗi (giới hạn bởi max, 0 = không giới hạn)
+// Nên đặt ngoài cùng khi kết hợp với Capped/Limit/WithBudget để hook không bị che
+func RespectRetryAfter(p Policy, max time.Duration) Policy {
+	return &retryAfterPolicy{Policy: p, max: max}
+}
+
+type retryAfterPolicy struct {
+	Policy
+	max time.Duration
+}
+
+func (r *retryAfterPolicy) Fresh() Policy {
+	return &retryAfterPolicy{Policy: Fresh(r.Policy), max: r.max}
+}
+
+func (r *retryAfterPolicy) DelayFromError(err error) (time.Duration, bool) {
+	delay, ok := DelayFromError(err)
+	if ok && r.max > 0 && delay > r.max {
+		delay = r.max
+	}
+	return delay, ok
+}
@@ -0,0 +1,512 @@
+package promise2
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// RecursiveMap duyệt cây bắt đầu từ root (thư mục, sơ đồ tổ chức, ...) và xử lý
+// từng node bằng fn với tối đa concurrency node chạy đồng thời
+// children trả về các node con của một node (được gọi sau khi fn của node đó xong)
+// Kết quả theo thứ tự node được phát hiện; lỗi đầu tiên cancel các node còn lại
+func RecursiveMap[T, U any](
+	ctx context.Context,
+	root T,
+	children func(T) []T,
+	fn func(ctx context.Context, node T) (U, error),
+	concurrency int,
+) *Promise[[]U] {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	q := NewPromiseWithExecutor[[]U](func(resolve func([]U), reject func(error)) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var (
+			mu      sync.Mutex
+			results []U
+			wg      sync.WaitGroup
+			errOnce sync.Once
+			runErr  error
+			slots   = make(chan struct{}, concurrency)
+		)
+
+		fail := func(err error) {
+			errOnce.Do(func() {
+				runErr = err
+				cancel()
+			})
+		}
+
+		var visit func(node T, idx int)
+		visit = func(node T, idx int) {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				fail(ctx.Err())
+				return
+			}
+
+			val, err := fn(ctx, node)
+			var kids []T
+			if err == nil {
+				kids = children(node)
+			}
+			<-slots
+
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			mu.Lock()
+			results[idx] = val
+			base := len(results)
+			results = append(results, make([]U, len(kids))...)
+			mu.Unlock()
+
+			for i, kid := range kids {
+				wg.Add(1)
+				go visit(kid, base+i)
+			}
+		}
+
+		results = make([]U, 1)
+		wg.Add(1)
+		go visit(root, 0)
+		wg.Wait()
+
+		if runErr != nil {
+			reject(runErr)
+			return
+		}
+		resolve(results)
+	})
+	q.node.setOp("RecursiveMap")
+	return q
+}
+
+// GroupBy chia items thành các nhóm theo key và xử lý mỗi nhóm bằng fn,
+// tối đa concurrency nhóm chạy đồng thời (ví dụ batch theo tenant hoặc partition)
+// Thứ tự items trong mỗi nhóm giữ nguyên thứ tự trong items;
+// lỗi đầu tiên cancel các nhóm còn lại và được trả về kèm key của nhóm
+func GroupBy[T any, K comparable, U any](
+	ctx context.Context,
+	items []T,
+	key func(T) K,
+	fn func(ctx context.Context, key K, group []T) (U, error),
+	concurrency int,
+) *Promise[map[K]U] {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	q := NewPromiseWithExecutor[map[K]U](func(resolve func(map[K]U), reject func(error)) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		probe := newCombinatorProbe(ctx, "GroupBy")
+
+		groups := make(map[K][]T)
+		for _, item := range items {
+			k := key(item)
+			groups[k] = append(groups[k], item)
+		}
+
+		var (
+			mu      sync.Mutex
+			results = make(map[K]U, len(groups))
+			wg      sync.WaitGroup
+			errOnce sync.Once
+			runErr  error
+			slots   = make(chan struct{}, concurrency)
+		)
+
+		fail := func(err error) {
+			errOnce.Do(func() {
+				runErr = err
+				cancel()
+			})
+		}
+
+		for k, group := range groups {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				fail(ctx.Err())
+			}
+			if ctx.Err() != nil {
+				break
+			}
+
+			wg.Add(1)
+			go func(k K, group []T) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				probe.started()
+				val, err := fn(ctx, k, group)
+				probe.settled(err)
+				if err != nil {
+					fail(fmt.Errorf("group %v: %w", k, err))
+					return
+				}
+
+				mu.Lock()
+				results[k] = val
+				mu.Unlock()
+			}(k, group)
+		}
+		wg.Wait()
+		probe.finish()
+
+		if runErr != nil {
+			reject(runErr)
+			return
+		}
+		resolve(results)
+	})
+	q.node.setOp("GroupBy")
+	return q
+}
+
+// ProcessFiles tìm các file khớp glob (hỗ trợ "**" cho nhiều cấp thư mục)
+// và xử lý từng file bằng fn trên pool; số file mở đồng thời bị giới hạn bởi số workers
+// Kết quả theo thứ tự path đã sắp xếp
+func ProcessFiles[T any](
+	ctx context.Context,
+	pool *WorkerPool[T],
+	glob string,
+	fn func(ctx context.Context, path string) (T, error),
+) *Promise[[]T] {
+	paths, err := globFiles(glob)
+	if err != nil {
+		return newSettledPromise(Result[[]T]{Err: err})
+	}
+
+	promises := make([]*Promise[T], len(paths))
+	for i, file := range paths {
+		file := file
+		promises[i] = pool.Submit(func() (T, error) {
+			if err := ctx.Err(); err != nil {
+				var zero T
+				return zero, err
+			}
+			return fn(ctx, file)
+		})
+	}
+	return All(ctx, promises...)
+}
+
+// globFiles trả về các file (không gồm thư mục) khớp pattern, đã sắp xếp
+func globFiles(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		files := matches[:0]
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				files = append(files, m)
+			}
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	// Thư mục gốc là các phần đầu của pattern không chứa ký tự glob
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	rootLen := 0
+	for rootLen < len(segments) && !strings.ContainsAny(segments[rootLen], "*?[") {
+		rootLen++
+	}
+	root := strings.Join(segments[:rootLen], "/")
+	if root == "" {
+		root = "."
+		if strings.HasPrefix(pattern, "/") {
+			root = "/"
+		}
+	}
+	rest := segments[rootLen:]
+
+	var files []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(filepath.FromSlash(root), name)
+		if err != nil {
+			return err
+		}
+		ok, err := matchSegments(rest, strings.Split(filepath.ToSlash(rel), "/"))
+		if err != nil {
+			return err
+		}
+		if ok {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// matchSegments so khớp từng phần của path với pattern, "**" khớp không hoặc nhiều thư mục
+func matchSegments(pattern, parts []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				ok, err := matchSegments(pattern[1:], parts[i:])
+				if ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(parts) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], parts[0])
+		if !ok || err != nil {
+			return false, err
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0, nil
+}
+
+// LineOptions cấu hình ProcessLines
+type LineOptions struct {
+	// Concurrency là số goroutine xử lý dòng đồng thời (mặc định 1)
+	Concurrency int
+	// MaxInFlight giới hạn số dòng đã đọc nhưng chưa được đưa vào kết quả (mặc định 2*Concurrency)
+	MaxInFlight int
+	// Ordered giữ kết quả theo thứ tự dòng; nếu tắt kết quả theo thứ tự xử lý xong
+	Ordered bool
+	// MaxLineSize là độ dài tối đa của một dòng (mặc định 1MB)
+	MaxLineSize int
+}
+
+// lineItem là một dòng cần xử lý hoặc kết quả của nó
+type lineItem[T any] struct {
+	idx  int
+	line string
+	val  T
+	err  error
+}
+
+// ProcessLines đọc r tuần tự theo dòng nhưng xử lý các dòng song song bằng fn
+// Số dòng đang xử lý bị giới hạn bởi MaxInFlight nên bộ nhớ không tăng theo kích thước input
+// Lỗi đầu tiên (đọc hoặc xử lý) cancel phần còn lại và reject Promise
+func ProcessLines[T any](
+	ctx context.Context,
+	r io.Reader,
+	fn func(ctx context.Context, line string) (T, error),
+	opts LineOptions,
+) *Promise[[]T] {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxInFlight < opts.Concurrency {
+		opts.MaxInFlight = 2 * opts.Concurrency
+	}
+	if opts.MaxLineSize <= 0 {
+		opts.MaxLineSize = 1024 * 1024
+	}
+
+	q := NewPromiseWithExecutor[[]T](func(resolve func([]T), reject func(error)) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		slots := make(chan struct{}, opts.MaxInFlight)
+		lines := make(chan lineItem[T], opts.Concurrency)
+		results := make(chan lineItem[T], opts.Concurrency)
+		var readErr error
+
+		// Reader: đọc tuần tự, chờ slot trống trước khi đưa dòng cho workers
+		go func() {
+			defer close(lines)
+
+			scanner := bufio.NewScanner(r)
+			scanner.Buffer(make([]byte, 0, 64*1024), opts.MaxLineSize)
+			for idx := 0; scanner.Scan(); idx++ {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				lines <- lineItem[T]{idx: idx, line: scanner.Text()}
+			}
+			readErr = scanner.Err()
+		}()
+
+		var wg sync.WaitGroup
+		wg.Add(opts.Concurrency)
+		for i := 0; i < opts.Concurrency; i++ {
+			go func() {
+				defer wg.Done()
+				for item := range lines {
+					if ctx.Err() != nil {
+						continue
+					}
+					item.val, item.err = fn(ctx, item.line)
+					results <- item
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		var out []T
+		var firstErr error
+		pending := make(map[int]T)
+		next := 0
+		for item := range results {
+			if firstErr != nil {
+				<-slots
+				continue
+			}
+			if item.err != nil {
+				firstErr = fmt.Errorf("line %d: %w", item.idx+1, item.err)
+				cancel()
+				<-slots
+				continue
+			}
+
+			if !opts.Ordered {
+				out = append(out, item.val)
+				<-slots
+				continue
+			}
+
+			pending[item.idx] = item.val
+			for {
+				val, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				out = append(out, val)
+				next++
+				<-slots
+			}
+		}
+
+		switch {
+		case firstErr != nil:
+			reject(firstErr)
+		case readErr != nil:
+			reject(readErr)
+		case ctx.Err() != nil:
+			reject(ctx.Err())
+		default:
+			resolve(out)
+		}
+	})
+	q.node.setOp("ProcessLines")
+	return q
+}
+
+// Produce nối producer với các consumer chạy trên pool qua một buffer có giới hạn
+// emit chặn khi số item đang chờ/đang xử lý đạt 2*số workers (backpressure)
+// và trả về lỗi khi pipeline đã lỗi hoặc ctx kết thúc để producer dừng lại
+// Promise trả về số item đã được consume thành công, hoặc lỗi đầu tiên của producer/consumer
+func Produce[T any](
+	ctx context.Context,
+	producer func(ctx context.Context, emit func(T) error) error,
+	pool *WorkerPool[struct{}],
+	consumer func(ctx context.Context, item T) error,
+) *Promise[int] {
+	q := NewPromiseWithExecutor[int](func(resolve func(int), reject func(error)) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var (
+			wg       sync.WaitGroup
+			errOnce  sync.Once
+			firstErr error
+			consumed atomic.Int64
+			slots    = make(chan struct{}, 2*pool.Stats().NumWorkers)
+		)
+
+		fail := func(err error) {
+			errOnce.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}
+
+		emit := func(item T) error {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			task := pool.Submit(func() (struct{}, error) {
+				if err := ctx.Err(); err != nil {
+					return struct{}{}, err
+				}
+				return struct{}{}, consumer(ctx, item)
+			})
+
+			// Giải phóng slot khi task settle, kể cả khi task không chạy được (pool đóng, bị shed)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				if _, err := task.await(context.Background()); err != nil {
+					fail(err)
+					return
+				}
+				consumed.Add(1)
+			}()
+			return nil
+		}
+
+		if err := producer(ctx, emit); err != nil {
+			fail(err)
+		}
+		wg.Wait()
+
+		if firstErr != nil {
+			reject(firstErr)
+			return
+		}
+		if err := ctx.Err(); err != nil {
+			reject(err)
+			return
+		}
+		resolve(int(consumed.Load()))
+	})
+	q.node.setOp("Produce")
+	return q
+}
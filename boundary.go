@@ -0,0 +1,36 @@
+package promise2
+
+import "context"
+
+// Boundary đánh dấu điểm chặn lỗi trong chain: Promise trả về luôn fulfill với Result của p,
+// nên các bước Then/Map phía sau vẫn chạy và tự quyết định xử lý lỗi thay vì bị short-circuit
+// Dùng Rethrow để đưa lỗi trở lại chain khi đã xử lý xong
+func Boundary[T any](p *Promise[T]) *Promise[Result[T]] {
+	q := NewPromiseWithExecutor[Result[T]](func(resolve func(Result[T]), reject func(error)) {
+		go func() {
+			val, err := p.await(context.Background())
+			resolve(Result[T]{Value: val, Err: err})
+		}()
+	})
+	q.node.link("Boundary", p.node)
+	return q
+}
+
+// Rethrow ngược lại với Boundary: reject với lỗi của Result, ngược lại fulfill với Value
+func Rethrow[T any](p *Promise[Result[T]]) *Promise[T] {
+	q := NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
+		go func() {
+			result, err := p.await(context.Background())
+			if err == nil {
+				err = result.Err
+			}
+			if err != nil {
+				reject(err)
+				return
+			}
+			resolve(result.Value)
+		}()
+	})
+	q.node.link("Rethrow", p.node)
+	return q
+}
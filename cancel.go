@@ -0,0 +1,158 @@
+package promise2
+
+import "context"
+
+// CancelQueued reject mọi task đang chờ (chưa bắt đầu chạy) với ErrTaskCancelled
+// Task đang chạy không bị ảnh hưởng; task submit sau đó vẫn chạy bình thường
+// Trả về số task được reject ngay (task còn trong channel hoặc priorityQueue);
+// task đang chờ vào queue cũng bị reject nhưng không được tính
+func (p *WorkerPool[T]) CancelQueued() int {
+	p.cancelQueuedScope()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return 0
+	}
+
+	cancelled := p.drainCancelled(p.taskQueue)
+	for _, q := range p.affinity {
+		cancelled += p.drainCancelled(q)
+	}
+	for _, q := range p.reserved {
+		cancelled += p.drainCancelled(q)
+	}
+	if p.prio != nil {
+		for {
+			item, ok := p.prio.pop()
+			if !ok {
+				break
+			}
+			p.prio.release(item, false)
+			p.reject(item.task, p.cancelledErr())
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+// CancelAll giống CancelQueued và hủy thêm ctx của các task SubmitCtx đang chạy
+// Task không dùng ctx (Submit) vẫn chạy đến khi xong
+func (p *WorkerPool[T]) CancelAll() int {
+	p.cancelRunScope()
+	return p.CancelQueued()
+}
+
+// cancelQueuedScope hủy mọi task đã submit nhưng chưa chạy, kể cả task còn chờ vào queue
+// Task trong channel bị reject khi worker lấy ra
+func (p *WorkerPool[T]) cancelQueuedScope() {
+	p.cancelMu.Lock()
+	defer p.cancelMu.Unlock()
+
+	close(p.queuedCh)
+	p.queuedCh = make(chan struct{})
+}
+
+// cancelRunScope hủy ctx của các task SubmitCtx đã submit
+func (p *WorkerPool[T]) cancelRunScope() {
+	p.cancelMu.Lock()
+	defer p.cancelMu.Unlock()
+
+	p.runCancel()
+	p.runCtx, p.runCancel = context.WithCancel(context.Background())
+}
+
+// queuedScope trả về channel được đóng ở lần CancelQueued tiếp theo
+func (p *WorkerPool[T]) queuedScope() <-chan struct{} {
+	p.cancelMu.Lock()
+	defer p.cancelMu.Unlock()
+
+	return p.queuedCh
+}
+
+// runScope trả về context bị hủy ở lần CancelAll tiếp theo
+func (p *WorkerPool[T]) runScope() context.Context {
+	p.cancelMu.Lock()
+	defer p.cancelMu.Unlock()
+
+	return p.runCtx
+}
+
+// rejectCancelled reject task nếu nó đã bị hủy trong lúc chờ (CancelQueued, CancelGroup),
+// trả về true nếu đã reject
+func (p *WorkerPool[T]) rejectCancelled(t task[T]) bool {
+	if t.groupCancelled() {
+		p.reject(t, p.cancelledErr())
+		return true
+	}
+
+	select {
+	case <-t.cancelled:
+		p.reject(t, p.cancelledErr())
+		return true
+	default:
+		return false
+	}
+}
+
+// cancelledErr là lỗi của task chờ bị hủy: ErrPoolShutdown nếu do Shutdown hết hạn
+func (p *WorkerPool[T]) cancelledErr() error {
+	if p.expired.Load() {
+		return ErrPoolShutdown
+	}
+	return ErrTaskCancelled
+}
+
+// drainCancelled lấy hết task đang nằm trong queue và reject chúng
+func (p *WorkerPool[T]) drainCancelled(queue chan task[T]) int {
+	cancelled := 0
+	for {
+		select {
+		case t := <-queue:
+			p.reject(t, p.cancelledErr())
+			cancelled++
+		default:
+			return cancelled
+		}
+	}
+}
+
+// CancelablePromise là Promise có thể hủy công việc bên dưới bằng Cancel
+type CancelablePromise[T any] struct {
+	*Promise[T]
+}
+
+// NewCancelablePromise giống NewPromiseCtx và trả về thêm Cancel để hủy công việc
+func NewCancelablePromise[T any](ctx context.Context, fn func(ctx context.Context) (T, error), opts ...Option) *CancelablePromise[T] {
+	return &CancelablePromise[T]{Promise: newCtxPromise("NewCancelablePromise", ctx, fn, opts)}
+}
+
+// Cancel hủy ctx của task và reject Promise với context.Canceled ngay, không chờ fn trả về
+// Không làm gì nếu Promise đã settle
+func (c *CancelablePromise[T]) Cancel() {
+	c.abort()
+	c.settle(Result[T]{Err: context.Canceled})
+}
+
+// NewPromiseCtx giống NewPromise nhưng fn nhận ctx để theo dõi cancellation và deadline
+// ctx của fn bị hủy khi ctx cha kết thúc hoặc khi Promise settle trước fn (WithTimeout),
+// nên goroutine không bị bỏ lại chạy sau khi không còn ai chờ kết quả
+func NewPromiseCtx[T any](ctx context.Context, fn func(ctx context.Context) (T, error), opts ...Option) *Promise[T] {
+	return newCtxPromise("NewPromiseCtx", ctx, fn, opts)
+}
+
+// newCtxPromise chạy fn với ctx con của ctx, ctx con bị hủy khi Promise settle hoặc khi gọi abort
+func newCtxPromise[T any](op string, ctx context.Context, fn func(ctx context.Context) (T, error), opts []Option) *Promise[T] {
+	cfg := newPromiseConfig(opts)
+	ctx, cancel := context.WithCancel(ctx)
+	p := newPromise[T]()
+	p.node.setOp(op)
+	applyPromiseConfig(p, cfg)
+	p.abort = cancel
+	p.onSettle(cancel)
+
+	go runPromise(p, cfg, func() (T, error) { return fn(ctx) })
+
+	return p
+}
@@ -0,0 +1,63 @@
+package promise2
+
+import "context"
+
+// Cell giữ một giá trị dùng chung giữa nhiều Promise, mọi Read/Update được chạy lần lượt
+// nên callback của chain không cần tự giữ mutex
+//
+//	hits := promise2.NewCell(map[string]int{})
+//	p.Then(func(page string) error {
+//		_, err := hits.Update(ctx, func(m map[string]int) (map[string]int, error) {
+//			m[page]++
+//			return m, nil
+//		}).Await(ctx)
+//		return err
+//	})
+//
+// Giá trị kiểu tham chiếu (map, slice, pointer) trả về bởi Read vẫn dùng chung với Cell,
+// chỉ nên sửa trong Update
+type Cell[T any] struct {
+	lock  chan struct{}
+	value T
+}
+
+// NewCell tạo Cell với giá trị ban đầu initial
+func NewCell[T any](initial T) *Cell[T] {
+	return &Cell[T]{lock: make(chan struct{}, 1), value: initial}
+}
+
+// Read trả về Promise fulfill với giá trị hiện tại, sau các Update đang chạy
+// Reject với lỗi của ctx nếu ctx kết thúc trước khi tới lượt
+func (c *Cell[T]) Read(ctx context.Context) *Promise[T] {
+	return c.run(ctx, "Cell.Read", func(val T) (T, error) {
+		return val, nil
+	})
+}
+
+// Update gọi fn với giá trị hiện tại và lưu giá trị fn trả về, Promise fulfill với giá trị mới
+// fn lỗi (hoặc panic) thì giá trị giữ nguyên và Promise reject với lỗi đó
+func (c *Cell[T]) Update(ctx context.Context, fn func(T) (T, error)) *Promise[T] {
+	return c.run(ctx, "Cell.Update", func(val T) (T, error) {
+		next, err := fn(val)
+		if err != nil {
+			return val, err
+		}
+		c.value = next
+		return next, nil
+	})
+}
+
+// run chạy fn với giá trị hiện tại khi giữ lock của c
+func (c *Cell[T]) run(ctx context.Context, op string, fn func(val T) (T, error)) *Promise[T] {
+	return newCtxPromise(op, ctx, func(ctx context.Context) (T, error) {
+		select {
+		case c.lock <- struct{}{}:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		defer func() { <-c.lock }()
+
+		return fn(c.value)
+	}, nil)
+}
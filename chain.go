@@ -0,0 +1,144 @@
+package promise2
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Chain là chuỗi bước nối tiếp với kiểu giá trị khác nhau giữa các bước
+// (Go chưa có method type parameter nên Then/Map chỉ giữ cùng kiểu)
+// Kiểu giữa hai bước liền nhau được kiểm tra lúc build, input và output lúc RunChain
+//
+//	c := promise2.NewChain(
+//		promise2.Step("fetch", fetchUser),      // int -> User
+//		promise2.Step("orders", loadOrders),    // User -> []Order
+//		promise2.Step("total", sumOrders),      // []Order -> float64
+//	)
+//	total, err := promise2.RunChain[float64](ctx, c, userID).Await(ctx)
+type Chain struct {
+	steps []ChainStep
+	err   error
+}
+
+// ChainStep là một bước đã xóa kiểu của Chain, tạo bằng Step
+type ChainStep struct {
+	name string
+	in   reflect.Type
+	out  reflect.Type
+	run  func(ctx context.Context, in any) (any, error)
+}
+
+// ChainError xảy ra khi kiểu giữa các bước không khớp hoặc một bước lỗi
+type ChainError struct {
+	// Index là vị trí bước (bắt đầu từ 0), Step là tên của nó
+	Index int
+	Step  string
+	Err   error
+}
+
+// Error trả về message kèm vị trí và tên bước
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("chain step %d %q: %v", e.Index, e.Step, e.Err)
+}
+
+// Unwrap trả về lỗi gốc
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// Step tạo bước name của Chain từ fn có kiểu
+func Step[In, Out any](name string, fn func(ctx context.Context, in In) (Out, error)) ChainStep {
+	return ChainStep{
+		name: name,
+		in:   reflect.TypeOf((*In)(nil)).Elem(),
+		out:  reflect.TypeOf((*Out)(nil)).Elem(),
+		run: func(ctx context.Context, in any) (any, error) {
+			typed, _ := in.(In)
+			return fn(ctx, typed)
+		},
+	}
+}
+
+// NewChain tạo Chain từ các bước
+func NewChain(steps ...ChainStep) *Chain {
+	return (&Chain{}).Then(steps...)
+}
+
+// Then nối thêm các bước vào cuối Chain
+// Lỗi kiểu đầu tiên được giữ lại và trả về bởi Err và RunChain
+func (c *Chain) Then(steps ...ChainStep) *Chain {
+	for _, step := range steps {
+		if c.err == nil && len(c.steps) > 0 {
+			prev := c.steps[len(c.steps)-1]
+			if !prev.out.AssignableTo(step.in) {
+				c.err = &ChainError{
+					Index: len(c.steps),
+					Step:  step.name,
+					Err:   fmt.Errorf("expects input %s, but step %q returns %s", step.in, prev.name, prev.out),
+				}
+			}
+		}
+		c.steps = append(c.steps, step)
+	}
+	return c
+}
+
+// Err trả về lỗi kiểu khi build Chain (nil nếu các bước khớp nhau)
+func (c *Chain) Err() error {
+	return c.err
+}
+
+// RunChain chạy lần lượt các bước của c với input, fulfill với output của bước cuối
+// Reject với *ChainError nếu Chain sai kiểu, input/Out không khớp, một bước lỗi
+// hoặc ctx kết thúc giữa hai bước; Chain rỗng trả về chính input
+func RunChain[Out any](ctx context.Context, c *Chain, input any) *Promise[Out] {
+	steps, buildErr := append([]ChainStep(nil), c.steps...), c.err
+
+	q := NewPromiseWithExecutor[Out](func(resolve func(Out), reject func(error)) {
+		if buildErr != nil {
+			reject(buildErr)
+			return
+		}
+
+		outType := reflect.TypeOf((*Out)(nil)).Elem()
+		if len(steps) > 0 {
+			if last := steps[len(steps)-1]; !last.out.AssignableTo(outType) {
+				reject(&ChainError{
+					Index: len(steps) - 1,
+					Step:  last.name,
+					Err:   fmt.Errorf("returns %s, not assignable to chain result %s", last.out, outType),
+				})
+				return
+			}
+		}
+
+		value := input
+		for i, step := range steps {
+			if err := ctx.Err(); err != nil {
+				reject(&ChainError{Index: i, Step: step.name, Err: err})
+				return
+			}
+			if !acceptsInput(step.in, value) {
+				reject(&ChainError{Index: i, Step: step.name, Err: fmt.Errorf("expects input %s, got %T", step.in, value)})
+				return
+			}
+
+			out, err := step.run(ctx, value)
+			if err != nil {
+				reject(&ChainError{Index: i, Step: step.name, Err: err})
+				return
+			}
+			value = out
+		}
+
+		result, ok := value.(Out)
+		if !ok && value != nil {
+			reject(fmt.Errorf("chain result %T is not %s", value, outType))
+			return
+		}
+		resolve(result)
+	})
+	q.node.setOp("Chain")
+	return q
+}
@@ -1,14 +1,19 @@
 package promise2
 
 import (
+	"container/heap"
 	"context"
+	"fmt"
+	"reflect"
 	"sync"
+	"time"
 )
 
 // All chờ tất cả promises hoàn thành
-// Nếu bất kỳ promise nào lỗi, trả về lỗi đó
+// Nếu bất kỳ promise nào lỗi, trả về lỗi đó kèm index và label của promise
+// (ví dụ `promise[7] "fetch user": ...`), errors.Is/As vẫn khớp lỗi gốc
 func All[T any](ctx context.Context, promises ...*Promise[T]) *Promise[[]T] {
-	return NewPromiseWithExecutor[[]T](func(resolve func([]T), reject func(error)) {
+	q := NewPromiseWithExecutor[[]T](func(resolve func([]T), reject func(error)) {
 		n := len(promises)
 		if n == 0 {
 			resolve([]T{})
@@ -20,16 +25,21 @@ func All[T any](ctx context.Context, promises ...*Promise[T]) *Promise[[]T] {
 		var errOnce sync.Once
 		var wg sync.WaitGroup
 
+		probe := newCombinatorProbe(ctx, "All")
+		progress := newProgressTracker(ctx, n)
 		wg.Add(n)
 
 		for i, promise := range promises {
+			probe.started()
 			go func(idx int, p *Promise[T]) {
 				defer wg.Done()
 
-				val, err := p.Await(ctx)
+				val, err := p.await(ctx)
+				probe.settled(err)
+				progress.add(1)
 				if err != nil {
 					errOnce.Do(func() {
-						reject(err)
+						reject(indexedError(idx, p, err))
 					})
 					return
 				}
@@ -42,14 +52,25 @@ func All[T any](ctx context.Context, promises ...*Promise[T]) *Promise[[]T] {
 
 		go func() {
 			wg.Wait()
+			probe.finish()
 			resolve(results)
 		}()
-	})
+	}, optionsFrom(ctx)...)
+	q.node.link("All", nodesOf(promises)...)
+	return q
+}
+
+// indexedError bọc lỗi của promise thứ idx kèm label (nếu có) để dễ tìm promise lỗi
+func indexedError[T any](idx int, p *Promise[T], err error) error {
+	if label := p.Label(); label != "" {
+		return fmt.Errorf("promise[%d] %q: %w", idx, label, err)
+	}
+	return fmt.Errorf("promise[%d]: %w", idx, err)
 }
 
 // Race trả về kết quả của promise hoàn thành đầu tiên
 func Race[T any](ctx context.Context, promises ...*Promise[T]) *Promise[T] {
-	return NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
+	q := NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
 		if len(promises) == 0 {
 			var zero T
 			resolve(zero)
@@ -60,7 +81,7 @@ func Race[T any](ctx context.Context, promises ...*Promise[T]) *Promise[T] {
 
 		for _, promise := range promises {
 			go func(p *Promise[T]) {
-				val, err := p.Await(ctx)
+				val, err := p.await(ctx)
 				once.Do(func() {
 					if err != nil {
 						reject(err)
@@ -70,13 +91,36 @@ func Race[T any](ctx context.Context, promises ...*Promise[T]) *Promise[T] {
 				})
 			}(promise)
 		}
+	}, optionsFrom(ctx)...)
+	q.node.link("Race", nodesOf(promises)...)
+	return q
+}
+
+// Until trả về Promise theo kết quả của p, hoặc reject với ErrSignalled nếu signal
+// phát (được đóng hoặc nhận giá trị) trước, ví dụ signal shutdown của server
+// p vẫn tiếp tục chạy, chỉ Promise trả về bị reject
+func Until[T any](p *Promise[T], signal <-chan struct{}) *Promise[T] {
+	q := NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
+		select {
+		case <-p.Done():
+			val, err := p.await(context.Background())
+			if err != nil {
+				reject(err)
+				return
+			}
+			resolve(val)
+		case <-signal:
+			reject(ErrSignalled)
+		}
 	})
+	q.node.link("Until", p.node)
+	return q
 }
 
 // AllSettled chờ tất cả promises settle (complete hoặc reject)
 // Trả về slice của PromiseStatus cho từng promise
 func AllSettled[T any](ctx context.Context, promises ...*Promise[T]) *Promise[[]PromiseStatus[T]] {
-	return NewPromiseWithExecutor[[]PromiseStatus[T]](func(resolve func([]PromiseStatus[T]), reject func(error)) {
+	q := NewPromiseWithExecutor[[]PromiseStatus[T]](func(resolve func([]PromiseStatus[T]), reject func(error)) {
 		n := len(promises)
 		if n == 0 {
 			resolve([]PromiseStatus[T]{})
@@ -87,13 +131,18 @@ func AllSettled[T any](ctx context.Context, promises ...*Promise[T]) *Promise[[]
 		var mu sync.Mutex
 		var wg sync.WaitGroup
 
+		probe := newCombinatorProbe(ctx, "AllSettled")
+		progress := newProgressTracker(ctx, n)
 		wg.Add(n)
 
 		for i, promise := range promises {
+			probe.started()
 			go func(idx int, p *Promise[T]) {
 				defer wg.Done()
 
-				val, err := p.Await(ctx)
+				val, err := p.await(ctx)
+				probe.settled(err)
+				progress.add(1)
 
 				mu.Lock()
 				if err != nil {
@@ -113,9 +162,255 @@ func AllSettled[T any](ctx context.Context, promises ...*Promise[T]) *Promise[[]
 
 		go func() {
 			wg.Wait()
+			probe.finish()
 			resolve(results)
 		}()
-	})
+	}, optionsFrom(ctx)...)
+	q.node.link("AllSettled", nodesOf(promises)...)
+	return q
+}
+
+// AllSettledTasks chạy các task với tối đa concurrency task đồng thời (<= 0 là không giới hạn)
+// và trả về PromiseStatus của từng task theo thứ tự input; Promise trả về không bao giờ reject
+// Task chỉ bắt đầu khi có slot; task chưa bắt đầu khi ctx kết thúc bị đánh dấu rejected
+// với ctx.Err(), task panic bị đánh dấu rejected với *PanicError
+func AllSettledTasks[T any](ctx context.Context, concurrency int, tasks ...func(ctx context.Context) (T, error)) *Promise[[]PromiseStatus[T]] {
+	q := NewPromiseWithExecutor[[]PromiseStatus[T]](func(resolve func([]PromiseStatus[T]), reject func(error)) {
+		n := len(tasks)
+		if concurrency <= 0 || concurrency > n {
+			concurrency = n
+		}
+
+		results := make([]PromiseStatus[T], n)
+		slots := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+
+		probe := newCombinatorProbe(ctx, "AllSettledTasks")
+		progress := newProgressTracker(ctx, n)
+
+		for i, task := range tasks {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if err := ctx.Err(); err != nil {
+				for j := i; j < n; j++ {
+					results[j] = PromiseStatus[T]{Status: StatusRejected, Err: err}
+				}
+				progress.add(n - i)
+				break
+			}
+
+			probe.started()
+			wg.Add(1)
+			go func(idx int, task func(ctx context.Context) (T, error)) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				result := recovered(RejectOnPanic(), func() (T, error) { return task(ctx) })
+				probe.settled(result.Err)
+
+				if result.Err != nil {
+					results[idx] = PromiseStatus[T]{Status: StatusRejected, Err: result.Err}
+				} else {
+					results[idx] = PromiseStatus[T]{Status: StatusFulfilled, Value: result.Value}
+				}
+				progress.add(1)
+			}(i, task)
+		}
+
+		wg.Wait()
+		probe.finish()
+		resolve(results)
+	}, optionsFrom(ctx)...)
+	q.node.setOp("AllSettledTasks")
+	return q
+}
+
+// AllMap giống All nhưng nhận map key → Promise và trả về map key → giá trị
+// Nếu bất kỳ promise nào lỗi, trả về lỗi đó kèm key của promise
+func AllMap[K comparable, T any](ctx context.Context, promises map[K]*Promise[T]) *Promise[map[K]T] {
+	q := NewPromiseWithExecutor[map[K]T](func(resolve func(map[K]T), reject func(error)) {
+		results := make(map[K]T, len(promises))
+		var mu sync.Mutex
+		var errOnce sync.Once
+		var wg sync.WaitGroup
+
+		wg.Add(len(promises))
+
+		for key, promise := range promises {
+			go func(key K, p *Promise[T]) {
+				defer wg.Done()
+
+				val, err := p.await(ctx)
+				if err != nil {
+					errOnce.Do(func() {
+						reject(fmt.Errorf("key %v: %w", key, err))
+					})
+					return
+				}
+
+				mu.Lock()
+				results[key] = val
+				mu.Unlock()
+			}(key, promise)
+		}
+
+		go func() {
+			wg.Wait()
+			resolve(results)
+		}()
+	}, optionsFrom(ctx)...)
+	q.node.link("AllMap", mapNodes(promises)...)
+	return q
+}
+
+// AllSettledMap giống AllSettled nhưng nhận map key → Promise
+// Trả về map key → PromiseStatus cho từng promise
+func AllSettledMap[K comparable, T any](ctx context.Context, promises map[K]*Promise[T]) *Promise[map[K]PromiseStatus[T]] {
+	q := NewPromiseWithExecutor[map[K]PromiseStatus[T]](func(resolve func(map[K]PromiseStatus[T]), reject func(error)) {
+		results := make(map[K]PromiseStatus[T], len(promises))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+
+		wg.Add(len(promises))
+
+		for key, promise := range promises {
+			go func(key K, p *Promise[T]) {
+				defer wg.Done()
+
+				val, err := p.await(ctx)
+				status := PromiseStatus[T]{Status: StatusFulfilled, Value: val}
+				if err != nil {
+					status = PromiseStatus[T]{Status: StatusRejected, Err: err}
+				}
+
+				mu.Lock()
+				results[key] = status
+				mu.Unlock()
+			}(key, promise)
+		}
+
+		go func() {
+			wg.Wait()
+			resolve(results)
+		}()
+	}, optionsFrom(ctx)...)
+	q.node.link("AllSettledMap", mapNodes(promises)...)
+	return q
+}
+
+// mapNodes trả về debug node của các promises trong map
+func mapNodes[K comparable, T any](promises map[K]*Promise[T]) []*debugNode {
+	list := make([]*Promise[T], 0, len(promises))
+	for _, p := range promises {
+		list = append(list, p)
+	}
+	return nodesOf(list)
+}
+
+// TopN nhận promises từ channel, chờ từng promise settle và giữ lại n kết quả tốt nhất
+// theo better (better(a, b) = true nghĩa là a xếp trên b), trả về theo thứ tự tốt nhất trước
+// Chỉ n kết quả được giữ trong bộ nhớ nên phù hợp với fan-out rất lớn;
+// kết thúc khi channel đóng và mọi promise đã settle, lỗi đầu tiên reject toàn bộ
+func TopN[T any](ctx context.Context, n int, better func(a, b T) bool, promises <-chan *Promise[T]) *Promise[[]T] {
+	q := NewPromiseWithExecutor[[]T](func(resolve func([]T), reject func(error)) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var (
+			mu      sync.Mutex
+			top     = &boundedHeap[T]{worse: func(a, b T) bool { return better(b, a) }}
+			wg      sync.WaitGroup
+			errOnce sync.Once
+			runErr  error
+		)
+
+		fail := func(err error) {
+			errOnce.Do(func() {
+				runErr = err
+				cancel()
+			})
+		}
+
+	receive:
+		for {
+			select {
+			case p, ok := <-promises:
+				if !ok {
+					break receive
+				}
+
+				wg.Add(1)
+				go func(p *Promise[T]) {
+					defer wg.Done()
+
+					val, err := p.await(ctx)
+					if err != nil {
+						fail(err)
+						return
+					}
+
+					mu.Lock()
+					top.offer(val, n)
+					mu.Unlock()
+				}(p)
+			case <-ctx.Done():
+				fail(ctx.Err())
+				break receive
+			}
+		}
+		wg.Wait()
+
+		if runErr != nil {
+			reject(runErr)
+			return
+		}
+		resolve(top.sorted())
+	}, optionsFrom(ctx)...)
+	q.node.setOp("TopN")
+	return q
+}
+
+// boundedHeap là min-heap theo worse: phần tử kém nhất nằm ở gốc để bị thay thế
+type boundedHeap[T any] struct {
+	items []T
+	worse func(a, b T) bool
+}
+
+func (h *boundedHeap[T]) Len() int           { return len(h.items) }
+func (h *boundedHeap[T]) Less(i, j int) bool { return h.worse(h.items[i], h.items[j]) }
+func (h *boundedHeap[T]) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *boundedHeap[T]) Push(x any)         { h.items = append(h.items, x.(T)) }
+
+func (h *boundedHeap[T]) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// offer thêm val nếu heap chưa đủ n phần tử hoặc val tốt hơn phần tử kém nhất
+func (h *boundedHeap[T]) offer(val T, n int) {
+	if n <= 0 {
+		return
+	}
+	if h.Len() < n {
+		heap.Push(h, val)
+		return
+	}
+	if h.worse(h.items[0], val) {
+		h.items[0] = val
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted lấy hết phần tử theo thứ tự tốt nhất trước
+func (h *boundedHeap[T]) sorted() []T {
+	out := make([]T, h.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(h).(T)
+	}
+	return out
 }
 
 // PromiseStatus chứa status và kết quả của một promise
@@ -129,6 +424,7 @@ type PromiseStatus[T any] struct {
 type Status string
 
 const (
+	StatusPending   Status = "pending"
 	StatusFulfilled Status = "fulfilled"
 	StatusRejected  Status = "rejected"
 )
@@ -142,7 +438,7 @@ type AnyResult[T any] struct {
 // Any trả về kết quả của promise thành công đầu tiên
 // Nếu tất cả promises reject, trả về AggregateError
 func Any[T any](ctx context.Context, promises ...*Promise[T]) *Promise[T] {
-	return NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
+	q := NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
 		n := len(promises)
 		if n == 0 {
 			reject(ErrAllPromisesRejected)
@@ -156,7 +452,7 @@ func Any[T any](ctx context.Context, promises ...*Promise[T]) *Promise[T] {
 
 		for _, promise := range promises {
 			go func(p *Promise[T]) {
-				val, err := p.Await(ctx)
+				val, err := p.await(ctx)
 				if err == nil {
 					once.Do(func() {
 						resolve(val)
@@ -164,6 +460,14 @@ func Any[T any](ctx context.Context, promises ...*Promise[T]) *Promise[T] {
 					return
 				}
 
+				// Lỗi vĩnh viễn (theo WithTransientErrors) reject ngay
+				if permanent(ctx, err) {
+					once.Do(func() {
+						reject(err)
+					})
+					return
+				}
+
 				mu.Lock()
 				errors = append(errors, err)
 				rejectedCount++
@@ -177,17 +481,53 @@ func Any[T any](ctx context.Context, promises ...*Promise[T]) *Promise[T] {
 				}
 			}(promise)
 		}
-	})
+	}, optionsFrom(ctx)...)
+	q.node.link("Any", nodesOf(promises)...)
+	return q
+}
+
+// Fallback thử lần lượt các nguồn, trả về kết quả của nguồn đầu tiên thành công
+// Với ctx từ WithTransientErrors, chỉ chuyển sang nguồn tiếp theo khi lỗi là tạm thời,
+// lỗi vĩnh viễn được trả về ngay; nếu mọi nguồn lỗi, trả về AggregateError
+func Fallback[T any](ctx context.Context, sources ...func(ctx context.Context) (T, error)) *Promise[T] {
+	q := NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
+		if len(sources) == 0 {
+			reject(ErrAllPromisesRejected)
+			return
+		}
+
+		errs := make([]error, 0, len(sources))
+		for i, source := range sources {
+			if err := ctx.Err(); err != nil {
+				reject(err)
+				return
+			}
+
+			val, err := source(ctx)
+			if err == nil {
+				resolve(val)
+				return
+			}
+			if permanent(ctx, err) {
+				reject(fmt.Errorf("source %d: %w", i, err))
+				return
+			}
+			errs = append(errs, fmt.Errorf("source %d: %w", i, err))
+		}
+		reject(NewAggregateError(errs))
+	}, optionsFrom(ctx)...)
+	q.node.setOp("Fallback")
+	return q
 }
 
 // Sequence thực thi promises theo thứ tự (từng cái một)
 func Sequence[T any](ctx context.Context, promises ...*Promise[T]) *Promise[[]T] {
-	return NewPromiseWithExecutor[[]T](func(resolve func([]T), reject func(error)) {
+	q := NewPromiseWithExecutor[[]T](func(resolve func([]T), reject func(error)) {
 		n := len(promises)
 		results := make([]T, n)
 
 		for i, promise := range promises {
-			val, err := promise.Await(ctx)
+			val, err := promise.await(ctx)
 			if err != nil {
 				reject(err)
 				return
@@ -196,7 +536,9 @@ func Sequence[T any](ctx context.Context, promises ...*Promise[T]) *Promise[[]T]
 		}
 
 		resolve(results)
-	})
+	}, optionsFrom(ctx)...)
+	q.node.link("Sequence", nodesOf(promises)...)
+	return q
 }
 
 // Pool chứa promises và chạy chúng với worker pool
@@ -207,3 +549,176 @@ func Pool[T any](ctx context.Context, pool *WorkerPool[T], tasks ...func() (T, e
 	}
 	return All(ctx, promises...)
 }
+
+// WaitAll chờ tất cả promises (có thể khác kiểu) settle thành công
+// Trả về lỗi ngay khi có promise bị reject hoặc ctx kết thúc; không quan tâm giá trị
+func WaitAll(ctx context.Context, promises ...AnyPromise) error {
+	remaining := append([]AnyPromise(nil), promises...)
+	for len(remaining) > 0 {
+		idx, err := waitFirst(ctx, remaining)
+		if err != nil {
+			return err
+		}
+
+		if _, err := remaining[idx].AwaitAny(ctx); err != nil {
+			return err
+		}
+		remaining = append(remaining[:idx], remaining[idx+1:]...)
+	}
+	return nil
+}
+
+// WaitAny chờ promise đầu tiên settle (thành công hoặc lỗi)
+// Trả về index của promise đó và lỗi của nó (nếu có)
+func WaitAny(ctx context.Context, promises ...AnyPromise) (int, error) {
+	if len(promises) == 0 {
+		return -1, ErrAllPromisesRejected
+	}
+
+	idx, err := waitFirst(ctx, promises)
+	if err != nil {
+		return -1, err
+	}
+
+	_, err = promises[idx].AwaitAny(ctx)
+	return idx, err
+}
+
+// SelectCase ghép một promise với callback nhận kết quả đúng kiểu của nó, dùng với Select
+type SelectCase struct {
+	promise AnyPromise
+	handle  func() error
+}
+
+// Case tạo SelectCase cho p; fn được gọi với kết quả của p khi p được Select chọn
+// Lỗi fn trả về trở thành lỗi của Select
+func Case[T any](p *Promise[T], fn func(val T, err error) error) SelectCase {
+	return SelectCase{
+		promise: p,
+		handle: func() error {
+			return fn(p.Await(context.Background()))
+		},
+	}
+}
+
+// Select chờ promise đầu tiên settle trong các cases (có thể khác kiểu), gọi callback
+// của case đó và trả về index của nó, tương tự select với channel
+// Không tạo goroutine cho mỗi promise; nếu ctx kết thúc trước, trả về -1 và ctx.Err()
+func Select(ctx context.Context, cases ...SelectCase) (int, error) {
+	if len(cases) == 0 {
+		<-ctx.Done()
+		return -1, ctx.Err()
+	}
+
+	promises := make([]AnyPromise, len(cases))
+	for i, c := range cases {
+		promises[i] = c.promise
+	}
+
+	idx, err := waitFirst(ctx, promises)
+	if err != nil {
+		return -1, err
+	}
+	return idx, cases[idx].handle()
+}
+
+// waitFirst chờ Done của promise đầu tiên bằng một lần reflect.Select,
+// không cần goroutine cho mỗi promise
+func waitFirst(ctx context.Context, promises []AnyPromise) (int, error) {
+	cases := make([]reflect.SelectCase, len(promises)+1)
+	for i, p := range promises {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(p.Done())}
+	}
+	cases[len(promises)] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+
+	chosen, _, _ := reflect.Select(cases)
+	if chosen == len(promises) {
+		return -1, ctx.Err()
+	}
+	return chosen, nil
+}
+
+// SequenceOptions cấu hình SequenceTasksWith
+type SequenceOptions struct {
+	// StepTimeout giới hạn thời gian của mỗi bước (0 = không giới hạn)
+	StepTimeout time.Duration
+	// ContinueOnError tiếp tục chạy các bước sau khi một bước lỗi
+	ContinueOnError bool
+}
+
+// SequenceTasks chạy các task lần lượt, task sau chỉ bắt đầu khi task trước thành công
+// Khác với Sequence, công việc chỉ bắt đầu khi tới lượt
+func SequenceTasks[T any](ctx context.Context, tasks ...func(context.Context) (T, error)) *Promise[[]T] {
+	steps := SequenceTasksWith(ctx, SequenceOptions{}, tasks...)
+	q := NewPromiseWithExecutor[[]T](func(resolve func([]T), reject func(error)) {
+		statuses, err := steps.await(ctx)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		results := make([]T, len(statuses))
+		for i, s := range statuses {
+			results[i] = s.Value
+		}
+		resolve(results)
+	}, optionsFrom(ctx)...)
+	q.node.link("SequenceTasks", steps.node)
+	return q
+}
+
+// SequenceTasksWith chạy các task lần lượt với timeout cho từng bước
+// và trả về PromiseStatus của mỗi bước đã chạy
+// Khi ContinueOnError tắt, Promise bị reject ngay tại bước lỗi đầu tiên
+func SequenceTasksWith[T any](ctx context.Context, opts SequenceOptions, tasks ...func(context.Context) (T, error)) *Promise[[]PromiseStatus[T]] {
+	q := NewPromiseWithExecutor[[]PromiseStatus[T]](func(resolve func([]PromiseStatus[T]), reject func(error)) {
+		statuses := make([]PromiseStatus[T], 0, len(tasks))
+
+		for i, task := range tasks {
+			if err := ctx.Err(); err != nil {
+				reject(err)
+				return
+			}
+
+			val, err := runStep(ctx, opts.StepTimeout, task)
+			if err != nil {
+				if !opts.ContinueOnError {
+					reject(fmt.Errorf("step %d: %w", i, err))
+					return
+				}
+				statuses = append(statuses, PromiseStatus[T]{Status: StatusRejected, Err: err})
+				continue
+			}
+			statuses = append(statuses, PromiseStatus[T]{Status: StatusFulfilled, Value: val})
+		}
+
+		resolve(statuses)
+	}, optionsFrom(ctx)...)
+	q.node.setOp("SequenceTasks")
+	return q
+}
+
+// runStep chạy một bước với timeout riêng, panic của task thành PanicError
+// Nếu task không tôn trọng ctx, bước vẫn kết thúc đúng hạn với context.DeadlineExceeded
+func runStep[T any](ctx context.Context, timeout time.Duration, task func(context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		r := recoverPanic(func() (T, error) { return task(ctx) })
+		return r.Value, r.Err
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan Result[T], 1)
+	go func() {
+		done <- recoverPanic(func() (T, error) { return task(stepCtx) })
+	}()
+
+	select {
+	case r := <-done:
+		return r.Value, r.Err
+	case <-stepCtx.Done():
+		var zero T
+		return zero, stepCtx.Err()
+	}
+}
@@ -0,0 +1,53 @@
+package promise2
+
+import "sync"
+
+// On trả về Promise settle cùng kết quả với p, các bước Then/Map/Catch/Finally nối
+// sau nó chạy trên workers của pool thay vì goroutine mới: concurrency của phần
+// continuation bị giới hạn và được tính trong Stats/metrics của pool
+// Mỗi bước chỉ được gửi vào pool khi Promise trước đó đã settle nên không giữ worker để chờ
+func (p *Promise[T]) On(pool *WorkerPool[T]) *Promise[T] {
+	q := newPromise[T]()
+	q.node.link("On", p.node)
+	q.runner = pool.runStep
+	p.onSettle(func() {
+		q.settle(p.result)
+	})
+	return q
+}
+
+// schedule chạy bước tiếp theo của chain: trên goroutine mới, hoặc sau khi p settle
+// trên pool của On, EventLoop của OnLoop hay SetDefaultLoop
+func (p *Promise[T]) schedule(step func()) {
+	runner := p.runner
+	if runner == nil {
+		if loop := defaultLoop.Load(); loop != nil {
+			runner = loop.Post
+		}
+	}
+	if runner == nil {
+		go step()
+		return
+	}
+	p.onSettle(func() {
+		runner(step)
+	})
+}
+
+// runStep chạy một bước chain của On trên worker của pool
+// Nếu pool từ chối task (đã đóng, bị shed), bước chạy trên goroutine riêng để chain vẫn settle
+func (p *WorkerPool[T]) runStep(step func()) {
+	var once sync.Once
+	run := func() { once.Do(step) }
+
+	t := p.Submit(func() (T, error) {
+		run()
+		var zero T
+		return zero, nil
+	})
+	t.onSettle(func() {
+		if t.result.Err != nil {
+			go run()
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package promise2
+
+import (
+	"context"
+	"time"
+)
+
+// expectedDurationCtx là key của context chứa thời gian chạy dự kiến của task
+type expectedDurationCtx struct{}
+
+// WithExpectedDuration gắn thời gian chạy dự kiến của task vào ctx
+// Hint này ghi đè giá trị mặc định của WithDeadlineWarnings cho task submit với ctx
+func WithExpectedDuration(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, expectedDurationCtx{}, d)
+}
+
+// ExpectedDurationFromContext trả về thời gian chạy dự kiến gắn bằng WithExpectedDuration
+func ExpectedDurationFromContext(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(expectedDurationCtx{}).(time.Duration)
+	return d, ok
+}
+
+// DeadlineWarning mô tả task bắt đầu chạy khi deadline còn lại của ctx
+// ngắn hơn thời gian chạy dự kiến, tức task gần như chắc chắn không kịp hoàn thành
+type DeadlineWarning struct {
+	Expected  time.Duration
+	Remaining time.Duration
+	Submitter string
+}
+
+// WithDeadlineWarnings gọi hook trước khi chạy task SubmitCtx có deadline còn lại
+// ngắn hơn thời gian dự kiến (expected, hoặc WithExpectedDuration trên ctx của task)
+// expected = 0 nghĩa là chỉ kiểm tra các task có hint riêng; task vẫn được chạy như bình thường
+func WithDeadlineWarnings(expected time.Duration, hook func(DeadlineWarning)) PoolOption {
+	return func(c *poolConfig) {
+		c.expectedDuration = expected
+		c.onDeadlineWarning = hook
+	}
+}
+
+// warnDeadline báo hook nếu deadline của task ngắn hơn thời gian chạy dự kiến
+func (p *WorkerPool[T]) warnDeadline(t task[T]) {
+	if p.onDeadlineWarning == nil || t.ctx == nil {
+		return
+	}
+
+	deadline, ok := t.ctx.Deadline()
+	if !ok {
+		return
+	}
+
+	expected := p.expectedDuration
+	if hint, ok := ExpectedDurationFromContext(t.ctx); ok {
+		expected = hint
+	}
+	if expected <= 0 {
+		return
+	}
+
+	if remaining := time.Until(deadline); remaining < expected {
+		p.onDeadlineWarning(DeadlineWarning{Expected: expected, Remaining: remaining, Submitter: t.tag})
+	}
+}
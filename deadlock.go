@@ -0,0 +1,71 @@
+package promise2
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DeadlockInfo mô tả một trường hợp nghi ngờ starvation deadlock:
+// Await chờ một task vẫn nằm trong queue của pool đang bận hết workers
+// và pool không hoàn thành thêm task nào trong suốt thời gian chờ
+type DeadlockInfo struct {
+	Label  string
+	Waited time.Duration
+	Stats  PoolStats
+}
+
+// WithDeadlockDetection bật phát hiện starvation deadlock cho các Promise của pool
+// Trường hợp điển hình: task đang chạy trên pool Await một task khác submit vào chính pool đó
+// Nếu onDeadlock là nil, Await trả về ErrPossibleDeadlock; ngược lại onDeadlock được gọi
+// (một lần cho mỗi lần Await) và Await tiếp tục chờ như bình thường
+func WithDeadlockDetection(timeout time.Duration, onDeadlock func(DeadlockInfo)) PoolOption {
+	return func(c *poolConfig) {
+		c.deadlockTimeout = timeout
+		c.onDeadlock = onDeadlock
+	}
+}
+
+// deadlockWatcher được gắn vào Promise do pool tạo khi bật phát hiện deadlock
+type deadlockWatcher interface {
+	watchAwait(ctx context.Context, done <-chan struct{}, queued *atomic.Bool, label string) error
+}
+
+// watchAwait chờ cùng Await và kiểm tra dấu hiệu deadlock mỗi chu kỳ timeout
+// Trả về nil khi Promise settle, ctx kết thúc hoặc đã báo qua hook
+func (p *WorkerPool[T]) watchAwait(ctx context.Context, done <-chan struct{}, queued *atomic.Bool, label string) error {
+	start := time.Now()
+	timer := time.NewTimer(p.deadlockTimeout)
+	defer timer.Stop()
+
+	completed := p.completed.Load()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		stalled := p.completed.Load() == completed
+		if queued.Load() && stalled && p.saturated() {
+			info := DeadlockInfo{Label: label, Waited: time.Since(start), Stats: p.Stats()}
+			if p.onDeadlock == nil {
+				return fmt.Errorf("%w: awaited task queued for %s while all %d workers are busy",
+					ErrPossibleDeadlock, roundDuration(info.Waited), info.Stats.NumWorkers)
+			}
+			p.onDeadlock(info)
+			return nil
+		}
+
+		completed = p.completed.Load()
+		timer.Reset(p.deadlockTimeout)
+	}
+}
+
+// saturated cho biết tất cả slot chạy task của pool đang bận
+func (p *WorkerPool[T]) saturated() bool {
+	return int(p.running.Load()) >= p.Stats().ConcurrencyLimit
+}
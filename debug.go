@@ -0,0 +1,255 @@
+package promise2
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// debugEnabled bật theo dõi cấu trúc chain cho các Promise tạo sau đó
+	debugEnabled atomic.Bool
+	debugNextID  atomic.Uint64
+)
+
+// EnableDebug bật/tắt debug tracking
+// Khi bật, mỗi Promise tạo ra sẽ ghi lại bước tạo ra nó (Then/Map/Catch/...),
+// Promise cha, thời điểm tạo và settle để DebugString/DumpChain hiển thị
+// Chỉ nên bật khi phát triển hoặc chẩn đoán vì có thêm chi phí mỗi Promise
+func EnableDebug(on bool) {
+	debugEnabled.Store(on)
+}
+
+// debugNode là thông tin debug của một Promise
+// Mọi method đều an toàn khi node là nil (debug tắt)
+type debugNode struct {
+	id      uint64
+	created time.Time
+	// pcs là stack nơi Promise được tạo (xem Ancestry)
+	pcs []uintptr
+
+	// kill reject Promise của node (xem StuckPromise.Kill), được gán khi tạo Promise
+	kill func(err error) bool
+
+	mu        sync.Mutex
+	op        string
+	label     string
+	parents   []*debugNode
+	state     Status
+	settledAt time.Time
+}
+
+// newDebugNode tạo node cho một Promise mới
+func newDebugNode(op string, created time.Time, kill func(err error) bool) *debugNode {
+	n := &debugNode{
+		id:      debugNextID.Add(1),
+		created: created,
+		pcs:     captureStack(),
+		kill:    kill,
+		op:      op,
+		state:   StatusPending,
+	}
+	pendingNodes.Store(n.id, n)
+	return n
+}
+
+// setOp đặt tên bước đã tạo ra Promise
+func (n *debugNode) setOp(op string) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.op = op
+}
+
+// setLabel ghi lại label của Promise
+func (n *debugNode) setLabel(label string) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.label = label
+}
+
+// link đặt tên bước và các Promise cha của node
+func (n *debugNode) link(op string, parents ...*debugNode) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.op = op
+	for _, parent := range parents {
+		if parent != nil {
+			n.parents = append(n.parents, parent)
+		}
+	}
+}
+
+// settled ghi lại thời điểm và trạng thái settle
+func (n *debugNode) settled(err error) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.state = StatusFulfilled
+	if err != nil {
+		n.state = StatusRejected
+	}
+	n.settledAt = time.Now()
+	pendingNodes.Delete(n.id)
+}
+
+// snapshot trả về bản sao các trường để đọc ngoài lock
+func (n *debugNode) snapshot() debugNode {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return debugNode{
+		id:        n.id,
+		created:   n.created,
+		op:        n.op,
+		label:     n.label,
+		parents:   append([]*debugNode(nil), n.parents...),
+		state:     n.state,
+		settledAt: n.settledAt,
+	}
+}
+
+// describe trả về một dòng mô tả node: #3 Map "parse" pending for 5.1s
+func (n *debugNode) describe() string {
+	s := n.snapshot()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#%d %s", s.id, s.op)
+	if s.label != "" {
+		fmt.Fprintf(&sb, " %q", s.label)
+	}
+	if s.state == StatusPending {
+		fmt.Fprintf(&sb, " pending for %s", roundDuration(time.Since(s.created)))
+	} else {
+		fmt.Fprintf(&sb, " %s in %s", s.state, roundDuration(s.settledAt.Sub(s.created)))
+	}
+	return sb.String()
+}
+
+// chain trả về các node từ gốc tới n theo Promise cha đầu tiên
+func (n *debugNode) chain() []*debugNode {
+	var nodes []*debugNode
+	for cur := n; cur != nil; {
+		nodes = append(nodes, cur)
+		parents := cur.snapshot().parents
+		if len(parents) == 0 {
+			break
+		}
+		cur = parents[0]
+	}
+
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return nodes
+}
+
+// DebugString mô tả chain từ Promise gốc tới Promise này, mỗi bước một dòng
+// kèm trạng thái và thời gian. Cần bật EnableDebug trước khi tạo chain
+func (p *Promise[T]) DebugString() string {
+	if p.node == nil {
+		return fmt.Sprintf("promise %s (debug tracking disabled)", p.State())
+	}
+
+	var sb strings.Builder
+	for depth, node := range p.node.chain() {
+		sb.WriteString(strings.Repeat("  ", depth))
+		sb.WriteString(node.describe())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// DumpChain ghi DebugString của Promise vào w
+func (p *Promise[T]) DumpChain(w io.Writer) error {
+	_, err := io.WriteString(w, p.DebugString())
+	return err
+}
+
+// debugInfo trả về node debug của Promise (nil khi debug tắt)
+func (p *Promise[T]) debugInfo() *debugNode {
+	return p.node
+}
+
+// nodesOf lấy debug nodes của các Promise, dùng cho combinators
+func nodesOf[T any](promises []*Promise[T]) []*debugNode {
+	nodes := make([]*debugNode, 0, len(promises))
+	for _, p := range promises {
+		if p != nil && p.node != nil {
+			nodes = append(nodes, p.node)
+		}
+	}
+	return nodes
+}
+
+// ExportGraph ghi đồ thị phụ thuộc của các Promise roots (và mọi Promise cha của chúng)
+// dưới dạng Graphviz DOT. Cạnh đi từ Promise cha tới Promise phụ thuộc vào nó
+// Cần bật EnableDebug trước khi tạo các Promise, Promise không có debug info bị bỏ qua
+func ExportGraph(w io.Writer, roots ...AnyPromise) error {
+	var sb strings.Builder
+	sb.WriteString("digraph promises {\n\trankdir=LR;\n\tnode [shape=box];\n")
+
+	visited := make(map[*debugNode]bool)
+	var stack []*debugNode
+	for _, root := range roots {
+		if root == nil {
+			continue
+		}
+		if node := root.debugInfo(); node != nil {
+			stack = append(stack, node)
+		}
+	}
+
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[node] {
+			continue
+		}
+		visited[node] = true
+
+		s := node.snapshot()
+		fmt.Fprintf(&sb, "\tn%d [label=%q, color=%s];\n", s.id, node.describe(), stateColor(s.state))
+		for _, parent := range s.parents {
+			fmt.Fprintf(&sb, "\tn%d -> n%d;\n", parent.id, s.id)
+			stack = append(stack, parent)
+		}
+	}
+
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// stateColor chọn màu node theo trạng thái
+func stateColor(state Status) string {
+	switch state {
+	case StatusFulfilled:
+		return "green"
+	case StatusRejected:
+		return "red"
+	default:
+		return "orange"
+	}
+}
@@ -123,15 +123,24 @@ package promise2
 // Promise:
 //   - NewPromise(fn) - Tạo promise từ function
 //   - NewPromiseWithExecutor(executor) - Tạo promise với executor
-//   - Await(ctx) - Chờ kết quả (blocking)
+//   - Await(ctx) - Chờ kết quả (blocking), lỗi ctx được bọc trong *AwaitError
+//   - SetLabel(label) - Đặt tên cho promise
 //   - Then(fn) - Chuỗi promise
 //   - Map(fn) - Transform giá trị
 //   - Catch(fn) - Xử lý lỗi
 //   - Finally(fn) - Cleanup
+//   - FromResult(result) - Tạo promise đã settle từ Result
+//   - State() - Trạng thái hiện tại của promise
+//   - Done() - Channel đóng khi promise settle
+//   - AnyPromise - Interface không generic cho promises khác kiểu
+//   - DebugString() / DumpChain(w) - Debug chain khi EnableDebug(true)
 //
 // WorkerPool:
 //   - NewWorkerPool[T](numWorkers) - Tạo worker pool
 //   - Submit(fn) - Gửi task vào pool
+//   - SubmitAffinity(key, fn) - Gửi task tới worker cố định theo key
+//   - SubmitNested(fn) - Submit từ bên trong task mà không gây deadlock
+//   - Register(name, handler) / SubmitTask(desc) / Recover() - Task journal qua QueueStore
 //   - Close() - Đóng pool
 //   - Stats() - Lấy thống kê
 //
@@ -141,4 +150,6 @@ package promise2
 //   - AllSettled(...promises) - Chờ tất cả settle
 //   - Any(...promises) - Chờ cái thành công đầu tiên
 //   - Sequence(...promises) - Chạy tuần tự
+//   - SequenceTasks/SequenceTasksWith(ctx, ...tasks) - Chạy task functions tuần tự
 //   - Pool(ctx, pool, ...tasks) - Chạy tasks trong pool
+//   - WaitAll/WaitAny(ctx, ...AnyPromise) - Chờ promises khác kiểu
@@ -0,0 +1,209 @@
+package promise2
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// Quy ước serialize lỗi: mỗi lỗi được ghi thành {"code", "message"}.
+// Code lấy từ registry (các lỗi của package và của context được đăng ký sẵn,
+// dùng RegisterErrorCode cho sentinel errors của ứng dụng). Khi decode, lỗi
+// trở thành *EncodedError và errors.Is vẫn khớp với sentinel theo code.
+
+var (
+	errorCodesMu sync.RWMutex
+	errorCodes   = []errorCode{
+		{"task_panicked", ErrTaskPanicked},
+		{"pool_closed", ErrPoolClosed},
+		{"all_rejected", ErrAllPromisesRejected},
+		{"unknown_task", ErrUnknownTask},
+		{"shed", ErrShedded},
+		{"pool_shutdown", ErrPoolShutdown},
+		{"task_cancelled", ErrTaskCancelled},
+		{"task_deadline", ErrTaskDeadline},
+		{"timeout", ErrTimeout},
+		{"signalled", ErrSignalled},
+		{"watchdog_killed", ErrWatchdogKilled},
+		{"caller_cancelled", ErrCallerCancelled},
+		{"canceled", context.Canceled},
+		{"deadline_exceeded", context.DeadlineExceeded},
+	}
+)
+
+// errorCode gắn một code ổn định với một sentinel error
+type errorCode struct {
+	code string
+	err  error
+}
+
+// RegisterErrorCode đăng ký code cho sentinel error để giữ được errors.Is sau khi serialize
+func RegisterErrorCode(code string, err error) {
+	errorCodesMu.Lock()
+	defer errorCodesMu.Unlock()
+
+	errorCodes = append(errorCodes, errorCode{code: code, err: err})
+}
+
+// EncodedError là lỗi được khôi phục từ Result/PromiseStatus đã serialize
+type EncodedError struct {
+	Code     string
+	Message  string
+	sentinel error
+}
+
+// Error trả về message gốc của lỗi
+func (e *EncodedError) Error() string {
+	return e.Message
+}
+
+// Unwrap trả về sentinel error tương ứng với Code (nếu đã đăng ký)
+func (e *EncodedError) Unwrap() error {
+	return e.sentinel
+}
+
+// wireError là dạng serialize của một error
+type wireError struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// encodeError chuyển error thành wireError
+func encodeError(err error) *wireError {
+	if err == nil {
+		return nil
+	}
+
+	var encoded *EncodedError
+	if errors.As(err, &encoded) && encoded.Code != "" {
+		return &wireError{Code: encoded.Code, Message: err.Error()}
+	}
+
+	errorCodesMu.RLock()
+	defer errorCodesMu.RUnlock()
+
+	for _, ec := range errorCodes {
+		if errors.Is(err, ec.err) {
+			return &wireError{Code: ec.code, Message: err.Error()}
+		}
+	}
+	return &wireError{Message: err.Error()}
+}
+
+// decode khôi phục error từ wireError
+func (w *wireError) decode() error {
+	if w == nil {
+		return nil
+	}
+
+	errorCodesMu.RLock()
+	defer errorCodesMu.RUnlock()
+
+	encoded := &EncodedError{Code: w.Code, Message: w.Message}
+	for _, ec := range errorCodes {
+		if w.Code != "" && ec.code == w.Code {
+			encoded.sentinel = ec.err
+			break
+		}
+	}
+	return encoded
+}
+
+// resultWire là dạng serialize của Result[T]
+type resultWire[T any] struct {
+	Value T          `json:"value"`
+	Err   *wireError `json:"error,omitempty"`
+}
+
+// MarshalJSON encode Result theo quy ước lỗi của package
+func (r Result[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(resultWire[T]{Value: r.Value, Err: encodeError(r.Err)})
+}
+
+// UnmarshalJSON decode Result đã được MarshalJSON
+func (r *Result[T]) UnmarshalJSON(data []byte) error {
+	var w resultWire[T]
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	r.Value, r.Err = w.Value, w.Err.decode()
+	return nil
+}
+
+// GobEncode encode Result bằng gob
+func (r Result[T]) GobEncode() ([]byte, error) {
+	return gobEncode(resultWire[T]{Value: r.Value, Err: encodeError(r.Err)})
+}
+
+// GobDecode decode Result đã được GobEncode
+func (r *Result[T]) GobDecode(data []byte) error {
+	var w resultWire[T]
+	if err := gobDecode(data, &w); err != nil {
+		return err
+	}
+	r.Value, r.Err = w.Value, w.Err.decode()
+	return nil
+}
+
+// statusWire là dạng serialize của PromiseStatus[T]
+type statusWire[T any] struct {
+	Status Status     `json:"status"`
+	Value  T          `json:"value"`
+	Err    *wireError `json:"error,omitempty"`
+}
+
+// MarshalJSON encode PromiseStatus theo quy ước lỗi của package
+func (s PromiseStatus[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(statusWire[T]{Status: s.Status, Value: s.Value, Err: encodeError(s.Err)})
+}
+
+// UnmarshalJSON decode PromiseStatus đã được MarshalJSON
+func (s *PromiseStatus[T]) UnmarshalJSON(data []byte) error {
+	var w statusWire[T]
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	s.Status, s.Value, s.Err = w.Status, w.Value, w.Err.decode()
+	return nil
+}
+
+// GobEncode encode PromiseStatus bằng gob
+func (s PromiseStatus[T]) GobEncode() ([]byte, error) {
+	return gobEncode(statusWire[T]{Status: s.Status, Value: s.Value, Err: encodeError(s.Err)})
+}
+
+// GobDecode decode PromiseStatus đã được GobEncode
+func (s *PromiseStatus[T]) GobDecode(data []byte) error {
+	var w statusWire[T]
+	if err := gobDecode(data, &w); err != nil {
+		return err
+	}
+	s.Status, s.Value, s.Err = w.Status, w.Value, w.Err.decode()
+	return nil
+}
+
+// Result chuyển PromiseStatus thành Result tương ứng
+func (s PromiseStatus[T]) Result() Result[T] {
+	return Result[T]{Value: s.Value, Err: s.Err}
+}
+
+// FromResult tạo một Promise đã settle với kết quả r
+// Dùng để khôi phục kết quả đọc từ cache thành Promise
+func FromResult[T any](r Result[T]) *Promise[T] {
+	return newSettledPromise(r)
+}
+
+func gobEncode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gobDecode(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
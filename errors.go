@@ -1,9 +1,11 @@
 package promise2
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 var (
@@ -15,8 +17,80 @@ var (
 
 	// ErrAllPromisesRejected xảy ra khi dùng Any() và tất cả promises bị reject
 	ErrAllPromisesRejected = errors.New("all promises were rejected")
+
+	// ErrShedded xảy ra khi load shedder của pool từ chối task
+	ErrShedded = errors.New("task shed by worker pool")
+
+	// ErrPossibleDeadlock xảy ra khi Await chờ task còn nằm trong queue của pool
+	// đã bận hết workers và không có tiến triển (xem WithDeadlockDetection)
+	ErrPossibleDeadlock = errors.New("possible worker pool starvation deadlock")
+
+	// ErrExecutorNeverSettled xảy ra khi executor return mà không gọi resolve/reject
+	// (chỉ khi bật WithUnsettledCheck)
+	ErrExecutorNeverSettled = errors.New("executor returned without settling the promise")
+
+	// ErrUnknownTask xảy ra khi SubmitTask/Recover gặp task chưa Register handler
+	ErrUnknownTask = errors.New("no handler registered for task")
+
+	// ErrTaskCancelled xảy ra khi task đang chờ trong queue bị CancelQueued/CancelAll hủy
+	// Bọc context.Canceled nên ClassOf trả về ClassCancelled
+	ErrTaskCancelled = fmt.Errorf("queued task cancelled: %w", context.Canceled)
+
+	// ErrTimeout xảy ra khi Promise không settle trong thời gian của WithTimeout
+	// Bọc context.DeadlineExceeded nên ClassOf trả về ClassTimeout
+	ErrTimeout = fmt.Errorf("promise timed out: %w", context.DeadlineExceeded)
+
+	// ErrCallerCancelled khớp (errors.Is) với AwaitError khi ctx của caller bị cancel
+	// Bọc context.Canceled
+	ErrCallerCancelled = fmt.Errorf("cancelled by caller: %w", context.Canceled)
+
+	// ErrTaskDeadline xảy ra khi task của pool chạy quá timeout của pool/task (WithTimeout)
+	// Bọc ErrTimeout nên errors.Is(err, ErrTimeout) và ClassOf vẫn như cũ
+	ErrTaskDeadline = fmt.Errorf("task deadline exceeded: %w", ErrTimeout)
+
+	// ErrPoolShutdown xảy ra khi task đang chờ bị hủy vì Shutdown hết hạn
+	// Bọc ErrTaskCancelled (và context.Canceled)
+	ErrPoolShutdown = fmt.Errorf("task cancelled by pool shutdown: %w", ErrTaskCancelled)
+
+	// ErrWatchdogKilled xảy ra khi Promise bị hủy bởi StuckPromise.Kill của watchdog
+	// Bọc context.Canceled
+	ErrWatchdogKilled = fmt.Errorf("promise killed by watchdog: %w", context.Canceled)
+
+	// ErrSignalled xảy ra khi signal của Until phát trước khi Promise settle
+	ErrSignalled = errors.New("promise interrupted by signal")
+
+	// ErrScopeClosed xảy ra khi Spawn vào RequestScope đã Close
+	ErrScopeClosed = errors.New("request scope is closed")
+
+	// ErrNotRecorded xảy ra khi Recorder ở chế độ replay không có bản ghi cho task và input
+	ErrNotRecorded = errors.New("no recorded result for task input")
+
+	// ErrUnhealthy được bọc bởi lỗi của Healthy khi pool không đạt điều kiện sức khỏe
+	ErrUnhealthy = errors.New("worker pool unhealthy")
+
+	// ErrNilPromise xảy ra khi continuation của FlatMap trả về Promise nil
+	ErrNilPromise = errors.New("continuation returned nil promise")
 )
 
+// PanicError xảy ra khi task của pool, function của Promise hoặc executor panic
+// Bọc ErrTaskPanicked nên errors.Is(err, ErrTaskPanicked) và ClassOf vẫn như cũ
+type PanicError struct {
+	// Value là giá trị recover được từ panic
+	Value any
+	// Stack là stack trace của goroutine tại chỗ panic (debug.Stack)
+	Stack []byte
+}
+
+// Error trả về message kèm giá trị panic
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrTaskPanicked, e.Value)
+}
+
+// Unwrap trả về ErrTaskPanicked
+func (e *PanicError) Unwrap() error {
+	return ErrTaskPanicked
+}
+
 // AggregateError chứa nhiều errors
 type AggregateError struct {
 	errors []error
@@ -53,3 +127,157 @@ func (ae *AggregateError) Errors() []error {
 func (ae *AggregateError) Count() int {
 	return len(ae.errors)
 }
+
+// AwaitError xảy ra khi context của Await bị cancel/hết hạn trước khi Promise settle
+// errors.Is(err, context.Canceled) và context.DeadlineExceeded vẫn hoạt động qua Unwrap
+type AwaitError struct {
+	Label   string
+	Pending time.Duration
+	Err     error
+}
+
+// Error trả về message dạng: promise "load-config" cancelled after 3.2s: context canceled
+func (e *AwaitError) Error() string {
+	name := "promise"
+	if e.Label != "" {
+		name = fmt.Sprintf("promise %q", e.Label)
+	}
+
+	verb := "cancelled"
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		verb = "timed out"
+	}
+
+	return fmt.Sprintf("%s %s after %s: %v", name, verb, roundDuration(e.Pending), e.Err)
+}
+
+// Unwrap trả về lỗi gốc của context
+func (e *AwaitError) Unwrap() error {
+	return e.Err
+}
+
+// Is cho errors.Is(err, ErrCallerCancelled) khớp khi ctx của caller bị cancel
+func (e *AwaitError) Is(target error) bool {
+	return target == ErrCallerCancelled && errors.Is(e.Err, context.Canceled)
+}
+
+// roundDuration làm tròn duration cho dễ đọc trong message lỗi
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
+
+// ErrorClass phân loại nguyên nhân lỗi để retry/alerting không phải so sánh string
+type ErrorClass string
+
+const (
+	ClassNone       ErrorClass = "none"
+	ClassError      ErrorClass = "error"
+	ClassTimeout    ErrorClass = "timeout"
+	ClassCancelled  ErrorClass = "cancelled"
+	ClassPanic      ErrorClass = "panic"
+	ClassPoolClosed ErrorClass = "pool_closed"
+	ClassShed       ErrorClass = "shed"
+)
+
+// classifier được implement bởi các lỗi tự khai báo class của mình
+type classifier interface {
+	ErrorClass() ErrorClass
+}
+
+// ClassOf trả về class của err
+// Thứ tự ưu tiên: lỗi tự khai báo class, lỗi của package, lỗi của context,
+// lỗi có method Timeout() bool (ví dụ net.Error)
+func ClassOf(err error) ErrorClass {
+	if err == nil {
+		return ClassNone
+	}
+
+	var c classifier
+	if errors.As(err, &c) {
+		return c.ErrorClass()
+	}
+
+	switch {
+	case errors.Is(err, ErrTaskPanicked):
+		return ClassPanic
+	case errors.Is(err, ErrPoolClosed):
+		return ClassPoolClosed
+	case errors.Is(err, ErrShedded):
+		return ClassShed
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout
+	case errors.Is(err, context.Canceled), errors.Is(err, ErrSignalled):
+		return ClassCancelled
+	}
+
+	var t interface{ Timeout() bool }
+	if errors.As(err, &t) && t.Timeout() {
+		return ClassTimeout
+	}
+	return ClassError
+}
+
+// Class trả về class của lỗi trong Result (ClassNone nếu thành công)
+func (r Result[T]) Class() ErrorClass {
+	return ClassOf(r.Err)
+}
+
+// IsTimeout cho biết Result thất bại do timeout/deadline
+func (r Result[T]) IsTimeout() bool {
+	return r.Class() == ClassTimeout
+}
+
+// IsCancelled cho biết Result thất bại do bị cancel
+func (r Result[T]) IsCancelled() bool {
+	return r.Class() == ClassCancelled
+}
+
+// IsPanic cho biết Result thất bại do task panic
+func (r Result[T]) IsPanic() bool {
+	return r.Class() == ClassPanic
+}
+
+// CancelCause là nguyên nhân cụ thể của một lỗi cancellation/timeout, dùng cho dashboard
+type CancelCause string
+
+const (
+	// CauseNone: không phải lỗi cancellation/timeout (hoặc err nil)
+	CauseNone         CancelCause = "none"
+	CauseCaller       CancelCause = "caller"
+	CauseTimeout      CancelCause = "timeout"
+	CausePoolShutdown CancelCause = "pool_shutdown"
+	CauseTaskDeadline CancelCause = "task_deadline"
+	CauseWatchdog     CancelCause = "watchdog"
+	// CauseOther: lỗi context không rõ nguồn (CancelQueued, ctx của task, ...)
+	CauseOther CancelCause = "other"
+)
+
+// CancelCauseOf trả về nguyên nhân cancellation của err
+// Lỗi cụ thể được ưu tiên trước lỗi chung mà nó bọc (ErrTaskDeadline trước ErrTimeout)
+func CancelCauseOf(err error) CancelCause {
+	var awaitErr *AwaitError
+	switch {
+	case err == nil:
+		return CauseNone
+	case errors.Is(err, ErrWatchdogKilled):
+		return CauseWatchdog
+	case errors.Is(err, ErrPoolShutdown):
+		return CausePoolShutdown
+	case errors.Is(err, ErrTaskDeadline):
+		return CauseTaskDeadline
+	case errors.Is(err, ErrTimeout):
+		return CauseTimeout
+	case errors.As(err, &awaitErr):
+		return CauseCaller
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CauseOther
+	}
+	return CauseNone
+}
@@ -0,0 +1,120 @@
+package promise2
+
+import "sort"
+
+// WithFairScheduling chia lượt chạy giữa các mức độ ưu tiên (xem WithPriority) theo trọng số
+// thay vì ưu tiên tuyệt đối: khi mọi mức đều có task chờ, mức có trọng số w được chọn
+// w/tổng trọng số số lần, nên task nền vẫn tiến triển khi tải ưu tiên cao kéo dài
+// Mức không có trong weights có trọng số 1. Bật luôn WithContextPriority
+func WithFairScheduling(weights map[int]int) PoolOption {
+	return func(c *poolConfig) {
+		c.priority = true
+		c.fairWeights = weights
+	}
+}
+
+// fairStore giữ task theo từng mức độ ưu tiên và chọn mức bằng smooth weighted round-robin
+type fairStore[T any] struct {
+	weights map[int]int
+	levels  map[int]*fairLevel[T]
+	count   int
+	// last ghi lại lần pop gần nhất để pushFront hoàn tác nếu task được trả lại
+	last fairPop[T]
+}
+
+// fairPop là thay đổi lượt của một lần pop
+type fairPop[T any] struct {
+	rank   uint64
+	total  int
+	chosen *fairLevel[T]
+	bumped []*fairLevel[T]
+}
+
+// fairLevel là hàng đợi của một mức độ ưu tiên
+type fairLevel[T any] struct {
+	items   taskHeap[T]
+	weight  int
+	current int
+}
+
+func newFairStore[T any](weights map[int]int) *fairStore[T] {
+	return &fairStore[T]{weights: weights, levels: make(map[int]*fairLevel[T])}
+}
+
+func (f *fairStore[T]) Len() int { return f.count }
+
+// level trả về hàng đợi của mức độ ưu tiên, tạo mới nếu chưa có
+func (f *fairStore[T]) level(priority int) *fairLevel[T] {
+	l, ok := f.levels[priority]
+	if !ok {
+		weight := f.weights[priority]
+		if weight <= 0 {
+			weight = 1
+		}
+		l = &fairLevel[T]{weight: weight}
+		f.levels[priority] = l
+	}
+	return l
+}
+
+func (f *fairStore[T]) push(item prioritized[T]) {
+	f.level(item.task.priority).items.push(item)
+	f.count++
+}
+
+// pushFront trả task về mức của nó và hoàn tác thay đổi lượt của lần pop đã lấy nó ra,
+// để dispatcher trả lại task nhiều lần không làm lệch tỉ lệ giữa các mức
+func (f *fairStore[T]) pushFront(item prioritized[T]) {
+	l := f.level(item.task.priority)
+	l.items.pushFront(item)
+	f.count++
+
+	if last := f.last; last.chosen == l && last.rank == item.rank {
+		for _, bumped := range last.bumped {
+			bumped.current -= bumped.weight
+		}
+		l.current += last.total
+	}
+	f.last = fairPop[T]{}
+}
+
+func (f *fairStore[T]) pop() prioritized[T] {
+	total := f.activeWeight()
+
+	// Duyệt theo thứ tự ưu tiên giảm dần để hòa thì mức cao hơn được chọn
+	priorities := make([]int, 0, len(f.levels))
+	for priority, l := range f.levels {
+		if l.items.Len() > 0 {
+			priorities = append(priorities, priority)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	var chosen *fairLevel[T]
+	bumped := make([]*fairLevel[T], 0, len(priorities))
+	for _, priority := range priorities {
+		l := f.levels[priority]
+		l.current += l.weight
+		bumped = append(bumped, l)
+		if chosen == nil || l.current > chosen.current {
+			chosen = l
+		}
+	}
+
+	chosen.current -= total
+	f.count--
+	item := chosen.items.pop()
+	f.last = fairPop[T]{rank: item.rank, total: total, chosen: chosen, bumped: bumped}
+	return item
+}
+
+// activeWeight là tổng trọng số của các mức đang có task chờ
+func (f *fairStore[T]) activeWeight() int {
+	total := 0
+	for _, l := range f.levels {
+		if l.items.Len() > 0 {
+			total += l.weight
+		}
+	}
+	return total
+}
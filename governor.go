@@ -0,0 +1,126 @@
+package promise2
+
+import (
+	"context"
+	"runtime"
+)
+
+// Governor giới hạn tổng số task đang chạy đồng thời trên nhiều WorkerPool
+// Dùng chung một Governor (WithGovernor) để giữ tổng concurrency của process
+// dưới một ngưỡng (ví dụ ≤ 256) bất kể số workers của từng pool
+type Governor struct {
+	slots chan struct{}
+	// cpu giới hạn riêng các task CPU-bound (nil nếu không bật WithCPUFraction)
+	cpu chan struct{}
+}
+
+// GovernorOption cấu hình thêm cho Governor
+type GovernorOption func(*Governor)
+
+// WithCPUFraction giới hạn số task CPU-bound (pool có WithCPUBound) chạy cùng lúc
+// trên mọi pool dùng chung Governor ở fraction * GOMAXPROCS (tối thiểu 1)
+// Task IO-bound không bị ảnh hưởng nên workload hỗn hợp không tranh hết core
+func WithCPUFraction(fraction float64) GovernorOption {
+	return func(g *Governor) {
+		n := int(fraction * float64(runtime.GOMAXPROCS(0)))
+		if n < 1 {
+			n = 1
+		}
+		g.cpu = make(chan struct{}, n)
+	}
+}
+
+// NewGovernor tạo Governor cho phép tối đa limit task chạy cùng lúc
+func NewGovernor(limit int, opts ...GovernorOption) *Governor {
+	if limit <= 0 {
+		limit = 1
+	}
+	g := &Governor{slots: make(chan struct{}, limit)}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Acquire chờ tới khi có slot trống hoặc ctx kết thúc
+func (g *Governor) Acquire(ctx context.Context) error {
+	select {
+	case g.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryAcquire lấy slot nếu còn trống, không chờ
+func (g *Governor) TryAcquire() bool {
+	select {
+	case g.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release trả lại slot đã Acquire
+func (g *Governor) Release() {
+	<-g.slots
+}
+
+// InUse trả về số slot đang được dùng
+func (g *Governor) InUse() int {
+	return len(g.slots)
+}
+
+// Limit trả về số slot tối đa
+func (g *Governor) Limit() int {
+	return cap(g.slots)
+}
+
+// acquireCPU chờ slot CPU-bound, không làm gì nếu Governor không giới hạn CPU
+func (g *Governor) acquireCPU() {
+	if g.cpu != nil {
+		g.cpu <- struct{}{}
+	}
+}
+
+// releaseCPU trả lại slot đã acquireCPU
+func (g *Governor) releaseCPU() {
+	if g.cpu != nil {
+		<-g.cpu
+	}
+}
+
+// CPUInUse trả về số task CPU-bound đang chạy (0 nếu không bật WithCPUFraction)
+func (g *Governor) CPUInUse() int {
+	return len(g.cpu)
+}
+
+// EffectiveParallelism trả về số task CPU-bound thực sự có thể chạy song song:
+// nhỏ nhất giữa Limit, giới hạn CPU của WithCPUFraction và GOMAXPROCS hiện tại
+func (g *Governor) EffectiveParallelism() int {
+	n := runtime.GOMAXPROCS(0)
+	if g.Limit() < n {
+		n = g.Limit()
+	}
+	if g.cpu != nil && cap(g.cpu) < n {
+		n = cap(g.cpu)
+	}
+	return n
+}
+
+// WithGovernor giới hạn các task của pool bằng Governor dùng chung
+// Worker lấy slot của Governor trước khi chạy task và trả lại khi task xong
+func WithGovernor(g *Governor) PoolOption {
+	return func(c *poolConfig) {
+		c.governor = g
+	}
+}
+
+// WithCPUBound đánh dấu mọi task của pool là CPU-bound: ngoài slot thường,
+// worker còn lấy slot CPU của Governor (xem WithCPUFraction) trước khi chạy task
+func WithCPUBound() PoolOption {
+	return func(c *poolConfig) {
+		c.cpuBound = true
+	}
+}
@@ -0,0 +1,128 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+)
+
+// groupCtx là key của context chứa group ID
+type groupCtx struct{}
+
+// WithGroup gắn group ID vào ctx, task SubmitCtx với ctx này thuộc group đó
+// Dùng cho một thao tác logic gồm nhiều task (ví dụ tạo một báo cáo) để
+// CancelGroup/WaitGroupID cả nhóm cùng lúc
+func WithGroup(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, groupCtx{}, id)
+}
+
+// GroupFromContext trả về group ID đã gắn bằng WithGroup
+func GroupFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(groupCtx{}).(string)
+	return id, ok && id != ""
+}
+
+// taskGroup theo dõi các task chưa settle của một group
+// Group bị xóa khỏi pool khi task cuối cùng settle, ID có thể dùng lại sau đó
+type taskGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	pending int
+	errs    []error
+	idle    chan struct{}
+}
+
+// taskGroups giữ các group đang có task chưa settle
+type taskGroups struct {
+	mu     sync.Mutex
+	groups map[string]*taskGroup
+}
+
+// join thêm một task vào group id, tạo group nếu chưa có
+func (g *taskGroups) join(id string) *taskGroup {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.groups == nil {
+		g.groups = make(map[string]*taskGroup)
+	}
+	group, ok := g.groups[id]
+	if !ok {
+		group = &taskGroup{idle: make(chan struct{})}
+		group.ctx, group.cancel = context.WithCancel(context.Background())
+		g.groups[id] = group
+	}
+	group.pending++
+	return group
+}
+
+// leave ghi nhận một task của group đã settle với err
+func (g *taskGroups) leave(id string, group *taskGroup, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err != nil {
+		group.errs = append(group.errs, err)
+	}
+	group.pending--
+	if group.pending == 0 {
+		group.cancel()
+		close(group.idle)
+		if g.groups[id] == group {
+			delete(g.groups, id)
+		}
+	}
+}
+
+// get trả về group id và số task chưa settle của nó
+func (g *taskGroups) get(id string) (*taskGroup, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	group, ok := g.groups[id]
+	if !ok {
+		return nil, 0
+	}
+	return group, group.pending
+}
+
+// CancelGroup hủy mọi task chưa settle của group id: task đang chờ bị reject với
+// ErrTaskCancelled khi tới lượt, task đang chạy bị hủy ctx
+// Task submit vào group trong lúc các task cũ chưa settle cũng bị hủy
+// Trả về số task chưa settle của group tại thời điểm gọi
+func (p *WorkerPool[T]) CancelGroup(id string) int {
+	group, pending := p.groups.get(id)
+	if group != nil {
+		group.cancel()
+	}
+	return pending
+}
+
+// WaitGroupID chờ mọi task của group id settle hoặc ctx kết thúc
+// Trả về AggregateError chứa lỗi của các task bị reject, nil nếu tất cả fulfilled
+// hoặc group không có task nào đang chờ
+func (p *WorkerPool[T]) WaitGroupID(ctx context.Context, id string) error {
+	group, _ := p.groups.get(id)
+	if group == nil {
+		return nil
+	}
+
+	select {
+	case <-group.idle:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	p.groups.mu.Lock()
+	defer p.groups.mu.Unlock()
+
+	if len(group.errs) == 0 {
+		return nil
+	}
+	return NewAggregateError(append([]error(nil), group.errs...))
+}
+
+// groupCancelled cho biết group của task đã bị CancelGroup hay chưa
+func (t task[T]) groupCancelled() bool {
+	return t.group != nil && t.group.ctx.Err() != nil
+}
@@ -0,0 +1,62 @@
+package promise2
+
+// WithAdaptiveQueue thay buffer cố định của queue chung bằng ring buffer tự tăng gấp đôi
+// (bắt đầu từ numWorkers*2) cho đến max task chờ, tránh phải chọn trước kích thước queue
+// cho tải burst. Khi đã đầy ở max, task mới chờ đến khi có chỗ như queue thường
+// Stats().PeakQueueSize cho biết số task chờ lớn nhất từng có để chọn max hợp lý
+func WithAdaptiveQueue(max int) PoolOption {
+	return func(c *poolConfig) {
+		c.queueMax = max
+	}
+}
+
+// taskRing là hàng đợi FIFO trên ring buffer, tăng gấp đôi khi đầy
+type taskRing[T any] struct {
+	buf   []prioritized[T]
+	head  int
+	count int
+}
+
+func newTaskRing[T any](size int) *taskRing[T] {
+	if size < 1 {
+		size = 1
+	}
+	return &taskRing[T]{buf: make([]prioritized[T], size)}
+}
+
+func (r *taskRing[T]) Len() int { return r.count }
+
+func (r *taskRing[T]) push(item prioritized[T]) {
+	r.grow()
+	r.buf[(r.head+r.count)%len(r.buf)] = item
+	r.count++
+}
+
+func (r *taskRing[T]) pushFront(item prioritized[T]) {
+	r.grow()
+	r.head = (r.head - 1 + len(r.buf)) % len(r.buf)
+	r.buf[r.head] = item
+	r.count++
+}
+
+func (r *taskRing[T]) pop() prioritized[T] {
+	item := r.buf[r.head]
+	// Xóa tham chiếu để task đã lấy ra được GC
+	r.buf[r.head] = prioritized[T]{}
+	r.head = (r.head + 1) % len(r.buf)
+	r.count--
+	return item
+}
+
+// grow tăng gấp đôi buffer khi đầy, giữ nguyên thứ tự các task
+func (r *taskRing[T]) grow() {
+	if r.count < len(r.buf) {
+		return
+	}
+
+	buf := make([]prioritized[T], len(r.buf)*2)
+	n := copy(buf, r.buf[r.head:])
+	copy(buf[n:], r.buf[:r.head])
+	r.buf = buf
+	r.head = 0
+}
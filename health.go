@@ -0,0 +1,79 @@
+package promise2
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// HealthCheck kiểm tra một điều kiện sức khỏe của pool từ Stats, trả về lỗi nếu không đạt
+type HealthCheck func(stats PoolStats) error
+
+// WithHealthChecks thêm các điều kiện được Healthy kiểm tra
+func WithHealthChecks(checks ...HealthCheck) PoolOption {
+	return func(c *poolConfig) {
+		c.healthChecks = append(c.healthChecks, checks...)
+	}
+}
+
+// QueueBelow không đạt khi queue có từ n task đang chờ trở lên
+func QueueBelow(n int) HealthCheck {
+	return func(stats PoolStats) error {
+		if stats.QueueSize >= n {
+			return fmt.Errorf("%w: queue size %d, threshold %d", ErrUnhealthy, stats.QueueSize, n)
+		}
+		return nil
+	}
+}
+
+// WorkersAlive không đạt khi có worker đã dừng
+func WorkersAlive() HealthCheck {
+	return func(stats PoolStats) error {
+		if stats.AliveWorkers < stats.NumWorkers {
+			return fmt.Errorf("%w: %d of %d workers alive", ErrUnhealthy, stats.AliveWorkers, stats.NumWorkers)
+		}
+		return nil
+	}
+}
+
+// Healthy trả về nil nếu pool đang phục vụ được: chưa đóng, warmup đã xong
+// không lỗi và mọi HealthCheck của WithHealthChecks đều đạt
+// Lỗi của điều kiện đầu tiên không đạt được trả về
+func (p *WorkerPool[T]) Healthy() error {
+	p.mu.RLock()
+	closed := p.closed
+	p.mu.RUnlock()
+	if closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case <-p.warmup.ready:
+	default:
+		return fmt.Errorf("%w: workers warming up", ErrUnhealthy)
+	}
+	if err := p.warmup.err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrUnhealthy, err)
+	}
+
+	stats := p.Stats()
+	for _, check := range p.healthChecks {
+		if err := check(stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HealthHandler expose Healthy dưới dạng HTTP endpoint cho liveness/readiness probe:
+// 200 "ok" khi healthy, 503 kèm message lỗi khi không
+func HealthHandler(target interface{ Healthy() error }) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := target.Healthy(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err.Error())
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
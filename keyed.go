@@ -0,0 +1,116 @@
+package promise2
+
+import (
+	"sync"
+	"time"
+)
+
+// WithResultCache giữ kết quả fulfilled của task SubmitKeyed trong ttl
+// Trong khoảng đó, SubmitKeyed cùng key trả về kết quả đã cache mà không chạy lại task
+func WithResultCache(ttl time.Duration) PoolOption {
+	return func(c *poolConfig) {
+		c.cacheTTL = ttl
+	}
+}
+
+// keyedResults gom các task SubmitKeyed theo key: task đang chạy và kết quả gần đây
+type keyedResults[T any] struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	inflight  map[string]*Promise[T]
+	cache     map[string]cachedResult[T]
+	nextSweep time.Time
+}
+
+// cachedResult là một kết quả được cache kèm thời điểm hết hạn
+type cachedResult[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func newKeyedResults[T any](ttl time.Duration) *keyedResults[T] {
+	return &keyedResults[T]{
+		ttl:      ttl,
+		inflight: make(map[string]*Promise[T]),
+		cache:    make(map[string]cachedResult[T]),
+	}
+}
+
+// SubmitKeyed submit task gắn với key
+// Nếu task cùng key đang chạy, Promise của nó được trả về thay vì submit thêm;
+// nếu pool bật WithResultCache và key có kết quả fulfilled chưa hết hạn,
+// Promise đã settle với kết quả đó được trả về ngay
+func (p *WorkerPool[T]) SubmitKeyed(key string, fn func() (T, error)) *Promise[T] {
+	k := p.keyed
+	now := time.Now()
+
+	k.mu.Lock()
+	if entry, ok := k.cache[key]; ok {
+		if now.Before(entry.expires) {
+			k.mu.Unlock()
+			return p.settledPromise(Result[T]{Value: entry.value})
+		}
+		delete(k.cache, key)
+	}
+	if promise, ok := k.inflight[key]; ok {
+		k.mu.Unlock()
+		return promise
+	}
+
+	// Giữ chỗ cho key rồi mới Submit ngoài lock, vì Submit có thể chờ (queue đầy, memory
+	// budget, Governor) và không được chặn các key khác; caller cùng key tới trong lúc đó nhận slot
+	slot := newPromise[T]()
+	slot.node.setOp("SubmitKeyed")
+	k.inflight[key] = slot
+	k.mu.Unlock()
+
+	promise := p.Submit(fn)
+
+	k.mu.Lock()
+	if k.inflight[key] == slot {
+		k.inflight[key] = promise
+	}
+	k.mu.Unlock()
+
+	release := func() {
+		k.finish(key, slot, promise)
+		slot.settle(promise.result)
+	}
+	if promise.State() != StatusPending {
+		// Submit bị từ chối ngay (pool đóng, bị shed): trả slot luôn
+		release()
+		return promise
+	}
+	go func() {
+		<-promise.Done()
+		release()
+	}()
+
+	return promise
+}
+
+// finish bỏ task khỏi danh sách đang chạy và cache kết quả nếu thành công
+func (k *keyedResults[T]) finish(key string, slot, promise *Promise[T]) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if current := k.inflight[key]; current == slot || current == promise {
+		delete(k.inflight, key)
+	}
+	if k.ttl <= 0 || promise.result.Err != nil {
+		return
+	}
+
+	now := time.Now()
+	k.cache[key] = cachedResult[T]{value: promise.result.Value, expires: now.Add(k.ttl)}
+
+	// Dọn các entry hết hạn định kỳ để cache không phình theo số key đã từng dùng
+	if now.After(k.nextSweep) {
+		for key, entry := range k.cache {
+			if !now.Before(entry.expires) {
+				delete(k.cache, key)
+			}
+		}
+		k.nextSweep = now.Add(k.ttl)
+	}
+}
@@ -0,0 +1,97 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+)
+
+// KeyedLimiter giới hạn số task chạy đồng thời theo key (ví dụ hostname), thêm vào
+// giới hạn chung của combinator/pool, để fan-out/crawl lịch sự với từng origin
+//
+//	hosts := promise2.NewKeyedLimiter(2)
+//	for i, u := range urls {
+//		tasks[i] = promise2.KeyLimited(hosts, u.Host, fetch(u))
+//	}
+//	statuses, _ := promise2.AllSettledTasks(ctx, 32, tasks...).Await(ctx)
+//
+// Task chờ slot của key vẫn giữ slot chung của combinator/pool trong lúc chờ
+type KeyedLimiter struct {
+	perKey int
+
+	mu   sync.Mutex
+	keys map[string]*keySlots
+}
+
+// keySlots là semaphore của một key, bị xóa khi không còn ai dùng
+type keySlots struct {
+	sem   chan struct{}
+	users int
+}
+
+// NewKeyedLimiter tạo KeyedLimiter cho phép tối đa perKey task cùng lúc mỗi key (tối thiểu 1)
+func NewKeyedLimiter(perKey int) *KeyedLimiter {
+	return &KeyedLimiter{perKey: max(perKey, 1), keys: make(map[string]*keySlots)}
+}
+
+// Acquire chờ slot của key; trả về release để trả slot (gọi đúng một lần)
+// Trả về ctx.Err() nếu ctx kết thúc trước khi có slot
+func (l *KeyedLimiter) Acquire(ctx context.Context, key string) (release func(), err error) {
+	l.mu.Lock()
+	slots, ok := l.keys[key]
+	if !ok {
+		slots = &keySlots{sem: make(chan struct{}, l.perKey)}
+		l.keys[key] = slots
+	}
+	slots.users++
+	l.mu.Unlock()
+
+	select {
+	case slots.sem <- struct{}{}:
+		var once sync.Once
+		return func() {
+			once.Do(func() {
+				<-slots.sem
+				l.leave(key, slots)
+			})
+		}, nil
+	case <-ctx.Done():
+		l.leave(key, slots)
+		return nil, ctx.Err()
+	}
+}
+
+// InFlight trả về số task đang giữ slot của key
+func (l *KeyedLimiter) InFlight(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if slots, ok := l.keys[key]; ok {
+		return len(slots.sem)
+	}
+	return 0
+}
+
+// leave bỏ một người dùng của key, xóa key khi không còn ai
+func (l *KeyedLimiter) leave(key string, slots *keySlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots.users--
+	if slots.users == 0 {
+		delete(l.keys, key)
+	}
+}
+
+// KeyLimited bọc task để chỉ chạy khi có slot của key trong l
+// Dùng với AllSettledTasks, SubmitCtx, Retry hay bất kỳ nơi nhận func(ctx) (T, error)
+func KeyLimited[T any](l *KeyedLimiter, key string, task func(ctx context.Context) (T, error)) func(ctx context.Context) (T, error) {
+	return func(ctx context.Context) (T, error) {
+		release, err := l.Acquire(ctx, key)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		defer release()
+		return task(ctx)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build promise2lean
+
+package promise2
+
+// leanMode được bật bằng build tag promise2lean (go build -tags promise2lean):
+// Promise không ghi label, thời điểm tạo, không báo ResultSink và không theo dõi debug,
+// các nhánh này bị compiler loại bỏ để chi phí gần với goroutine + channel thuần
+// SetLabel không có tác dụng, Label trả về rỗng, AwaitError.Pending luôn là 0
+const leanMode = true
@@ -0,0 +1,106 @@
+package promise2
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// defaultLoop là EventLoop chạy continuation của mọi chain không gắn On/OnLoop
+var defaultLoop atomic.Pointer[EventLoop]
+
+// EventLoop chạy các bước chain lần lượt trên một goroutine duy nhất (trampoline)
+// Bước nào settle trước thì chạy trước, không có hai bước chạy song song, giống
+// microtask queue của JavaScript: hữu ích cho simulation cần thứ tự xác định hoặc
+// code chỉ được chạy trên một "UI thread"
+// Bước chạy trên loop không được Await Promise chưa settle vì sẽ chặn cả loop
+type EventLoop struct {
+	mu     sync.Mutex
+	queue  []func()
+	closed bool
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+// NewEventLoop tạo EventLoop và khởi động goroutine của nó
+func NewEventLoop() *EventLoop {
+	l := &EventLoop{
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Post đưa fn vào cuối queue của loop, không chờ fn chạy
+// Sau khi loop đã Close, fn chạy trên goroutine riêng để chain vẫn settle
+func (l *EventLoop) Post(fn func()) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		go fn()
+		return
+	}
+	l.queue = append(l.queue, fn)
+	l.mu.Unlock()
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close chạy hết các bước đã Post rồi dừng goroutine của loop
+// Không được gọi Close từ một bước đang chạy trên chính loop đó
+func (l *EventLoop) Close() {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+	<-l.done
+}
+
+// run lấy lần lượt từng bước trong queue ra chạy
+func (l *EventLoop) run() {
+	defer close(l.done)
+
+	for {
+		l.mu.Lock()
+		if len(l.queue) == 0 {
+			closed := l.closed
+			l.mu.Unlock()
+			if closed {
+				return
+			}
+			<-l.wake
+			continue
+		}
+		fn := l.queue[0]
+		l.queue[0] = nil
+		l.queue = l.queue[1:]
+		l.mu.Unlock()
+
+		fn()
+	}
+}
+
+// SetDefaultLoop chạy continuation (Then/Map/Catch/Finally) của mọi chain trên loop
+// thay vì goroutine mới, trừ chain đã gắn On/OnLoop. nil trả lại hành vi mặc định
+func SetDefaultLoop(loop *EventLoop) {
+	defaultLoop.Store(loop)
+}
+
+// OnLoop trả về Promise settle cùng kết quả với p, các bước Then/Map/Catch/Finally
+// nối sau nó chạy trên loop (xem EventLoop)
+func (p *Promise[T]) OnLoop(loop *EventLoop) *Promise[T] {
+	q := newPromise[T]()
+	q.node.link("OnLoop", p.node)
+	q.runner = loop.Post
+	p.onSettle(func() {
+		q.settle(p.result)
+	})
+	return q
+}
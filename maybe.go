@@ -0,0 +1,155 @@
+package promise2
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Maybe là giá trị có thể không tồn tại: OK = false nghĩa là "không có", không phải lỗi
+// (tên Option đã dùng cho option của NewPromise/NewWorkerPool/Submit)
+type Maybe[T any] struct {
+	Value T
+	OK    bool
+}
+
+// Some tạo Maybe có giá trị
+func Some[T any](v T) Maybe[T] {
+	return Maybe[T]{Value: v, OK: true}
+}
+
+// None tạo Maybe không có giá trị
+func None[T any]() Maybe[T] {
+	return Maybe[T]{}
+}
+
+// Get trả về giá trị và cờ có giá trị hay không
+func (m Maybe[T]) Get() (T, bool) {
+	return m.Value, m.OK
+}
+
+// OrElse trả về giá trị nếu có, ngược lại trả về fallback
+func (m Maybe[T]) OrElse(fallback T) T {
+	if m.OK {
+		return m.Value
+	}
+	return fallback
+}
+
+// CatchNotFound chuyển lỗi khớp errors.Is(err, sentinel) của p thành None, giá trị
+// fulfilled thành Some; lỗi khác vẫn reject như cũ
+// Dùng khi "không tìm thấy" không nên làm hỏng cả fan-out (ví dụ sql.ErrNoRows)
+func CatchNotFound[T any](p *Promise[T], sentinel error) *Promise[Maybe[T]] {
+	q := NewPromiseWithExecutor[Maybe[T]](func(resolve func(Maybe[T]), reject func(error)) {
+		go func() {
+			val, err := p.await(context.Background())
+			switch {
+			case err == nil:
+				resolve(Some(val))
+			case errors.Is(err, sentinel):
+				resolve(None[T]())
+			default:
+				reject(err)
+			}
+		}()
+	})
+	q.node.link("CatchNotFound", p.node)
+	return q
+}
+
+// Filter giữ giá trị của p nếu keep trả về true (Some), bỏ đi thành None nếu không
+// Dùng cùng AllMaybe để một bước lọc trong pipeline bỏ item mà không phải trả lỗi
+func Filter[T any](p *Promise[T], keep func(T) bool) *Promise[Maybe[T]] {
+	q := NewPromiseWithExecutor[Maybe[T]](func(resolve func(Maybe[T]), reject func(error)) {
+		go func() {
+			val, err := p.await(context.Background())
+			if err != nil {
+				reject(err)
+				return
+			}
+			if !keep(val) {
+				resolve(None[T]())
+				return
+			}
+			resolve(Some(val))
+		}()
+	})
+	q.node.link("Filter", p.node)
+	return q
+}
+
+// AllMaybe giống All nhưng bỏ các kết quả None, chỉ giữ giá trị Some theo thứ tự
+// Reject ngay khi một promise reject, None không được tính là lỗi
+func AllMaybe[T any](ctx context.Context, promises ...*Promise[Maybe[T]]) *Promise[[]T] {
+	all := All(ctx, promises...)
+	q := NewPromiseWithExecutor[[]T](func(resolve func([]T), reject func(error)) {
+		go func() {
+			results, err := all.await(context.Background())
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			values := make([]T, 0, len(results))
+			for _, m := range results {
+				if m.OK {
+					values = append(values, m.Value)
+				}
+			}
+			resolve(values)
+		}()
+	})
+	q.node.link("AllMaybe", all.node)
+	return q
+}
+
+// FirstSome trả về Some của promise đầu tiên fulfill với giá trị (theo thứ tự settle)
+// Nếu không promise nào có giá trị: None khi không có lỗi, AggregateError khi có
+// promise reject, để phân biệt "không có" với "lỗi"
+func FirstSome[T any](ctx context.Context, promises ...*Promise[Maybe[T]]) *Promise[Maybe[T]] {
+	q := NewPromiseWithExecutor[Maybe[T]](func(resolve func(Maybe[T]), reject func(error)) {
+		n := len(promises)
+		if n == 0 {
+			resolve(None[T]())
+			return
+		}
+
+		var mu sync.Mutex
+		var errs []error
+		settled := 0
+		var once sync.Once
+
+		for _, promise := range promises {
+			go func(p *Promise[Maybe[T]]) {
+				m, err := p.await(ctx)
+				if err == nil && m.OK {
+					once.Do(func() {
+						resolve(m)
+					})
+					return
+				}
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				}
+				settled++
+				last := settled == n
+				mu.Unlock()
+
+				if !last {
+					return
+				}
+				once.Do(func() {
+					if len(errs) > 0 {
+						reject(NewAggregateError(errs))
+						return
+					}
+					resolve(None[T]())
+				})
+			}(promise)
+		}
+	})
+	q.node.link("FirstSome", nodesOf(promises)...)
+	return q
+}
@@ -0,0 +1,145 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+)
+
+// WithMemoryBudget giới hạn tổng kích thước ước tính (bytes) của kết quả đang "in flight":
+// từ lúc submit cho đến khi kết quả được Await lần đầu
+// Khi vượt budget, Submit/SubmitCtx/SubmitSized chờ cho đến khi có kết quả được đọc;
+// một task lớn hơn cả budget vẫn được nhận khi không còn gì in flight
+func WithMemoryBudget(maxBytes int64) PoolOption {
+	return func(c *poolConfig) {
+		c.memoryBudget = maxBytes
+	}
+}
+
+// WithResultMeasurer đo kích thước thực của kết quả sau khi task chạy xong,
+// thay cho kích thước ước tính khai báo lúc submit (dùng với WithMemoryBudget)
+func WithResultMeasurer(measure func(result any) int64) PoolOption {
+	return func(c *poolConfig) {
+		c.measure = measure
+	}
+}
+
+// SubmitSized giống Submit nhưng khai báo kích thước ước tính của kết quả
+// Chờ nếu pool có WithMemoryBudget và budget đang đầy
+func (p *WorkerPool[T]) SubmitSized(size int64, fn func() (T, error)) *Promise[T] {
+	if err := p.admit(TaskInfo{Method: "SubmitSized"}); err != nil {
+		return p.settledPromise(Result[T]{Err: err})
+	}
+
+	mem, err := p.reserveMemory(context.Background(), size)
+	if err != nil {
+		return p.settledPromise(Result[T]{Err: err})
+	}
+	return p.pushTask(p.taskQueue, task[T]{fn: fn, mem: mem})
+}
+
+// reserveMemory giữ size bytes trong budget của pool (nil nếu pool không có budget)
+func (p *WorkerPool[T]) reserveMemory(ctx context.Context, size int64) (*memReservation, error) {
+	if p.memory == nil {
+		return nil, nil
+	}
+	return p.memory.acquire(ctx, p.done, size)
+}
+
+// measureResult cập nhật kích thước giữ trong budget theo kết quả thực của task
+// Task lỗi không giữ kết quả nên được trả budget ngay
+// Kết quả fulfilled cũng được tính vào MemoryUsage khi pool bật WithMemoryAccounting
+func (p *WorkerPool[T]) measureResult(t task[T], result Result[T]) {
+	if result.Err == nil && t.retained != nil {
+		t.retained.set(p.accounting.sizer(result.Value))
+	}
+	if t.mem == nil {
+		return
+	}
+	if result.Err != nil {
+		t.mem.release()
+		return
+	}
+	if p.measure != nil {
+		t.mem.resize(p.measure(result.Value))
+	}
+}
+
+// memoryBudget đếm tổng kích thước ước tính của các kết quả in flight
+type memoryBudget struct {
+	mu      sync.Mutex
+	max     int64
+	used    int64
+	changed chan struct{}
+}
+
+func newMemoryBudget(max int64) *memoryBudget {
+	return &memoryBudget{max: max, changed: make(chan struct{})}
+}
+
+// acquire chờ đến khi budget đủ chỗ cho size bytes
+func (b *memoryBudget) acquire(ctx context.Context, done <-chan struct{}, size int64) (*memReservation, error) {
+	for {
+		b.mu.Lock()
+		if b.used == 0 || b.used+max(size, 1) <= b.max {
+			b.used += size
+			b.mu.Unlock()
+			return &memReservation{budget: b, size: size}, nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-done:
+			return nil, ErrPoolClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// adjust thay đổi used và đánh thức các submitter đang chờ
+func (b *memoryBudget) adjust(delta int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used += delta
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// memReservation là phần budget được giữ cho một task
+type memReservation struct {
+	mu       sync.Mutex
+	budget   *memoryBudget
+	size     int64
+	released bool
+}
+
+// resize đổi kích thước được giữ sang size
+func (r *memReservation) resize(size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.released {
+		return
+	}
+	r.budget.adjust(size - r.size)
+	r.size = size
+}
+
+// release trả phần budget đang giữ, chỉ lần gọi đầu tiên có hiệu lực
+func (r *memReservation) release() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.released {
+		return
+	}
+	r.released = true
+	r.budget.adjust(-r.size)
+}
@@ -0,0 +1,150 @@
+package promise2
+
+import "sync"
+
+// MemoryUsage là kích thước kết quả của các task cùng label (xem WithMemoryAccounting)
+type MemoryUsage struct {
+	// Retained là tổng kích thước các kết quả fulfilled chưa được Await
+	Retained int64
+	// PeakRetained là Retained lớn nhất từng có
+	PeakRetained int64
+	// Total là tổng kích thước mọi kết quả fulfilled đã đo
+	Total int64
+	// Count là số kết quả fulfilled đã đo
+	Count uint64
+}
+
+// WithMemoryAccounting đo kích thước gần đúng (bytes) của mỗi kết quả fulfilled bằng sizer
+// và cộng dồn theo label của task (WithLabel, label rỗng cho task không đặt label)
+// Xem bằng MemoryUsage() để tìm bước nào của pipeline đang giữ nhiều dữ liệu nhất
+func WithMemoryAccounting(sizer func(result any) int64) PoolOption {
+	return func(c *poolConfig) {
+		c.sizer = sizer
+	}
+}
+
+// MemoryUsage trả về kích thước kết quả theo label, nil nếu pool không bật WithMemoryAccounting
+func (p *WorkerPool[T]) MemoryUsage() map[string]MemoryUsage {
+	if p.accounting == nil {
+		return nil
+	}
+	return p.accounting.snapshot()
+}
+
+// memoryAccounting cộng dồn MemoryUsage theo label
+type memoryAccounting struct {
+	sizer func(result any) int64
+
+	mu    sync.Mutex
+	usage map[string]*MemoryUsage
+}
+
+func newMemoryAccounting(sizer func(result any) int64) *memoryAccounting {
+	return &memoryAccounting{sizer: sizer, usage: make(map[string]*MemoryUsage)}
+}
+
+// retain ghi nhận kết quả size bytes của label bắt đầu được giữ
+func (a *memoryAccounting) retain(label string, size int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	u, ok := a.usage[label]
+	if !ok {
+		u = &MemoryUsage{}
+		a.usage[label] = u
+	}
+	u.Retained += size
+	u.PeakRetained = max(u.PeakRetained, u.Retained)
+	u.Total += size
+	u.Count++
+}
+
+// drop ghi nhận kết quả size bytes của label không còn được giữ
+// counted = false khi kết quả bị bỏ (Promise đã settle trước đó) nên không tính vào Total/Count
+func (a *memoryAccounting) drop(label string, size int64, counted bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	u := a.usage[label]
+	u.Retained -= size
+	if !counted {
+		u.Total -= size
+		u.Count--
+	}
+}
+
+// snapshot trả về bản sao MemoryUsage theo label
+func (a *memoryAccounting) snapshot() map[string]MemoryUsage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	usage := make(map[string]MemoryUsage, len(a.usage))
+	for label, u := range a.usage {
+		usage[label] = *u
+	}
+	return usage
+}
+
+// retainedResult là phần MemoryUsage của kết quả một task, giữ từ lúc task fulfill
+// tới lần Await đầu tiên. Mọi method an toàn khi r là nil (pool không bật accounting)
+type retainedResult struct {
+	acct  *memoryAccounting
+	label string
+
+	mu       sync.Mutex
+	size     int64
+	held     bool
+	released bool
+}
+
+// set ghi nhận kết quả size bytes, bỏ qua nếu Promise đã được Await (settle do timeout)
+func (r *retainedResult) set(size int64) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.released || r.held {
+		return
+	}
+	r.size = size
+	r.held = true
+	r.acct.retain(r.label, size)
+}
+
+// release được gọi khi kết quả được Await, chỉ lần đầu có hiệu lực
+func (r *retainedResult) release() {
+	r.free(true)
+}
+
+// discard bỏ kết quả không được dùng vì Promise đã settle trước đó
+func (r *retainedResult) discard() {
+	r.free(false)
+}
+
+func (r *retainedResult) free(counted bool) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.released {
+		return
+	}
+	r.released = true
+	if r.held {
+		r.acct.drop(r.label, r.size, counted)
+	}
+}
+
+// settleTask settle Promise của task; nếu Promise đã settle trước đó (hết timeout)
+// kết quả bị bỏ và không còn tính trong MemoryUsage
+func (p *WorkerPool[T]) settleTask(t task[T], result Result[T]) {
+	if !t.promise.settle(result) {
+		t.retained.discard()
+	}
+}
@@ -0,0 +1,180 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Metrics nhận số liệu của các combinator chạy với ctx từ WithCombinatorMetrics
+type Metrics interface {
+	ObserveCombinator(name string, stats CombinatorStats)
+}
+
+// MetricsFunc cho phép dùng function như Metrics
+type MetricsFunc func(name string, stats CombinatorStats)
+
+// ObserveCombinator implement Metrics
+func (f MetricsFunc) ObserveCombinator(name string, stats CombinatorStats) {
+	f(name, stats)
+}
+
+// CombinatorStats là số liệu của một combinator trong một cửa sổ thời gian
+type CombinatorStats struct {
+	// Window là độ dài cửa sổ (cửa sổ cuối có thể ngắn hơn interval)
+	Window time.Duration
+	// Completed và Failed là số phần tử xong/lỗi trong cửa sổ
+	Completed int
+	Failed    int
+	// Throughput là số phần tử settle mỗi giây trong cửa sổ
+	Throughput float64
+	// FailureRate là tỉ lệ lỗi trong cửa sổ (0..1)
+	FailureRate float64
+	// InFlight là số phần tử đang chạy ở cuối cửa sổ, MaxInFlight là cao nhất trong cửa sổ
+	InFlight    int
+	MaxInFlight int
+	// Final = true ở lần báo cáo cuối cùng khi combinator kết thúc
+	Final bool
+}
+
+// metricsCtx là key của context chứa cấu hình metrics của combinator
+type metricsCtx struct{}
+
+type metricsConfig struct {
+	metrics  Metrics
+	interval time.Duration
+}
+
+// WithCombinatorMetrics bật chế độ instrument cho All, AllSettled và GroupBy chạy với ctx trả về:
+// mỗi interval (và khi kết thúc) combinator báo throughput, số phần tử đang chạy và tỉ lệ lỗi
+// cho metrics, giúp chọn giới hạn concurrency dựa trên số liệu
+func WithCombinatorMetrics(ctx context.Context, metrics Metrics, interval time.Duration) context.Context {
+	return context.WithValue(ctx, metricsCtx{}, metricsConfig{metrics: metrics, interval: interval})
+}
+
+// combinatorProbe đo một lần chạy combinator, nil khi ctx không bật metrics
+// Các method đều an toàn khi probe là nil
+type combinatorProbe struct {
+	name    string
+	cfg     metricsConfig
+	stop    chan struct{}
+	stopped sync.Once
+	looped  chan struct{}
+
+	mu          sync.Mutex
+	windowStart time.Time
+	completed   int
+	failed      int
+	inFlight    int
+	maxInFlight int
+}
+
+// newCombinatorProbe bắt đầu đo combinator name nếu ctx bật metrics
+func newCombinatorProbe(ctx context.Context, name string) *combinatorProbe {
+	cfg, ok := ctx.Value(metricsCtx{}).(metricsConfig)
+	if !ok || cfg.metrics == nil {
+		return nil
+	}
+
+	probe := &combinatorProbe{
+		name:        name,
+		cfg:         cfg,
+		stop:        make(chan struct{}),
+		looped:      make(chan struct{}),
+		windowStart: time.Now(),
+	}
+	if cfg.interval > 0 {
+		go probe.loop()
+	} else {
+		close(probe.looped)
+	}
+	return probe
+}
+
+// loop báo cáo mỗi interval cho đến khi combinator kết thúc
+func (c *combinatorProbe) loop() {
+	defer close(c.looped)
+
+	ticker := time.NewTicker(c.cfg.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.report(false)
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// started ghi nhận một phần tử bắt đầu chạy
+func (c *combinatorProbe) started() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight++
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+}
+
+// settled ghi nhận một phần tử chạy xong
+func (c *combinatorProbe) settled(err error) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight--
+	if err != nil {
+		c.failed++
+	} else {
+		c.completed++
+	}
+}
+
+// finish dừng đo và gửi báo cáo cuối
+func (c *combinatorProbe) finish() {
+	if c == nil {
+		return
+	}
+
+	c.stopped.Do(func() {
+		close(c.stop)
+		// Chờ loop dừng để báo cáo cuối luôn là báo cáo sau cùng
+		<-c.looped
+		c.report(true)
+	})
+}
+
+// report gửi số liệu của cửa sổ hiện tại rồi bắt đầu cửa sổ mới
+func (c *combinatorProbe) report(final bool) {
+	c.mu.Lock()
+	now := time.Now()
+	stats := CombinatorStats{
+		Window:      now.Sub(c.windowStart),
+		Completed:   c.completed,
+		Failed:      c.failed,
+		InFlight:    c.inFlight,
+		MaxInFlight: c.maxInFlight,
+		Final:       final,
+	}
+	c.windowStart = now
+	c.completed, c.failed = 0, 0
+	c.maxInFlight = c.inFlight
+	c.mu.Unlock()
+
+	settled := stats.Completed + stats.Failed
+	if stats.Window > 0 {
+		stats.Throughput = float64(settled) / stats.Window.Seconds()
+	}
+	if settled > 0 {
+		stats.FailureRate = float64(stats.Failed) / float64(settled)
+	}
+	c.cfg.metrics.ObserveCombinator(c.name, stats)
+}
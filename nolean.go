@@ -0,0 +1,6 @@
+//go:build !promise2lean
+
+package promise2
+
+// leanMode là false ở build mặc định (xem lean.go)
+const leanMode = false
@@ -0,0 +1,132 @@
+package promise2
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/phucps89/go-promise2/backoff"
+)
+
+// Option là option dùng chung cho NewPromise, NewPromiseWithExecutor, NewWorkerPool,
+// Submit, SubmitCtx và các combinator (qua WithOptions); mỗi API chỉ dùng phần của
+// option áp dụng được cho nó
+// PromiseOption và PoolOption cũng là Option
+type Option interface {
+	applyPromise(*promiseConfig)
+	applyPool(*poolConfig)
+	applyTask(*taskConfig)
+}
+
+func (o PromiseOption) applyPromise(c *promiseConfig) { o(c) }
+func (o PromiseOption) applyPool(*poolConfig)         {}
+func (o PromiseOption) applyTask(*taskConfig)         {}
+
+func (o PoolOption) applyPromise(*promiseConfig) {}
+func (o PoolOption) applyPool(c *poolConfig)     { o(c) }
+func (o PoolOption) applyTask(*taskConfig)       {}
+
+// taskConfig chứa cấu hình riêng của một task submit vào pool
+type taskConfig struct {
+	label   string
+	timeout time.Duration
+	retry   backoff.Policy
+	sink    ResultSink
+	tracer  Tracer
+}
+
+// newTaskConfig áp dụng các Option cho một task, trả về nil nếu không có option nào
+func newTaskConfig(opts []Option) *taskConfig {
+	if len(opts) == 0 {
+		return nil
+	}
+
+	cfg := &taskConfig{}
+	for _, opt := range opts {
+		opt.applyTask(cfg)
+	}
+	return cfg
+}
+
+type optionsCtx struct{}
+
+// WithOptions gắn opts vào ctx cho các combinator nhận ctx (All, AllSettled, Race, Any,
+// Fallback, Sequence, TopN, ...): phần áp dụng cho Promise (label, timeout, sink, tracer)
+// được dùng cho Promise mà combinator trả về
+// Giống WithProgress, ctx truyền xuống task và combinator lồng bên trong cũng mang opts
+//
+//	users := promise2.All(promise2.WithOptions(ctx, promise2.WithLabel("load users"), promise2.WithTimeout(time.Second)), ps...)
+func WithOptions(ctx context.Context, opts ...Option) context.Context {
+	return context.WithValue(ctx, optionsCtx{}, opts)
+}
+
+// optionsFrom trả về các Option gắn bởi WithOptions, nil nếu không có
+func optionsFrom(ctx context.Context) []Option {
+	opts, _ := ctx.Value(optionsCtx{}).([]Option)
+	return opts
+}
+
+// sharedOption là Option áp dụng cho nhiều loại API, phần nil được bỏ qua
+type sharedOption struct {
+	promise func(*promiseConfig)
+	pool    func(*poolConfig)
+	task    func(*taskConfig)
+}
+
+func (o sharedOption) applyPromise(c *promiseConfig) {
+	if o.promise != nil {
+		o.promise(c)
+	}
+}
+
+func (o sharedOption) applyPool(c *poolConfig) {
+	if o.pool != nil {
+		o.pool(c)
+	}
+}
+
+func (o sharedOption) applyTask(c *taskConfig) {
+	if o.task != nil {
+		o.task(c)
+	}
+}
+
+// WithLabel đặt label cho Promise tạo bởi NewPromise/NewPromiseWithExecutor hoặc Submit
+// Không có tác dụng với NewWorkerPool
+func WithLabel(label string) Option {
+	return sharedOption{
+		promise: func(c *promiseConfig) { c.label = label },
+		task:    func(c *taskConfig) { c.label = label },
+	}
+}
+
+// WithTimeout reject Promise với ErrTimeout nếu chưa settle sau d
+// Với NewWorkerPool, d là timeout mặc định của mọi task; với SubmitCtx ctx của task cũng bị hủy sau d
+// Công việc của NewPromise/Submit không nhận ctx nên vẫn chạy tiếp, kết quả của nó bị bỏ qua
+func WithTimeout(d time.Duration) Option {
+	return sharedOption{
+		promise: func(c *promiseConfig) { c.timeout = d },
+		pool:    func(c *poolConfig) { c.timeout = d },
+		task:    func(c *taskConfig) { c.timeout = d },
+	}
+}
+
+// expireAfter reject Promise với ErrTimeout nếu chưa settle sau d
+func (p *Promise[T]) expireAfter(d time.Duration) {
+	p.expireWith(d, ErrTimeout)
+}
+
+// expireWith giống expireAfter nhưng reject với cause (bọc kèm d)
+func (p *Promise[T]) expireWith(d time.Duration, cause error) {
+	if d <= 0 {
+		return
+	}
+
+	timer := time.AfterFunc(d, func() {
+		p.settle(Result[T]{Err: fmt.Errorf("%w after %s", cause, d)})
+	})
+	go func() {
+		<-p.done
+		timer.Stop()
+	}()
+}
@@ -0,0 +1,164 @@
+package promise2
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PhasePolicy quyết định Phases làm gì khi một task của phase lỗi
+type PhasePolicy int
+
+const (
+	// AbortOnError hủy các task còn lại của phase và không chạy các phase sau
+	AbortOnError PhasePolicy = iota
+	// ContinueOnError ghi nhận lỗi, chạy hết phase rồi sang phase tiếp theo
+	ContinueOnError
+)
+
+// PhaseStats là thống kê của một phase đã chạy
+type PhaseStats struct {
+	Name      string
+	Started   time.Time
+	Duration  time.Duration
+	Succeeded int
+	Failed    int
+	// Skipped là số task không được chạy vì phase bị hủy (AbortOnError hoặc ctx kết thúc)
+	Skipped int
+	Errors  []error
+}
+
+// PhaseError xảy ra khi phase AbortOnError có task lỗi hoặc ctx kết thúc giữa chừng
+type PhaseError struct {
+	Phase string
+	Err   error
+	// Stats là thống kê các phase đã chạy, kể cả phase lỗi
+	Stats []PhaseStats
+}
+
+// Error trả về message kèm tên phase lỗi
+func (e *PhaseError) Error() string {
+	return fmt.Sprintf("phase %q: %v", e.Phase, e.Err)
+}
+
+// Unwrap trả về lỗi gốc
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// Phases chạy các phase có tên theo thứ tự, mỗi phase gồm nhiều task chạy song song;
+// mọi task của phase N xong mới bắt đầu phase N+1 (barrier), thay cho WaitGroup tự viết
+// trong các job ETL lớn
+//
+//	stats, err := promise2.NewPhases().
+//		Add("extract", promise2.AbortOnError, extractTasks...).
+//		Add("transform", promise2.ContinueOnError, transformTasks...).
+//		Add("load", promise2.AbortOnError, loadTasks...).
+//		Run(ctx, 8).Await(ctx)
+type Phases struct {
+	phases []phase
+}
+
+// phase là một phase đã đăng ký
+type phase struct {
+	name   string
+	policy PhasePolicy
+	tasks  []func(ctx context.Context) error
+}
+
+// NewPhases tạo Phases rỗng
+func NewPhases() *Phases {
+	return &Phases{}
+}
+
+// Add đăng ký phase name với policy và các task của nó, chạy sau các phase đã Add trước
+func (ps *Phases) Add(name string, policy PhasePolicy, tasks ...func(ctx context.Context) error) *Phases {
+	ps.phases = append(ps.phases, phase{name: name, policy: policy, tasks: tasks})
+	return ps
+}
+
+// Run chạy lần lượt các phase, tối đa concurrency task cùng lúc trong mỗi phase
+// (concurrency <= 0 là không giới hạn). Fulfill với thống kê từng phase; reject với
+// *PhaseError khi phase AbortOnError có lỗi (Err là AggregateError) hoặc ctx kết thúc
+// Panic trong task được tính là lỗi của task
+func (ps *Phases) Run(ctx context.Context, concurrency int) *Promise[[]PhaseStats] {
+	phases := append([]phase(nil), ps.phases...)
+	q := NewPromiseWithExecutor[[]PhaseStats](func(resolve func([]PhaseStats), reject func(error)) {
+		go func() {
+			var stats []PhaseStats
+			for _, ph := range phases {
+				if err := ctx.Err(); err != nil {
+					reject(&PhaseError{Phase: ph.name, Err: err, Stats: stats})
+					return
+				}
+
+				s := ph.run(ctx, concurrency)
+				stats = append(stats, s)
+				if s.Failed > 0 && ph.policy == AbortOnError {
+					reject(&PhaseError{Phase: ph.name, Err: NewAggregateError(s.Errors), Stats: stats})
+					return
+				}
+				if s.Skipped > 0 {
+					reject(&PhaseError{Phase: ph.name, Err: ctx.Err(), Stats: stats})
+					return
+				}
+			}
+			resolve(stats)
+		}()
+	})
+	q.node.setOp("Phases")
+	return q
+}
+
+// run chạy mọi task của phase và chờ chúng xong
+func (ph phase) run(ctx context.Context, concurrency int) PhaseStats {
+	if concurrency <= 0 {
+		concurrency = max(len(ph.tasks), 1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stats := PhaseStats{Name: ph.name, Started: time.Now()}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for _, task := range ph.tasks {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			mu.Lock()
+			stats.Skipped++
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(task func(ctx context.Context) error) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result := recovered(RejectOnPanic(), func() (struct{}, error) { return struct{}{}, task(ctx) })
+
+			mu.Lock()
+			defer mu.Unlock()
+			if result.Err != nil {
+				stats.Failed++
+				stats.Errors = append(stats.Errors, result.Err)
+				if ph.policy == AbortOnError {
+					cancel()
+				}
+				return
+			}
+			stats.Succeeded++
+		}(task)
+	}
+
+	wg.Wait()
+	stats.Duration = time.Since(stats.Started)
+	return stats
+}
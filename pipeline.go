@@ -0,0 +1,92 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+)
+
+// Conduit nối hai pool thành pipeline hai tầng: kết quả của tầng đầu (pool A)
+// được transform rồi chạy trên tầng sau (pool B)
+// Số item đang ở trong pipeline bị giới hạn bởi buffer, Submit chờ khi pipeline đầy
+type Conduit[A, B any] struct {
+	first     *WorkerPool[A]
+	second    *WorkerPool[B]
+	transform func(A) (B, error)
+	slots     chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Connect tạo Conduit từ poolA qua transform sang poolB với tối đa buffer item trong pipeline
+// (buffer <= 0 thì dùng tổng số workers của hai pool)
+// Conduit sở hữu hai pool: Close của Conduit drain cả pipeline rồi đóng cả hai
+func Connect[A, B any](poolA *WorkerPool[A], transform func(A) (B, error), poolB *WorkerPool[B], buffer int) *Conduit[A, B] {
+	if buffer <= 0 {
+		buffer = poolA.Stats().NumWorkers + poolB.Stats().NumWorkers
+	}
+
+	return &Conduit[A, B]{
+		first:     poolA,
+		second:    poolB,
+		transform: transform,
+		slots:     make(chan struct{}, buffer),
+	}
+}
+
+// Submit chạy fn trên pool A, rồi transform kết quả trên pool B
+// Chờ khi pipeline đã đủ buffer item; nếu ctx kết thúc trong lúc chờ, Promise reject với ctx.Err()
+// Lỗi ở tầng đầu được chuyển thẳng ra Promise, tầng sau không chạy
+func (c *Conduit[A, B]) Submit(ctx context.Context, fn func() (A, error)) *Promise[B] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return newSettledPromise(Result[B]{Err: ErrPoolClosed})
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return newSettledPromise(Result[B]{Err: ctx.Err()})
+	}
+
+	first := c.first.Submit(fn)
+	q := newPromise[B]()
+	q.node.link("Connect", first.node)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer func() { <-c.slots }()
+
+		val, err := first.await(context.Background())
+		if err != nil {
+			q.settle(Result[B]{Err: err})
+			return
+		}
+
+		out, err := c.second.Submit(func() (B, error) {
+			return c.transform(val)
+		}).await(context.Background())
+		q.settle(Result[B]{Value: out, Err: err})
+	}()
+
+	return q
+}
+
+// Close ngừng nhận item mới, chờ mọi item đang trong pipeline đi hết hai tầng
+// rồi đóng pool A và pool B
+func (c *Conduit[A, B]) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	c.wg.Wait()
+
+	if err := c.first.Close(); err != nil {
+		return err
+	}
+	return c.second.Close()
+}
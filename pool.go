@@ -1,117 +1,957 @@
 package promise2
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/phucps89/go-promise2/backoff"
 )
 
 // WorkerPool quản lý một pool của workers để xử lý tasks
 type WorkerPool[T any] struct {
 	taskQueue chan task[T]
+	affinity  []chan task[T]
+	reserved  map[string]chan task[T]
+	prio      *priorityQueue[T]
+	// usePriority cho biết pool bật WithContextPriority
+	usePriority bool
+
+	// cancelMu bảo vệ phạm vi hủy hiện tại của CancelQueued/CancelAll
+	cancelMu  sync.Mutex
+	queuedCh  chan struct{}
+	runCtx    context.Context
+	runCancel context.CancelFunc
 	wg        sync.WaitGroup
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
 	done      chan struct{}
 	workers   int
+	store     QueueStore
+	onError   func(error)
+	backend   ExecBackend
+	governor  *Governor
+
+	controller ConcurrencyController
+	limiter    *dynamicLimiter
+	shedder    func(PoolStats) bool
+
+	running   atomic.Int32
+	overflow  atomic.Int32
+	completed atomic.Uint64
+	peak      atomic.Int64
+	tags      tagCounters
+	shutdown  atomic.Pointer[shutdownRecorder]
+	warmup    *warmupState
+	alive     atomic.Int32
+	// expired báo Shutdown đã hết hạn, task chờ bị hủy sau đó nhận ErrPoolShutdown
+	expired atomic.Bool
+
+	healthChecks []HealthCheck
+	sink         ResultSink
+	tracer       Tracer
+	memory       *memoryBudget
+	measure      func(any) int64
+
+	deadlockTimeout time.Duration
+	onDeadlock      func(DeadlockInfo)
+	recover         RecoverPolicy
+	retry           backoff.Policy
+	requeue         backoff.Policy
+	keyed           *keyedResults[T]
+
+	expectedDuration  time.Duration
+	onDeadlineWarning func(DeadlineWarning)
+	transform         func(Result[T]) Result[T]
+	timeout           atomic.Int64
+	admission         func(TaskInfo) error
+	cpuBound          bool
+	groups            taskGroups
+	accounting        *memoryAccounting
+
+	// scaling bảo vệ extra; extra là stop channel của các worker thêm bởi Apply
+	scaling    sync.Mutex
+	extra      []chan struct{}
+	extraCount atomic.Int32
+	// capacity giới hạn số task chạy cùng lúc khi Apply giảm Workers, rate giới hạn tốc độ bắt đầu task
+	capacity atomic.Pointer[dynamicLimiter]
+	rate     atomic.Pointer[rateGate]
+
+	handlersMu sync.RWMutex
+	handlers   map[string]func(payload []byte) (T, error)
+}
+
+// PoolOption cấu hình thêm cho WorkerPool
+type PoolOption func(*poolConfig)
+
+// poolConfig chứa các cấu hình tùy chọn của WorkerPool
+type poolConfig struct {
+	store      QueueStore
+	onError    func(error)
+	backend    ExecBackend
+	governor   *Governor
+	controller ConcurrencyController
+	shedder    func(PoolStats) bool
+
+	deadlockTimeout time.Duration
+	onDeadlock      func(DeadlockInfo)
+	recover         RecoverPolicy
+	retry           backoff.Policy
+	requeue         backoff.Policy
+	cacheTTL        time.Duration
+	reserved        map[string]int
+	priority        bool
+	order           SchedulingOrder
+	memoryBudget    int64
+	measure         func(any) int64
+	warmup          func(ctx context.Context, workerID int) error
+	healthChecks    []HealthCheck
+	sink            ResultSink
+	tracer          Tracer
+
+	expectedDuration  time.Duration
+	onDeadlineWarning func(DeadlineWarning)
+	transform         any
+	timeout           time.Duration
+	queueMax          int
+	admission         func(TaskInfo) error
+	fairWeights       map[int]int
+	cpuBound          bool
+	sizer             func(any) int64
+}
+
+// WithLoadShedder gọi shed với Stats hiện tại mỗi lần Submit
+// Nếu shed trả về true, task bị reject ngay với ErrShedded mà không vào queue
+func WithLoadShedder(shed func(PoolStats) bool) PoolOption {
+	return func(c *poolConfig) {
+		c.shedder = shed
+	}
+}
+
+// WithReserved dành riêng n workers cho task của class (submit qua SubmitClass)
+// Các workers này không nhận task thường và không bị giới hạn bởi controller,
+// Governor hay load shedder, nên task quan trọng như health check hoặc shutdown
+// hook luôn có chỗ chạy kể cả khi queue chung đã đầy
+func WithReserved(class string, n int) PoolOption {
+	return func(c *poolConfig) {
+		if c.reserved == nil {
+			c.reserved = make(map[string]int)
+		}
+		c.reserved[class] += n
+	}
+}
+
+// WithQueueStore dùng store để journal các task submit qua SubmitTask
+// Mặc định pool dùng MemoryQueueStore
+func WithQueueStore(store QueueStore) PoolOption {
+	return func(c *poolConfig) {
+		c.store = store
+	}
+}
+
+// WithErrorHook nhận các lỗi nền của pool không gắn với Promise nào,
+// ví dụ QueueStore không ack được task đã chạy xong (task sẽ chạy lại khi Recover)
+// hook chạy trên goroutine của worker nên phải ngắn và không block
+func WithErrorHook(hook func(err error)) PoolOption {
+	return func(c *poolConfig) {
+		c.onError = hook
+	}
 }
 
 // task đại diện cho một công việc cần làm
 type task[T any] struct {
-	fn func() (T, error)
-	ch chan Result[T]
+	fn      func() (T, error)
+	promise *Promise[T]
+	// ctx của task submit qua SubmitCtx, dùng để dừng retry sớm
+	ctx context.Context
+	// priority dùng khi pool bật WithContextPriority
+	priority int
+	// cancelled được đóng khi task chưa chạy bị CancelQueued/CancelAll hủy
+	cancelled <-chan struct{}
+	// tag là tên submitter lấy từ ctx của SubmitCtx
+	tag string
+	// mem là phần memory budget được giữ cho kết quả của task
+	mem *memReservation
+	// cfg là option riêng của task (WithLabel, WithTimeout, WithRetry, WithResultSink)
+	cfg *taskConfig
+	// group là group của task (WithGroup), nil nếu không thuộc group nào
+	group *taskGroup
+	// retained là phần MemoryUsage của kết quả (WithMemoryAccounting), nil nếu không bật
+	retained *retainedResult
+	// requeues là số lần task đã được đưa lại queue do bị rate limit (WithRateLimitRequeue),
+	// requeuePolicy là bản riêng của policy đó cho task (xem backoff.Fresh)
+	requeues      int
+	requeuePolicy backoff.Policy
 }
 
 // NewWorkerPool tạo một worker pool mới với số lượng workers
-func NewWorkerPool[T any](numWorkers int) *WorkerPool[T] {
+func NewWorkerPool[T any](numWorkers int, opts ...Option) *WorkerPool[T] {
 	if numWorkers <= 0 {
 		numWorkers = 1
 	}
 
+	cfg := poolConfig{}
+	for _, opt := range opts {
+		opt.applyPool(&cfg)
+	}
+	if cfg.store == nil {
+		cfg.store = NewMemoryQueueStore()
+	}
+
+	// Khi sắp xếp theo độ ưu tiên, thứ tự khác FIFO hoặc queue tự tăng kích thước,
+	// task chờ trong priorityQueue thay vì buffer của channel
+	scheduled := cfg.priority || cfg.order != OrderFIFO || cfg.queueMax > 0
+	queueSize := numWorkers * 2
+	if scheduled {
+		queueSize = 0
+	}
+
 	pool := &WorkerPool[T]{
-		taskQueue: make(chan task[T], numWorkers*2),
-		done:      make(chan struct{}),
-		workers:   numWorkers,
+		taskQueue:  make(chan task[T], queueSize),
+		affinity:   make([]chan task[T], numWorkers),
+		reserved:   make(map[string]chan task[T], len(cfg.reserved)),
+		done:       make(chan struct{}),
+		workers:    numWorkers,
+		store:      cfg.store,
+		onError:    cfg.onError,
+		backend:    cfg.backend,
+		governor:   cfg.governor,
+		controller: cfg.controller,
+		shedder:    cfg.shedder,
+
+		deadlockTimeout: cfg.deadlockTimeout,
+		onDeadlock:      cfg.onDeadlock,
+		recover:         cfg.recover,
+		retry:           cfg.retry,
+		requeue:         cfg.requeue,
+		keyed:           newKeyedResults[T](cfg.cacheTTL),
+		measure:         cfg.measure,
+		warmup:          newWarmupState(cfg.warmup, numWorkers),
+		healthChecks:    cfg.healthChecks,
+		usePriority:     cfg.priority,
+		sink:            cfg.sink,
+		tracer:          cfg.tracer,
+		handlers:        make(map[string]func(payload []byte) (T, error)),
+		queuedCh:        make(chan struct{}),
+
+		expectedDuration:  cfg.expectedDuration,
+		onDeadlineWarning: cfg.onDeadlineWarning,
+		transform:         resultTransform[T](&cfg),
+		admission:         cfg.admission,
+		cpuBound:          cfg.cpuBound,
 	}
+	pool.runCtx, pool.runCancel = context.WithCancel(context.Background())
+	pool.timeout.Store(int64(cfg.timeout))
 
-	// Khởi tạo workers
+	if cfg.controller != nil {
+		pool.limiter = newDynamicLimiter(numWorkers)
+	}
+	if cfg.sizer != nil {
+		pool.accounting = newMemoryAccounting(cfg.sizer)
+	}
+	if cfg.memoryBudget > 0 {
+		pool.memory = newMemoryBudget(cfg.memoryBudget)
+	}
+	if scheduled {
+		pool.prio = newPriorityQueue[T](cfg.order)
+		if cfg.fairWeights != nil {
+			pool.prio.items = newFairStore[T](cfg.fairWeights)
+		}
+		if cfg.queueMax > 0 {
+			pool.prio.max = cfg.queueMax
+			if !cfg.priority && cfg.order == OrderFIFO {
+				pool.prio.items = newTaskRing[T](numWorkers * 2)
+			}
+		}
+		go pool.dispatch()
+	}
+
+	// Khởi tạo workers, mỗi worker có một queue riêng cho affinity tasks
 	for i := 0; i < numWorkers; i++ {
+		pool.affinity[i] = make(chan task[T], 2)
 		pool.wg.Add(1)
-		go pool.worker()
+		pool.alive.Add(1)
+		go pool.worker(i)
+	}
+
+	// Workers dành riêng cho từng class, chỉ nhận task của class đó
+	for class, n := range cfg.reserved {
+		if n <= 0 {
+			continue
+		}
+		queue := make(chan task[T], n*2)
+		pool.reserved[class] = queue
+		for i := 0; i < n; i++ {
+			pool.wg.Add(1)
+			go pool.reservedWorker(queue)
+		}
 	}
 
 	return pool
 }
 
-// worker là một worker routine xử lý tasks từ queue
-func (p *WorkerPool[T]) worker() {
+// worker là một worker routine xử lý tasks từ queue chung và queue riêng của nó
+// Worker chỉ dừng khi cả hai queue đã đóng và không còn task nào
+func (p *WorkerPool[T]) worker(id int) {
 	defer p.wg.Done()
 
-	for {
+	defer p.alive.Add(-1)
+
+	p.warmup.run(id)
+
+	queue := p.taskQueue
+	own := p.affinity[id]
+
+	for queue != nil || own != nil {
 		select {
-		case <-p.done:
-			return
-		case t, ok := <-p.taskQueue:
+		case t, ok := <-queue:
 			if !ok {
-				return
+				queue = nil
+				continue
+			}
+			p.executeTask(t)
+		case t, ok := <-own:
+			if !ok {
+				own = nil
+				continue
 			}
 			p.executeTask(t)
 		}
 	}
 }
 
+// reservedWorker xử lý task của một class được WithReserved dành riêng
+func (p *WorkerPool[T]) reservedWorker(queue chan task[T]) {
+	defer p.wg.Done()
+
+	for t := range queue {
+		if p.rejectCancelled(t) {
+			continue
+		}
+
+		t.promise.queued.Store(false)
+		p.tags.started(t.tag)
+		p.running.Add(1)
+		result := p.runTask(t, false)
+		p.running.Add(-1)
+		p.completed.Add(1)
+		p.tags.finished(t.tag, result.Err)
+		p.recordShutdown(result.Err, false)
+		p.measureResult(t, result)
+
+		p.settleTask(t, result)
+	}
+}
+
 // executeTask thực thi một task và gửi kết quả
 func (p *WorkerPool[T]) executeTask(t task[T]) {
-	defer func() {
-		if r := recover(); r != nil {
-			t.ch <- Result[T]{Err: ErrTaskPanicked}
+	if p.rejectCancelled(t) {
+		return
+	}
+
+	if p.limiter != nil {
+		p.limiter.acquire()
+		defer p.limiter.release()
+	}
+	if l := p.capacity.Load(); l != nil {
+		l.acquire()
+		defer l.release()
+	}
+	if g := p.rate.Load(); g != nil {
+		g.wait(p.done)
+	}
+
+	t.promise.queued.Store(false)
+	p.warnDeadline(t)
+	p.tags.started(t.tag)
+	p.running.Add(1)
+	start := time.Now()
+	result := p.runTask(t, true)
+	p.running.Add(-1)
+	if p.requeueRateLimited(t, result.Err) {
+		return
+	}
+	p.completed.Add(1)
+	p.tags.finished(t.tag, result.Err)
+	p.recordShutdown(result.Err, false)
+
+	if p.limiter != nil {
+		p.limiter.setLimit(p.controller.Observe(time.Since(start), result.Err, p.limiter.current()))
+	}
+	p.measureResult(t, result)
+
+	p.settleTask(t, result)
+}
+
+// runTask chạy function của task, panic được xử lý theo RecoverPolicy của pool
+// governed cho biết task có phải lấy slot của Governor hay không
+// Nếu pool bật WithRetry, task được chạy lại cho đến khi policy dừng
+// Kết quả cuối cùng đi qua transform của WithResultTransform (nếu có)
+func (p *WorkerPool[T]) runTask(t task[T], governed bool) Result[T] {
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	policy := p.retry
+	if t.cfg != nil && t.cfg.retry != nil {
+		policy = t.cfg.retry
+	}
+
+	result := retryLoop(ctx, policy, p.done, func() Result[T] {
+		if governed && p.governor != nil {
+			p.governor.Acquire(context.Background())
+			defer p.governor.Release()
+			if p.cpuBound {
+				p.governor.acquireCPU()
+				defer p.governor.releaseCPU()
+			}
 		}
-	}()
 
-	val, err := t.fn()
-	t.ch <- Result[T]{Value: val, Err: err}
+		return recovered(p.recover, t.fn)
+	})
+	if p.transform != nil {
+		result = p.transform(result)
+	}
+	return result
 }
 
 // Submit thêm một task vào queue và trả về Promise
-func (p *WorkerPool[T]) Submit(fn func() (T, error)) *Promise[T] {
-	promise := &Promise[T]{
-		resultChan: make(chan Result[T], 1),
+// opts áp dụng riêng cho task này (WithLabel, WithTimeout, WithRetry, WithResultSink)
+func (p *WorkerPool[T]) Submit(fn func() (T, error), opts ...Option) *Promise[T] {
+	cfg := newTaskConfig(opts)
+	return p.enqueue(p.taskQueue, task[T]{fn: fn, cfg: cfg}, taskInfo("Submit", cfg))
+}
+
+// SubmitClass gửi task của class vào các workers được dành riêng bằng WithReserved
+// Nếu class không có workers dành riêng, task được Submit như bình thường
+func (p *WorkerPool[T]) SubmitClass(class string, fn func() (T, error)) *Promise[T] {
+	queue, ok := p.reserved[class]
+	if !ok {
+		return p.Submit(fn)
 	}
 
+	if err := p.checkAdmission(TaskInfo{Method: "SubmitClass", Key: class}); err != nil {
+		return p.settledPromise(Result[T]{Err: err})
+	}
+	return p.push(queue, fn)
+}
+
+// SubmitCtx giống Submit nhưng fn nhận ctx
+// ctx mang idempotency key ổn định qua các lần retry (xem IdempotencyKey)
+// và thông tin lần thử (Attempt, FirstAttemptAt); khi ctx kết thúc, pool không retry task nữa
+// opts giống Submit; với WithTimeout ctx của task cũng bị hủy khi hết thời gian
+func (p *WorkerPool[T]) SubmitCtx(ctx context.Context, fn func(ctx context.Context) (T, error), opts ...Option) *Promise[T] {
+	ctx = withAttemptInfo(withIdempotencyKey(ctx))
+	cfg := newTaskConfig(opts)
+
+	info := taskInfo("SubmitCtx", cfg)
+	info.Ctx = ctx
+	info.Submitter, _ = SubmitterFromContext(ctx)
+	info.Priority, _ = PriorityFromContext(ctx)
+	if err := p.admit(info); err != nil {
+		return p.settledPromise(Result[T]{Err: err})
+	}
+
+	mem, err := p.reserveMemory(ctx, 0)
+	if err != nil {
+		return p.settledPromise(Result[T]{Err: err})
+	}
+
+	timeout := time.Duration(p.timeout.Load())
+	if cfg != nil && cfg.timeout > 0 {
+		timeout = cfg.timeout
+	}
+
+	// ctx của task cũng bị hủy khi CancelAll được gọi hoặc hết timeout
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, ErrTaskDeadline)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	stop := context.AfterFunc(p.runScope(), cancel)
+
+	// Task thuộc group cũng bị hủy khi CancelGroup được gọi
+	groupID, inGroup := GroupFromContext(ctx)
+	var group *taskGroup
+	stopGroup := func() bool { return false }
+	if inGroup {
+		group = p.groups.join(groupID)
+		stopGroup = context.AfterFunc(group.ctx, cancel)
+	}
+
+	var priority int
+	if p.usePriority {
+		priority, _ = PriorityFromContext(ctx)
+	}
+	tag, _ := SubmitterFromContext(ctx)
+	promise := p.pushTask(p.taskQueue, task[T]{
+		fn:       func() (T, error) { return fn(ctx) },
+		ctx:      ctx,
+		priority: priority,
+		tag:      tag,
+		mem:      mem,
+		cfg:      cfg,
+		group:    group,
+	})
+	promise.abort = cancel
+
 	go func() {
-		t := task[T]{
-			fn: fn,
-			ch: promise.resultChan,
+		<-promise.Done()
+		// Promise hết timeout thì chờ ctx tự hết hạn (sớm hơn hoặc cùng lúc) để task thấy
+		// DeadlineExceeded thay vì Canceled
+		if timeout > 0 && errors.Is(promise.result.Err, ErrTaskDeadline) {
+			<-ctx.Done()
+		}
+		stop()
+		stopGroup()
+		cancel()
+		if inGroup {
+			p.groups.leave(groupID, group, promise.result.Err)
+		}
+	}()
+
+	return promise
+}
+
+// SubmitNested dùng cho task được submit từ bên trong một task khác của cùng pool
+// Nếu pool đang bận hết workers hoặc queue còn task chờ, task chạy ngay trên một
+// overflow goroutine (không qua queue, limiter và Governor) nên task cha Await nó
+// không thể gây starvation deadlock; ngược lại task được Submit như bình thường
+func (p *WorkerPool[T]) SubmitNested(fn func() (T, error)) *Promise[T] {
+	if !p.saturated() && p.queueLen() == 0 {
+		return p.Submit(fn)
+	}
+
+	if err := p.admit(TaskInfo{Method: "SubmitNested"}); err != nil {
+		return p.settledPromise(Result[T]{Err: err})
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return p.settledPromise(Result[T]{Err: ErrPoolClosed})
+	}
+
+	promise := p.newTaskPromise()
+	promise.node.setOp("SubmitNested")
+
+	p.wg.Add(1)
+	p.overflow.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer p.overflow.Add(-1)
+
+		promise.settle(p.runTask(task[T]{fn: fn, promise: promise}, false))
+	}()
+
+	return promise
+}
+
+// SubmitAffinity thêm một task vào queue riêng của worker được chọn theo key
+// Cùng một key luôn được xử lý bởi cùng một worker (với cùng số lượng workers),
+// giúp cache và connection theo từng worker được tái sử dụng
+func (p *WorkerPool[T]) SubmitAffinity(key string, fn func() (T, error)) *Promise[T] {
+	info := TaskInfo{Method: "SubmitAffinity", Key: key}
+	return p.enqueue(p.affinity[p.workerFor(key)], task[T]{fn: fn}, info)
+}
+
+// workerFor chọn worker cho key bằng jump consistent hash,
+// nên khi số workers thay đổi chỉ một phần nhỏ keys bị chuyển sang worker khác
+func (p *WorkerPool[T]) workerFor(key string) int {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return jumpHash(h.Sum64(), len(p.affinity))
+}
+
+// jumpHash là thuật toán jump consistent hash của Lamping & Veach
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// Register đăng ký handler cho các task mang tên name
+// Handler phải được đăng ký trước khi SubmitTask hoặc Recover (trừ khi dùng ExecBackend)
+func (p *WorkerPool[T]) Register(name string, handler func(payload []byte) (T, error)) {
+	p.handlersMu.Lock()
+	defer p.handlersMu.Unlock()
+
+	p.handlers[name] = handler
+}
+
+// SubmitTask journal task vào QueueStore rồi submit vào queue
+// Task được ack sau khi chạy xong (dù thành công hay lỗi)
+func (p *WorkerPool[T]) SubmitTask(desc TaskDescriptor) *Promise[T] {
+	handler, ok := p.handler(desc.Name)
+	if !ok {
+		return p.settledPromise(Result[T]{Err: fmt.Errorf("%w: %q", ErrUnknownTask, desc.Name)})
+	}
+
+	if err := p.admit(TaskInfo{Method: "SubmitTask", Name: desc.Name}); err != nil {
+		return p.settledPromise(Result[T]{Err: err})
+	}
+
+	id, err := p.store.Append(desc)
+	if err != nil {
+		return p.settledPromise(Result[T]{Err: fmt.Errorf("journal task %q: %w", desc.Name, err)})
+	}
+
+	return p.push(p.taskQueue, p.journaled(id, handler, desc.Payload))
+}
+
+// Recover chạy lại các task chưa được ack trong QueueStore (ví dụ sau khi restart)
+// Trả về lỗi nếu có task không tìm thấy handler, các task còn lại vẫn được submit
+func (p *WorkerPool[T]) Recover() ([]*Promise[T], error) {
+	records, err := p.store.Recover()
+	if err != nil {
+		return nil, err
+	}
+
+	promises := make([]*Promise[T], 0, len(records))
+	var errs []error
+	for _, rec := range records {
+		handler, ok := p.handler(rec.Task.Name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %q (id %d)", ErrUnknownTask, rec.Task.Name, rec.ID))
+			continue
+		}
+		promises = append(promises, p.Submit(p.journaled(rec.ID, handler, rec.Task.Payload)))
+	}
+
+	if len(errs) > 0 {
+		return promises, NewAggregateError(errs)
+	}
+	return promises, nil
+}
+
+// handler tìm handler đã đăng ký theo tên
+// Khi pool có ExecBackend, mọi task đều được chuyển cho backend với ctx bị hủy ở CancelAll tiếp theo
+func (p *WorkerPool[T]) handler(name string) (func(payload []byte) (T, error), bool) {
+	if p.backend != nil {
+		return backendHandler[T](p.runScope(), p.backend, name), true
+	}
+
+	p.handlersMu.RLock()
+	defer p.handlersMu.RUnlock()
+
+	h, ok := p.handlers[name]
+	return h, ok
+}
+
+// journaled bọc handler để ack task trong store sau khi chạy xong
+// Lỗi Ack không ảnh hưởng kết quả task (task đã chạy), chỉ được báo cho WithErrorHook
+func (p *WorkerPool[T]) journaled(id uint64, handler func(payload []byte) (T, error), payload []byte) func() (T, error) {
+	return func() (T, error) {
+		defer func() {
+			if err := p.store.Ack(id); err != nil {
+				p.reportError(fmt.Errorf("ack journaled task %d: %w", id, err))
+			}
+		}()
+		return handler(payload)
+	}
+}
+
+// reportError báo lỗi nền cho hook của WithErrorHook nếu có
+func (p *WorkerPool[T]) reportError(err error) {
+	if p.onError != nil {
+		p.onError(err)
+	}
+}
+
+// enqueue kiểm tra admit rồi gửi task vào queue chỉ định
+func (p *WorkerPool[T]) enqueue(queue chan task[T], t task[T], info TaskInfo) *Promise[T] {
+	if err := p.admit(info); err != nil {
+		return p.settledPromise(Result[T]{Err: err})
+	}
+
+	mem, err := p.reserveMemory(context.Background(), 0)
+	if err != nil {
+		return p.settledPromise(Result[T]{Err: err})
+	}
+	t.mem = mem
+	return p.pushTask(queue, t)
+}
+
+// push gửi task vào queue chỉ định và trả về Promise
+func (p *WorkerPool[T]) push(queue chan task[T], fn func() (T, error)) *Promise[T] {
+	return p.pushTask(queue, task[T]{fn: fn})
+}
+
+// pushTask gắn Promise cho t rồi gửi vào queue chỉ định
+// Queue còn chỗ thì task vào ngay (tryPush), chỉ khi queue đầy mới cần goroutine chờ
+func (p *WorkerPool[T]) pushTask(queue chan task[T], t task[T]) *Promise[T] {
+	promise := p.newTaskPromise()
+	timeout := time.Duration(p.timeout.Load())
+	tracer := p.tracer
+	if t.cfg != nil {
+		if t.cfg.sink != nil {
+			promise.sink = t.cfg.sink
+		}
+		if t.cfg.label != "" {
+			promise.SetLabel(t.cfg.label)
+		}
+		if t.cfg.timeout > 0 {
+			timeout = t.cfg.timeout
+		}
+		if t.cfg.tracer != nil {
+			tracer = t.cfg.tracer
+		}
+	}
+	promise.trace(tracer)
+	promise.expireWith(timeout, ErrTaskDeadline)
+	t.promise = promise
+	t.cancelled = p.queuedScope()
+	p.tags.enqueued(t.tag)
+	if t.mem != nil {
+		promise.onAwait = t.mem.release
+	}
+	if p.accounting != nil {
+		label := ""
+		if t.cfg != nil {
+			label = t.cfg.label
+		}
+		retained := &retainedResult{acct: p.accounting, label: label}
+		t.retained = retained
+		release := promise.onAwait
+		promise.onAwait = func() {
+			if release != nil {
+				release()
+			}
+			retained.release()
+		}
+	}
+	promise.node.setOp("Submit")
+	if p.deadlockTimeout > 0 {
+		promise.queued.Store(true)
+		promise.watcher = p
+	}
+
+	p.enqueueTask(queue, t)
+	return promise
+}
+
+// enqueueTask đưa task đã gắn Promise vào queue, chờ trên goroutine riêng nếu queue đầy
+func (p *WorkerPool[T]) enqueueTask(queue chan task[T], t task[T]) {
+	if p.prio != nil && queue == p.taskQueue {
+		if !p.addPrioritized(t) {
+			// Hàng đợi đã đầy (WithAdaptiveQueue), chờ có chỗ như queue thường
+			go p.waitPrioritized(t)
+		}
+		return
+	}
+
+	if p.tryPush(queue, t) {
+		return
+	}
+
+	go func() {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+
+		if p.closed {
+			p.reject(t, ErrPoolClosed)
+			return
+		}
+
+		// CancelQueued có thể đã drain queue trước khi goroutine này chạy tới đây,
+		// ưu tiên cancelled để task không lọt vào queue sau khi bị hủy
+		select {
+		case <-t.cancelled:
+			p.reject(t, p.cancelledErr())
+			return
+		default:
 		}
 
 		select {
-		case p.taskQueue <- t:
+		case queue <- t:
 			// Task đã được thêm vào queue
+			if queue == p.taskQueue {
+				p.recordPeak(len(queue))
+			}
 		case <-p.done:
 			// Pool đã bị đóng
-			promise.resultChan <- Result[T]{Err: ErrPoolClosed}
+			p.reject(t, ErrPoolClosed)
+		case <-t.cancelled:
+			p.reject(t, p.cancelledErr())
 		}
 	}()
+}
 
-	return promise
+// tryPush là fast path của pushTask: đưa task vào queue ngay nếu queue còn chỗ,
+// không tạo goroutine. Trả về false nếu queue đầy, khi đó task phải chờ như thường
+func (p *WorkerPool[T]) tryPush(queue chan task[T], t task[T]) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.reject(t, ErrPoolClosed)
+		return true
+	}
+
+	select {
+	case queue <- t:
+		if queue == p.taskQueue {
+			p.recordPeak(len(queue))
+		}
+		return true
+	default:
+		return false
+	}
 }
 
-// Close đóng worker pool và chờ tất cả tasks hoàn thành
+// Close đóng worker pool và chờ tất cả tasks đã vào queue hoàn thành
+// Tasks chưa kịp vào queue sẽ bị reject với ErrPoolClosed
 func (p *WorkerPool[T]) Close() error {
-	close(p.done)
-	close(p.taskQueue)
+	p.beginClose()
 	p.wg.Wait()
 	return nil
 }
 
+// beginClose ngừng nhận task mới và đóng các queue, không chờ workers
+func (p *WorkerPool[T]) beginClose() {
+	p.closeOnce.Do(func() {
+		// Đóng done trước để giải phóng các Submit đang chờ queue trống
+		close(p.done)
+		p.warmup.stop()
+
+		p.mu.Lock()
+		p.closed = true
+		close(p.taskQueue)
+		for _, q := range p.affinity {
+			close(q)
+		}
+		for _, q := range p.reserved {
+			close(q)
+		}
+		p.mu.Unlock()
+
+		// Không còn task nào được thêm vào priorityQueue sau khi closed được đặt
+		if p.prio != nil {
+			p.rejectPrioritized()
+		}
+	})
+}
+
 // PoolStats chứa thống kê của worker pool
 type PoolStats struct {
-	NumWorkers   int
-	QueueSize    int
+	NumWorkers    int
+	QueueSize     int
 	QueueCapacity int
+	// PeakQueueSize là số task chờ trong queue chung lớn nhất từng có
+	PeakQueueSize int
+	// ConcurrencyLimit là số task được phép chạy đồng thời (bằng NumWorkers nếu không có controller)
+	ConcurrencyLimit int
+	// Running là số task đang chạy trên workers
+	Running int
+	// Overflow là số task SubmitNested đang chạy ngoài workers
+	Overflow int
+	// AliveWorkers là số workers (không tính workers của WithReserved) đang hoạt động
+	AliveWorkers int
+	// Submitters là thống kê theo submitter của các task SubmitCtx gắn WithSubmitter
+	Submitters map[string]SubmitterStats
+}
+
+// reject reject task chưa được chạy (pool đóng, bị hủy)
+func (p *WorkerPool[T]) reject(t task[T], err error) {
+	p.tags.dequeued(t.tag)
+	p.recordShutdown(nil, true)
+	t.mem.release()
+	t.promise.settle(Result[T]{Err: err})
+}
+
+// recordPeak cập nhật PeakQueueSize nếu depth lớn hơn
+func (p *WorkerPool[T]) recordPeak(depth int) {
+	for {
+		peak := p.peak.Load()
+		if int64(depth) <= peak || p.peak.CompareAndSwap(peak, int64(depth)) {
+			return
+		}
+	}
+}
+
+// queueLen trả về số task đang chờ trong queue chung
+func (p *WorkerPool[T]) queueLen() int {
+	if p.prio != nil {
+		return len(p.taskQueue) + p.prio.len()
+	}
+	return len(p.taskQueue)
 }
 
 // Stats trả về thống kê hiện tại của pool
 func (p *WorkerPool[T]) Stats() PoolStats {
-	return PoolStats{
-		NumWorkers:    p.workers,
-		QueueSize:     len(p.taskQueue),
-		QueueCapacity: cap(p.taskQueue),
+	workers := p.workers + int(p.extraCount.Load())
+	stats := PoolStats{
+		NumWorkers:       workers,
+		QueueSize:        p.queueLen(),
+		QueueCapacity:    cap(p.taskQueue),
+		PeakQueueSize:    int(p.peak.Load()),
+		ConcurrencyLimit: workers,
+		Running:          int(p.running.Load()),
+		Overflow:         int(p.overflow.Load()),
+		AliveWorkers:     int(p.alive.Load()),
+		Submitters:       p.tags.snapshot(),
+	}
+	if p.prio != nil {
+		stats.QueueCapacity, stats.PeakQueueSize = p.prio.stats()
+	}
+	if p.limiter != nil {
+		stats.ConcurrencyLimit = p.limiter.current()
 	}
+	if l := p.capacity.Load(); l != nil {
+		stats.ConcurrencyLimit = min(stats.ConcurrencyLimit, l.current())
+	}
+	return stats
+}
+
+// SubscribeStats gửi Stats mỗi interval vào channel trả về
+// Consumer chậm chỉ nhận snapshot mới nhất (snapshot cũ bị bỏ qua);
+// channel được đóng khi pool đóng
+// interval <= 0 là lỗi lập trình nên panic ngay tại chỗ gọi (như time.NewTicker)
+// thay vì panic trong goroutine gửi snapshot
+func (p *WorkerPool[T]) SubscribeStats(interval time.Duration) <-chan PoolStats {
+	if interval <= 0 {
+		panic(fmt.Sprintf("promise2: SubscribeStats interval must be positive, got %s", interval))
+	}
+
+	ch := make(chan PoolStats, 1)
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				stats := p.Stats()
+				select {
+				case ch <- stats:
+				default:
+					// Bỏ snapshot cũ chưa được đọc, thay bằng snapshot mới
+					select {
+					case <-ch:
+					default:
+					}
+					ch <- stats
+				}
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	return ch
 }
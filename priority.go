@@ -0,0 +1,323 @@
+package promise2
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"sync"
+)
+
+// priorityCtx là key của context chứa độ ưu tiên của request
+type priorityCtx struct{}
+
+// WithPriority gắn độ ưu tiên vào ctx (số lớn hơn được chạy trước)
+// Thường được gọi bởi HTTP middleware theo loại request
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityCtx{}, priority)
+}
+
+// PriorityFromContext trả về độ ưu tiên đã gắn bằng WithPriority
+func PriorityFromContext(ctx context.Context) (int, bool) {
+	priority, ok := ctx.Value(priorityCtx{}).(int)
+	return priority, ok
+}
+
+// WithContextPriority sắp xếp queue chung của pool theo độ ưu tiên
+// SubmitCtx đọc độ ưu tiên từ ctx (xem WithPriority), các task khác có độ ưu tiên 0;
+// task cùng độ ưu tiên theo SchedulingOrder của pool (mặc định FIFO)
+func WithContextPriority() PoolOption {
+	return func(c *poolConfig) {
+		c.priority = true
+	}
+}
+
+// SchedulingOrder là thứ tự workers lấy task từ queue chung
+type SchedulingOrder int
+
+const (
+	// OrderFIFO lấy task vào trước trước (mặc định)
+	OrderFIFO SchedulingOrder = iota
+	// OrderLIFO lấy task mới nhất trước, giảm tail latency khi tải tăng đột biến
+	// (task cũ có thể đã bị caller bỏ cuộc)
+	OrderLIFO
+	// OrderRandom lấy task ngẫu nhiên, hữu ích cho chaos testing
+	OrderRandom
+)
+
+// String trả về tên của SchedulingOrder
+func (o SchedulingOrder) String() string {
+	switch o {
+	case OrderLIFO:
+		return "lifo"
+	case OrderRandom:
+		return "random"
+	default:
+		return "fifo"
+	}
+}
+
+// WithSchedulingOrder chọn thứ tự lấy task từ queue chung của pool
+// Khi kết hợp với WithContextPriority, thứ tự này áp dụng cho các task cùng độ ưu tiên
+func WithSchedulingOrder(order SchedulingOrder) PoolOption {
+	return func(c *poolConfig) {
+		c.order = order
+	}
+}
+
+// priorityQueue giữ các task chờ theo độ ưu tiên trước khi chuyển cho workers
+type priorityQueue[T any] struct {
+	mu     sync.Mutex
+	items  taskStore[T]
+	seq    uint64
+	held   int
+	order  SchedulingOrder
+	notify chan struct{}
+
+	// max là số task chờ tối đa (0 = không giới hạn), peak là số task chờ lớn nhất từng có
+	max  int
+	peak int
+	// space được đóng (và thay mới) mỗi khi có task rời hàng đợi
+	space chan struct{}
+}
+
+func newPriorityQueue[T any](order SchedulingOrder) *priorityQueue[T] {
+	return &priorityQueue[T]{
+		items:  &taskHeap[T]{},
+		order:  order,
+		notify: make(chan struct{}, 1),
+		space:  make(chan struct{}),
+	}
+}
+
+// add thêm task vào hàng đợi và đánh thức dispatcher
+// Trả về false nếu hàng đợi đã đầy (xem WithAdaptiveQueue)
+func (q *priorityQueue[T]) add(t task[T]) bool {
+	q.mu.Lock()
+	waiting := q.items.Len() + q.held
+	if q.max > 0 && waiting >= q.max {
+		q.mu.Unlock()
+		return false
+	}
+
+	q.seq++
+	rank := q.seq
+	switch q.order {
+	case OrderLIFO:
+		rank = ^q.seq
+	case OrderRandom:
+		rank = rand.Uint64()
+	}
+	q.items.push(prioritized[T]{task: t, rank: rank})
+	if waiting+1 > q.peak {
+		q.peak = waiting + 1
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// setMax đổi số task chờ tối đa và đánh thức các submitter đang chờ chỗ
+func (q *priorityQueue[T]) setMax(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.max = n
+	close(q.space)
+	q.space = make(chan struct{})
+}
+
+// waitSpace trả về channel được đóng khi có task rời hàng đợi
+func (q *priorityQueue[T]) waitSpace() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.space
+}
+
+// pop lấy task có độ ưu tiên cao nhất, task được tính là đang chờ cho đến khi release
+func (q *priorityQueue[T]) pop() (prioritized[T], bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.items.Len() == 0 {
+		return prioritized[T]{}, false
+	}
+	q.held++
+	return q.items.pop(), true
+}
+
+// release đánh dấu task lấy ra bởi pop đã rời khỏi hàng đợi
+// Nếu requeue, task được đưa lại vào heap với rank ban đầu
+func (q *priorityQueue[T]) release(item prioritized[T], requeue bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.held--
+	if requeue {
+		q.items.pushFront(item)
+		return
+	}
+	close(q.space)
+	q.space = make(chan struct{})
+}
+
+// len trả về số task đang chờ
+func (q *priorityQueue[T]) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.items.Len() + q.held
+}
+
+// stats trả về sức chứa hiện tại và số task chờ lớn nhất từng có
+func (q *priorityQueue[T]) stats() (capacity, peak int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	capacity = q.max
+	if ring, ok := q.items.(*taskRing[T]); ok {
+		capacity = len(ring.buf)
+	}
+	return capacity, q.peak
+}
+
+// dispatch chuyển task theo thứ tự ưu tiên vào queue chung cho đến khi pool đóng
+// Task còn lại khi pool đóng được Close reject với ErrPoolClosed
+func (p *WorkerPool[T]) dispatch() {
+	for {
+		item, ok := p.prio.pop()
+		if !ok {
+			select {
+			case <-p.prio.notify:
+				continue
+			case <-p.done:
+				return
+			}
+		}
+
+		if !p.handOff(item) {
+			return
+		}
+	}
+}
+
+// handOff chờ worker rảnh để nhận task, trả về false nếu pool đã đóng
+// Nếu có task mới trong lúc chờ, task được trả lại heap để chọn lại task ưu tiên nhất
+func (p *WorkerPool[T]) handOff(item prioritized[T]) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.prio.release(item, false)
+		p.reject(item.task, ErrPoolClosed)
+		return false
+	}
+
+	select {
+	case p.taskQueue <- item.task:
+		p.prio.release(item, false)
+		return true
+	case <-p.prio.notify:
+		p.prio.release(item, true)
+		return true
+	case <-p.done:
+		p.prio.release(item, false)
+		p.reject(item.task, ErrPoolClosed)
+		return false
+	}
+}
+
+// addPrioritized thêm task vào priorityQueue, reject task nếu pool đã đóng
+// Trả về false nếu hàng đợi đã đầy
+func (p *WorkerPool[T]) addPrioritized(t task[T]) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.reject(t, ErrPoolClosed)
+		return true
+	}
+	return p.prio.add(t)
+}
+
+// waitPrioritized chờ priorityQueue có chỗ cho task, hoặc reject khi pool đóng/task bị hủy
+func (p *WorkerPool[T]) waitPrioritized(t task[T]) {
+	for {
+		space := p.prio.waitSpace()
+		if p.addPrioritized(t) {
+			return
+		}
+
+		select {
+		case <-space:
+		case <-p.done:
+			p.reject(t, ErrPoolClosed)
+			return
+		case <-t.cancelled:
+			p.reject(t, p.cancelledErr())
+			return
+		}
+	}
+}
+
+// rejectPrioritized reject các task còn chờ trong priorityQueue
+func (p *WorkerPool[T]) rejectPrioritized() {
+	for {
+		item, ok := p.prio.pop()
+		if !ok {
+			return
+		}
+		p.prio.release(item, false)
+		p.reject(item.task, ErrPoolClosed)
+	}
+}
+
+// prioritized là một phần tử của taskHeap
+// rank quyết định thứ tự giữa các task cùng độ ưu tiên (nhỏ hơn ra trước)
+type prioritized[T any] struct {
+	task task[T]
+	rank uint64
+}
+
+// taskStore là nơi priorityQueue giữ các task chờ
+type taskStore[T any] interface {
+	Len() int
+	push(item prioritized[T])
+	// pushFront trả lại task vừa lấy ra để nó được lấy ra đầu tiên ở lần sau
+	pushFront(item prioritized[T])
+	pop() prioritized[T]
+}
+
+// taskHeap là max-heap theo độ ưu tiên, cùng độ ưu tiên thì task có rank nhỏ hơn ra trước
+type taskHeap[T any] []prioritized[T]
+
+func (h *taskHeap[T]) push(item prioritized[T]) { heap.Push(h, item) }
+
+// pushFront dùng lại rank ban đầu nên task vẫn đứng đúng chỗ trong heap
+func (h *taskHeap[T]) pushFront(item prioritized[T]) { heap.Push(h, item) }
+
+func (h *taskHeap[T]) pop() prioritized[T] { return heap.Pop(h).(prioritized[T]) }
+
+func (h taskHeap[T]) Len() int { return len(h) }
+
+func (h taskHeap[T]) Less(i, j int) bool {
+	if h[i].task.priority != h[j].task.priority {
+		return h[i].task.priority > h[j].task.priority
+	}
+	return h[i].rank < h[j].rank
+}
+
+func (h taskHeap[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *taskHeap[T]) Push(x any) { *h = append(*h, x.(prioritized[T])) }
+
+func (h *taskHeap[T]) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
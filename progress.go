@@ -0,0 +1,230 @@
+package promise2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressCtx là key của context chứa callback tiến độ của combinator
+type progressCtx struct{}
+
+// WithProgress gắn fn vào ctx: All, AllSettled và AllSettledTasks chạy với ctx trả về gọi
+// fn(done, total) mỗi khi một phần tử settle, để CLI vẽ progress bar cho batch lớn
+// fn được gọi tuần tự (không đồng thời) với done tăng dần, lần cuối done == total
+func WithProgress(ctx context.Context, fn func(done, total int)) context.Context {
+	return context.WithValue(ctx, progressCtx{}, fn)
+}
+
+// progressTracker đếm phần tử đã settle của một combinator, nil khi ctx không có WithProgress
+// Các method đều an toàn khi tracker là nil
+type progressTracker struct {
+	mu    sync.Mutex
+	fn    func(done, total int)
+	done  int
+	total int
+}
+
+// newProgressTracker tạo tracker cho total phần tử nếu ctx có WithProgress
+func newProgressTracker(ctx context.Context, total int) *progressTracker {
+	fn, ok := ctx.Value(progressCtx{}).(func(done, total int))
+	if !ok || fn == nil {
+		return nil
+	}
+	return &progressTracker{fn: fn, total: total}
+}
+
+// add ghi nhận n phần tử vừa settle và báo fn
+func (t *progressTracker) add(n int) {
+	if t == nil || n <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done += n
+	t.fn(t.done, t.total)
+}
+
+// ProgressBar vẽ progress bar một dòng kèm tốc độ và ETA ra terminal, dùng với WithProgress:
+//
+//	bar := promise2.NewProgressBar(os.Stderr, "upload")
+//	statuses, _ := promise2.AllSettledTasks(promise2.WithProgress(ctx, bar.Update), 8, tasks...).Await(ctx)
+//
+// Dòng được vẽ lại tối đa mỗi 100ms (và luôn ở lần cuối, kèm xuống dòng)
+type ProgressBar struct {
+	w     io.Writer
+	label string
+	// Width là số ký tự của thanh, mặc định 30
+	Width int
+
+	mu    sync.Mutex
+	start time.Time
+	drawn time.Time
+}
+
+// NewProgressBar tạo ProgressBar ghi ra w với nhãn label
+func NewProgressBar(w io.Writer, label string) *ProgressBar {
+	return &ProgressBar{w: w, label: label, Width: 30}
+}
+
+// Update vẽ tiến độ done/total, có dạng func(done, total int) của WithProgress
+func (b *ProgressBar) Update(done, total int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.start.IsZero() {
+		b.start = now
+	}
+	final := done >= total
+	if !final && now.Sub(b.drawn) < 100*time.Millisecond {
+		return
+	}
+	b.drawn = now
+
+	fmt.Fprint(b.w, "\r"+b.render(done, total, now.Sub(b.start)))
+	if final {
+		fmt.Fprintln(b.w)
+	}
+}
+
+// render tạo dòng progress, ví dụ: upload [=========>          ] 42/100 12.5/s ETA 5s
+func (b *ProgressBar) render(done, total int, elapsed time.Duration) string {
+	width := max(b.Width, 1)
+	filled := width
+	if total > 0 {
+		filled = min(width*done/total, width)
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+
+	line := fmt.Sprintf("%s [%s] %d/%d", b.label, bar, done, total)
+	if elapsed <= 0 || done == 0 {
+		return line
+	}
+	rate := float64(done) / elapsed.Seconds()
+	line += fmt.Sprintf(" %.1f/s", rate)
+	if done < total {
+		eta := time.Duration(float64(total-done) / rate * float64(time.Second))
+		line += fmt.Sprintf(" ETA %s", eta.Round(time.Second))
+	}
+	return line
+}
+
+// ProgressPromise là Promise báo tiến độ kiểu P (phần trăm, bước, ...) trong lúc còn pending
+type ProgressPromise[T, P any] struct {
+	*Promise[T]
+
+	// emit giữ thứ tự gọi các handler giữa report và OnProgress
+	emit sync.Mutex
+
+	mu       sync.Mutex
+	handlers []func(P)
+	ch       chan P
+	last     P
+	reported bool
+	closed   bool
+}
+
+// NewProgressPromise giống NewPromise, fn nhận report để báo tiến độ cho caller qua
+// OnProgress hoặc Progress; report sau khi Promise settle bị bỏ qua
+//
+//	p := promise2.NewProgressPromise(func(report func(float64)) (int, error) {
+//		for i, row := range rows {
+//			import(row)
+//			report(float64(i+1) / float64(len(rows)))
+//		}
+//		return len(rows), nil
+//	})
+//	p.OnProgress(func(pct float64) { log.Printf("%.0f%%", pct*100) })
+func NewProgressPromise[T, P any](fn func(report func(P)) (T, error), opts ...Option) *ProgressPromise[T, P] {
+	cfg := newPromiseConfig(opts)
+	p := &ProgressPromise[T, P]{Promise: newPromise[T]()}
+	p.node.setOp("NewProgressPromise")
+	applyPromiseConfig(p.Promise, cfg)
+	p.Promise.onSettle(p.closeProgress)
+
+	go runPromise(p.Promise, cfg, func() (T, error) { return fn(p.report) })
+
+	return p
+}
+
+// OnProgress đăng ký fn nhận mọi tiến độ báo sau đó, và tiến độ gần nhất nếu đã có
+// fn được gọi tuần tự trên goroutine gọi report, không được gọi OnProgress bên trong fn
+func (p *ProgressPromise[T, P]) OnProgress(fn func(P)) *ProgressPromise[T, P] {
+	p.emit.Lock()
+	defer p.emit.Unlock()
+
+	p.mu.Lock()
+	p.handlers = append(p.handlers, fn)
+	last, reported := p.last, p.reported
+	p.mu.Unlock()
+
+	if reported {
+		fn(last)
+	}
+	return p
+}
+
+// Progress trả về channel nhận tiến độ, được đóng khi Promise settle
+// Channel chỉ giữ giá trị mới nhất: caller đọc chậm bỏ lỡ giá trị cũ chứ không làm fn bị chặn
+func (p *ProgressPromise[T, P]) Progress() <-chan P {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch == nil {
+		p.ch = make(chan P, 1)
+		if p.reported {
+			p.ch <- p.last
+		}
+		if p.closed {
+			close(p.ch)
+		}
+	}
+	return p.ch
+}
+
+// report lưu tiến độ v, gửi vào channel của Progress và gọi các handler của OnProgress
+func (p *ProgressPromise[T, P]) report(v P) {
+	p.emit.Lock()
+	defer p.emit.Unlock()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.last, p.reported = v, true
+	if p.ch != nil {
+		// Bỏ giá trị chưa được đọc để channel luôn giữ tiến độ mới nhất
+		select {
+		case <-p.ch:
+		default:
+		}
+		p.ch <- v
+	}
+	handlers := p.handlers
+	p.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn(v)
+	}
+}
+
+// closeProgress đóng channel của Progress khi Promise settle
+func (p *ProgressPromise[T, P]) closeProgress() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	if p.ch != nil {
+		close(p.ch)
+	}
+}
@@ -0,0 +1,157 @@
+package promise2bench
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phucps89/go-promise2"
+)
+
+// PoolFactory tạo pool mới cho mỗi lần chạy benchmark
+type PoolFactory func() *promise2.WorkerPool[struct{}]
+
+// Report là kết quả một lần chạy workload
+// Latency được đo từ lúc submit đến khi promise settle (gồm cả thời gian chờ trong queue)
+type Report struct {
+	Tasks      int
+	Failed     int
+	Elapsed    time.Duration
+	Throughput float64 // task/giây
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+// Run submit tasks task của workload vào pool rồi chờ tất cả settle
+// Cùng seed cho cùng chuỗi task; pool không bị đóng sau khi chạy
+func Run(ctx context.Context, pool *promise2.WorkerPool[struct{}], w Workload, tasks int, seed int64) (Report, error) {
+	rng := rand.New(rand.NewSource(seed))
+	latencies := make([]time.Duration, tasks)
+	errs := make([]error, tasks)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for i := 0; i < tasks; i++ {
+		task := w.Next(i, rng)
+
+		submitted := time.Now()
+		p := pool.SubmitCtx(ctx, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, sleep(ctx, task.Work)
+		})
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = p.Await(ctx)
+			latencies[i] = time.Since(submitted)
+		}(i)
+
+		if task.Gap > 0 {
+			if err := sleep(ctx, task.Gap); err != nil {
+				wg.Wait()
+				return Report{}, err
+			}
+		}
+	}
+
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return Report{}, err
+	}
+
+	report := summarize(latencies, time.Since(start))
+	for _, err := range errs {
+		if err != nil {
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// Benchmark chạy b.N task của workload trên pool mới tạo từ newPool
+// và report thêm các metric p50/p99 latency và throughput
+func Benchmark(b *testing.B, newPool PoolFactory, w Workload) {
+	b.Helper()
+
+	pool := newPool()
+	defer pool.Close()
+
+	b.ResetTimer()
+	report, err := Run(context.Background(), pool, w, b.N, 1)
+	b.StopTimer()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportMetric(float64(report.P50.Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(report.P99.Nanoseconds()), "p99-ns")
+	b.ReportMetric(report.Throughput, "tasks/s")
+	if report.Failed > 0 {
+		b.ReportMetric(float64(report.Failed), "failed")
+	}
+}
+
+// Compare chạy cùng workload trên từng cấu hình pool dưới dạng sub-benchmark
+// Các sub-benchmark được chạy theo thứ tự tên để kết quả dễ so sánh giữa các lần chạy
+func Compare(b *testing.B, configs map[string]PoolFactory, w Workload) {
+	b.Helper()
+
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		newPool := configs[name]
+		b.Run(name, func(b *testing.B) {
+			Benchmark(b, newPool, w)
+		})
+	}
+}
+
+// summarize tính percentile và throughput từ latency của từng task
+func summarize(latencies []time.Duration, elapsed time.Duration) Report {
+	report := Report{Tasks: len(latencies), Elapsed: elapsed}
+	if len(latencies) == 0 {
+		return report
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	percentile := func(q float64) time.Duration {
+		return sorted[int(q*float64(len(sorted)-1))]
+	}
+	report.P50 = percentile(0.50)
+	report.P90 = percentile(0.90)
+	report.P99 = percentile(0.99)
+	report.Max = sorted[len(sorted)-1]
+	if elapsed > 0 {
+		report.Throughput = float64(len(latencies)) / elapsed.Seconds()
+	}
+	return report
+}
+
+// sleep chờ d hoặc đến khi ctx kết thúc
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,65 @@
+package promise2bench
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/phucps89/go-promise2"
+)
+
+// TestWorkloads kiểm tra các workload sinh task đúng phân bố và lặp lại được theo seed
+func TestWorkloads(t *testing.T) {
+	uniform := &Uniform{Min: time.Millisecond, Max: 2 * time.Millisecond}
+	heavy := &HeavyTailed{Median: time.Millisecond, Max: 100 * time.Millisecond}
+
+	a, b := rand.New(rand.NewSource(7)), rand.New(rand.NewSource(7))
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		task := uniform.Next(i, a)
+		if task.Work < time.Millisecond || task.Work > 2*time.Millisecond {
+			t.Fatalf("uniform work %s out of range", task.Work)
+		}
+		if again := uniform.Next(i, b); again != task {
+			t.Fatalf("expected same task for same seed, got %v and %v", task, again)
+		}
+		if work := heavy.Next(i, rng).Work; work <= 0 || work > 100*time.Millisecond {
+			t.Fatalf("heavy-tailed work %s out of range", work)
+		}
+	}
+
+	bursty := &Bursty{Burst: 3, Idle: time.Millisecond}
+	for i := 0; i < 6; i++ {
+		gap := bursty.Next(i, rng).Gap
+		if (i%3 == 2) != (gap == time.Millisecond) {
+			t.Fatalf("task %d: unexpected gap %s", i, gap)
+		}
+	}
+}
+
+// TestRun kiểm tra Run chờ mọi task và tính report
+func TestRun(t *testing.T) {
+	pool := promise2.NewWorkerPool[struct{}](4)
+	defer pool.Close()
+
+	report, err := Run(context.Background(), pool, &Uniform{Max: time.Millisecond}, 50, 1)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if report.Tasks != 50 || report.Failed != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.P50 > report.P99 || report.P99 > report.Max || report.Throughput <= 0 {
+		t.Fatalf("inconsistent percentiles %+v", report)
+	}
+}
+
+func BenchmarkPools(b *testing.B) {
+	Compare(b, map[string]PoolFactory{
+		"fifo": func() *promise2.WorkerPool[struct{}] { return promise2.NewWorkerPool[struct{}](8) },
+		"lifo": func() *promise2.WorkerPool[struct{}] {
+			return promise2.NewWorkerPool[struct{}](8, promise2.WithSchedulingOrder(promise2.OrderLIFO))
+		},
+	}, &HeavyTailed{Median: 100 * time.Microsecond, Max: 5 * time.Millisecond})
+}
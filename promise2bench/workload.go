@@ -0,0 +1,88 @@
+// Package promise2bench cung cấp các workload mẫu (uniform, bursty, heavy-tailed)
+// và helper benchmark để so sánh các cấu hình WorkerPool một cách nhất quán.
+//
+//	func BenchmarkPools(b *testing.B) {
+//		promise2bench.Compare(b, map[string]promise2bench.PoolFactory{
+//			"fifo": func() *promise2.WorkerPool[struct{}] { return promise2.NewWorkerPool[struct{}](8) },
+//			"lifo": func() *promise2.WorkerPool[struct{}] {
+//				return promise2.NewWorkerPool[struct{}](8, promise2.WithSchedulingOrder(promise2.OrderLIFO))
+//			},
+//		}, &promise2bench.HeavyTailed{Median: time.Millisecond, Max: 50 * time.Millisecond})
+//	}
+package promise2bench
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Task mô tả một task trong workload
+// Work là thời gian task chạy; Gap là thời gian chờ trước khi submit task tiếp theo
+type Task struct {
+	Work time.Duration
+	Gap  time.Duration
+}
+
+// Workload sinh chuỗi task cho benchmark
+// i là thứ tự task (bắt đầu từ 0); rng được seed cố định nên cùng seed cho cùng chuỗi task
+type Workload interface {
+	Next(i int, rng *rand.Rand) Task
+}
+
+// Uniform sinh task có thời gian chạy phân bố đều trong [Min, Max], submit liên tục
+type Uniform struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Next implement Workload
+func (u *Uniform) Next(i int, rng *rand.Rand) Task {
+	work := u.Min
+	if u.Max > u.Min {
+		work += time.Duration(rng.Int63n(int64(u.Max-u.Min) + 1))
+	}
+	return Task{Work: work}
+}
+
+// Bursty submit từng đợt Burst task liền nhau, sau mỗi đợt nghỉ Idle
+// Mỗi task chạy trong Work
+type Bursty struct {
+	Burst int
+	Idle  time.Duration
+	Work  time.Duration
+}
+
+// Next implement Workload
+func (b *Bursty) Next(i int, rng *rand.Rand) Task {
+	task := Task{Work: b.Work}
+	if b.Burst <= 1 || (i+1)%b.Burst == 0 {
+		task.Gap = b.Idle
+	}
+	return task
+}
+
+// HeavyTailed sinh thời gian chạy theo phân phối Pareto: đa số task gần Median,
+// số ít task chạy rất lâu (bị giới hạn bởi Max nếu Max > 0)
+// Alpha càng nhỏ đuôi càng dài, mặc định 1.5
+type HeavyTailed struct {
+	Median time.Duration
+	Alpha  float64
+	Max    time.Duration
+}
+
+// Next implement Workload
+func (h *HeavyTailed) Next(i int, rng *rand.Rand) Task {
+	alpha := h.Alpha
+	if alpha <= 0 {
+		alpha = 1.5
+	}
+
+	// scale sao cho median của Pareto bằng Median
+	scale := float64(h.Median) / math.Pow(2, 1/alpha)
+	work := time.Duration(scale / math.Pow(1-rng.Float64(), 1/alpha))
+	if h.Max > 0 && work > h.Max {
+		work = h.Max
+	}
+	return Task{Work: work}
+}
@@ -0,0 +1,107 @@
+// Package promise2test cung cấp helper kiểm thử dạng property-based cho code dùng promise:
+// chạy một kịch bản nhiều lần (nên chạy kèm -race) với nhiễu lập lịch ngẫu nhiên
+// (yield/sleep chèn vào giữa các bước) và in seed khi thất bại để tái hiện lỗi.
+//
+//	func TestAllKeepsOrder(t *testing.T) {
+//		promise2test.Check(t, 200, func(t testing.TB, pert *promise2test.Perturber) {
+//			a := promise2.NewPromise(promise2test.Wrap(pert, func() (int, error) { return 1, nil }))
+//			b := promise2.NewPromise(promise2test.Wrap(pert, func() (int, error) { return 2, nil }))
+//			vals, err := promise2.All(context.Background(), a, b).Await(context.Background())
+//			if err != nil || vals[0] != 1 || vals[1] != 2 {
+//				t.Fatalf("unexpected %v, %v", vals, err)
+//			}
+//		})
+//	}
+//
+// Lỗi in ra seed; chạy lại với PROMISE2_SEED=<seed> để lặp lại đúng chuỗi nhiễu đó.
+package promise2test
+
+import (
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// SeedEnv là biến môi trường dùng để chạy lại kịch bản với một seed cố định
+const SeedEnv = "PROMISE2_SEED"
+
+// Perturber chèn nhiễu lập lịch ngẫu nhiên (theo seed) vào các điểm Yield
+// An toàn khi gọi đồng thời từ nhiều goroutine
+type Perturber struct {
+	seed int64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewPerturber tạo Perturber với seed cho trước
+func NewPerturber(seed int64) *Perturber {
+	return &Perturber{seed: seed, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Seed trả về seed của Perturber
+func (p *Perturber) Seed() int64 {
+	return p.seed
+}
+
+// Yield có thể không làm gì, nhường CPU (runtime.Gosched) hoặc sleep vài chục micro giây
+// Gọi Yield ở các điểm nhạy cảm về thứ tự để làm lộ race và lỗi phụ thuộc thứ tự
+// Perturber nil không làm gì
+func (p *Perturber) Yield() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	roll := p.rng.Intn(10)
+	pause := time.Duration(p.rng.Intn(50)) * time.Microsecond
+	p.mu.Unlock()
+
+	switch {
+	case roll < 4:
+	case roll < 8:
+		runtime.Gosched()
+	default:
+		time.Sleep(pause)
+	}
+}
+
+// Wrap bọc fn để Yield trước và sau khi chạy
+func Wrap[T any](p *Perturber, fn func() (T, error)) func() (T, error) {
+	return func() (T, error) {
+		p.Yield()
+		val, err := fn()
+		p.Yield()
+		return val, err
+	}
+}
+
+// Check chạy scenario runs lần, mỗi lần với một Perturber có seed khác nhau
+// Dừng ở lần thất bại đầu tiên và log seed để chạy lại qua biến môi trường SeedEnv;
+// nếu SeedEnv được đặt, scenario chỉ chạy một lần với seed đó
+func Check(t *testing.T, runs int, scenario func(t testing.TB, pert *Perturber)) {
+	t.Helper()
+
+	base := time.Now().UnixNano()
+	if env := os.Getenv(SeedEnv); env != "" {
+		seed, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			t.Fatalf("invalid %s %q: %v", SeedEnv, env, err)
+		}
+		base, runs = seed, 1
+	}
+
+	for i := 0; i < runs; i++ {
+		seed := base + int64(i)
+		ok := t.Run("seed="+strconv.FormatInt(seed, 10), func(t *testing.T) {
+			scenario(t, NewPerturber(seed))
+		})
+		if !ok {
+			t.Fatalf("scenario failed on run %d with seed %d (rerun with %s=%d)", i+1, seed, SeedEnv, seed)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package promise2test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/phucps89/go-promise2"
+)
+
+// TestCheckAllKeepsOrder chạy All dưới nhiễu lập lịch, kết quả phải giữ đúng thứ tự input
+func TestCheckAllKeepsOrder(t *testing.T) {
+	Check(t, 50, func(t testing.TB, pert *Perturber) {
+		promises := make([]*promise2.Promise[int], 5)
+		for i := range promises {
+			i := i
+			promises[i] = promise2.NewPromise(Wrap(pert, func() (int, error) { return i, nil }))
+		}
+
+		vals, err := promise2.All(context.Background(), promises...).Await(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for i, v := range vals {
+			if v != i {
+				t.Fatalf("expected %d at index %d, got %d", i, i, v)
+			}
+		}
+	})
+}
+
+// TestPerturberDeterministic kiểm tra cùng seed cho cùng chuỗi nhiễu
+func TestPerturberDeterministic(t *testing.T) {
+	a, b := NewPerturber(42), NewPerturber(42)
+	for i := 0; i < 20; i++ {
+		if a.rng.Int63() != b.rng.Int63() {
+			t.Fatal("expected identical sequences for identical seeds")
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package promise2test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phucps89/go-promise2"
+)
+
+// ErrSimDeadlock được trả về bởi Sim.Run khi còn task chờ promise mà không còn gì chạy được
+var ErrSimDeadlock = errors.New("simulation deadlock")
+
+// simGrace là thời gian thực chờ promise do thư viện settle (All, Then, ...) trước khi
+// coi như không còn task nào chạy được và tua đồng hồ giả
+const simGrace = 20 * time.Millisecond
+
+// simEpoch là thời điểm bắt đầu của đồng hồ giả
+var simEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Sim là scheduler mô phỏng có seed: mỗi lúc chỉ một task chạy, thứ tự chạy giữa các điểm
+// Yield/Sleep/Await do seed quyết định và thời gian là đồng hồ giả chỉ tiến khi mọi task đang
+// ngủ, nên cả chương trình promise/pool chạy lại được đúng lịch với cùng seed (kiểu FoundationDB)
+//
+//	sim := promise2test.NewSim(seed)
+//	a := promise2test.Promise(sim, func() (int, error) { sim.Sleep(time.Second); return 1, nil })
+//	b := promise2test.Promise(sim, func() (int, error) { sim.Yield(); return 2, nil })
+//	sim.Go(func() {
+//		vals, err := promise2test.Await(sim, promise2.All(context.Background(), a, b))
+//		...
+//	})
+//	if err := sim.Run(); err != nil { ... }
+//
+// Lịch chỉ lặp lại được khi công việc chạy qua Sim (Go, Promise) và chỉ chờ bằng Yield,
+// Sleep, Await; time.Sleep, timer thật và channel ngoài Sim nằm ngoài quyền điều khiển
+type Sim struct {
+	seed int64
+	rng  *rand.Rand
+
+	mu       sync.Mutex
+	now      time.Time
+	nextID   int
+	runnable []*simTask
+	sleeping []*simTask
+	awaiting []*simTask
+	current  *simTask
+	schedule []int
+	failure  error
+
+	yielded chan struct{}
+}
+
+// simTask là một task của Sim, chạy trên goroutine riêng nhưng chỉ khi được đánh thức
+type simTask struct {
+	id    int
+	wake  chan struct{}
+	until time.Time
+	done  <-chan struct{}
+}
+
+// NewSim tạo Sim với seed cho trước
+func NewSim(seed int64) *Sim {
+	return &Sim{
+		seed:    seed,
+		rng:     rand.New(rand.NewSource(seed)),
+		now:     simEpoch,
+		yielded: make(chan struct{}),
+	}
+}
+
+// Seed trả về seed của Sim
+func (s *Sim) Seed() int64 {
+	return s.seed
+}
+
+// Now trả về thời gian của đồng hồ giả
+func (s *Sim) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.now
+}
+
+// Schedule trả về id các task theo thứ tự được chạy (task đầu tiên có id 1)
+// Cùng seed và cùng chương trình cho cùng Schedule
+func (s *Sim) Schedule() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]int(nil), s.schedule...)
+}
+
+// Go thêm một task chạy fn, gọi trước Run hoặc từ trong task khác
+func (s *Sim) Go(fn func()) {
+	s.mu.Lock()
+	s.nextID++
+	t := &simTask{id: s.nextID, wake: make(chan struct{})}
+	s.runnable = append(s.runnable, t)
+	s.mu.Unlock()
+
+	go func() {
+		<-t.wake
+		defer func() {
+			if r := recover(); r != nil {
+				s.fail(fmt.Errorf("task %d panicked: %v", t.id, r))
+			}
+			s.yielded <- struct{}{}
+		}()
+		fn()
+	}()
+}
+
+// Yield nhường lượt cho task khác do seed chọn, chỉ gọi từ trong task của Sim
+func (s *Sim) Yield() {
+	s.park(func(t *simTask) { s.runnable = append(s.runnable, t) })
+}
+
+// Sleep dừng task hiện tại d theo đồng hồ giả, chỉ gọi từ trong task của Sim
+func (s *Sim) Sleep(d time.Duration) {
+	s.park(func(t *simTask) {
+		t.until = s.now.Add(d)
+		s.sleeping = append(s.sleeping, t)
+	})
+}
+
+// Promise tạo Promise có fn chạy như một task của Sim
+func Promise[T any](s *Sim, fn func() (T, error)) *promise2.Promise[T] {
+	var resolve func(T)
+	var reject func(error)
+	ready := make(chan struct{})
+	p := promise2.NewPromiseWithExecutor(func(res func(T), rej func(error)) {
+		resolve, reject = res, rej
+		close(ready)
+	})
+	<-ready
+
+	s.Go(func() {
+		val, err := fn()
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(val)
+	})
+	return p
+}
+
+// Await chờ p trong task của Sim: task nhường lượt cho đến khi p settle
+func Await[T any](s *Sim, p *promise2.Promise[T]) (T, error) {
+	done := p.Done()
+	select {
+	case <-done:
+	default:
+		s.park(func(t *simTask) {
+			t.done = done
+			s.awaiting = append(s.awaiting, t)
+		})
+	}
+	return p.Await(context.Background())
+}
+
+// Run chạy các task đến khi tất cả xong
+// Trả về lỗi (kèm seed) nếu một task panic, hoặc ErrSimDeadlock nếu còn task chờ promise
+// không bao giờ settle
+func (s *Sim) Run() error {
+	for {
+		s.mu.Lock()
+		if s.failure != nil {
+			s.mu.Unlock()
+			return s.failure
+		}
+
+		if len(s.runnable) > 0 {
+			i := s.rng.Intn(len(s.runnable))
+			t := s.runnable[i]
+			s.runnable = append(s.runnable[:i], s.runnable[i+1:]...)
+			s.current = t
+			s.schedule = append(s.schedule, t.id)
+			s.mu.Unlock()
+
+			t.wake <- struct{}{}
+			<-s.yielded
+			continue
+		}
+		s.mu.Unlock()
+
+		if s.waitAwaiting() {
+			continue
+		}
+
+		s.mu.Lock()
+		switch {
+		case len(s.sleeping) > 0:
+			s.advance()
+			s.mu.Unlock()
+		case len(s.awaiting) > 0:
+			n := len(s.awaiting)
+			s.mu.Unlock()
+			return fmt.Errorf("%w: %d task(s) awaiting promises that never settle (seed %d)", ErrSimDeadlock, n, s.seed)
+		default:
+			s.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// park đưa task hiện tại về trạng thái chờ bằng enqueue rồi trả lượt cho Run
+func (s *Sim) park(enqueue func(t *simTask)) {
+	s.mu.Lock()
+	t := s.current
+	if t == nil {
+		s.mu.Unlock()
+		panic("promise2test: Sim.Yield/Sleep/Await called outside a simulated task")
+	}
+	s.current = nil
+	enqueue(t)
+	s.mu.Unlock()
+
+	s.yielded <- struct{}{}
+	<-t.wake
+}
+
+// waitAwaiting chờ tối đa simGrace cho promise mà các task đang Await, chuyển các task
+// có promise đã settle sang runnable theo thứ tự id; trả về true nếu có task được chuyển
+func (s *Sim) waitAwaiting() bool {
+	deadline := time.Now().Add(simGrace)
+	for {
+		// Nhường CPU vài lần để các goroutine của thư viện settle cùng lúc được thấy cùng nhau
+		for i := 0; i < 8; i++ {
+			runtime.Gosched()
+		}
+
+		s.mu.Lock()
+		pending := s.awaiting[:0]
+		moved := false
+		for _, t := range s.awaiting {
+			select {
+			case <-t.done:
+				t.done = nil
+				s.runnable = append(s.runnable, t)
+				moved = true
+			default:
+				pending = append(pending, t)
+			}
+		}
+		s.awaiting = pending
+		empty := len(pending) == 0
+		s.mu.Unlock()
+
+		if moved {
+			s.mu.Lock()
+			sort.Slice(s.runnable, func(i, j int) bool { return s.runnable[i].id < s.runnable[j].id })
+			s.mu.Unlock()
+			return true
+		}
+		if empty || time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Microsecond)
+	}
+}
+
+// advance tua đồng hồ giả tới task ngủ sớm nhất và đánh thức mọi task đã tới hạn
+func (s *Sim) advance() {
+	sort.SliceStable(s.sleeping, func(i, j int) bool {
+		if !s.sleeping[i].until.Equal(s.sleeping[j].until) {
+			return s.sleeping[i].until.Before(s.sleeping[j].until)
+		}
+		return s.sleeping[i].id < s.sleeping[j].id
+	})
+
+	s.now = s.sleeping[0].until
+	i := 0
+	for i < len(s.sleeping) && !s.sleeping[i].until.After(s.now) {
+		s.runnable = append(s.runnable, s.sleeping[i])
+		i++
+	}
+	s.sleeping = s.sleeping[i:]
+}
+
+// fail ghi nhận lỗi đầu tiên của mô phỏng
+func (s *Sim) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failure == nil {
+		s.failure = fmt.Errorf("%w (seed %d)", err, s.seed)
+	}
+}
+
+// Simulate chạy scenario runs lần, mỗi lần với một Sim có seed khác nhau, giống Check
+// scenario tạo task trên sim, gọi sim.Run và kiểm tra kết quả
+func Simulate(t *testing.T, runs int, scenario func(t testing.TB, sim *Sim)) {
+	t.Helper()
+
+	base := time.Now().UnixNano()
+	if env := os.Getenv(SeedEnv); env != "" {
+		seed, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			t.Fatalf("invalid %s %q: %v", SeedEnv, env, err)
+		}
+		base, runs = seed, 1
+	}
+
+	for i := 0; i < runs; i++ {
+		seed := base + int64(i)
+		ok := t.Run("seed="+strconv.FormatInt(seed, 10), func(t *testing.T) {
+			scenario(t, NewSim(seed))
+		})
+		if !ok {
+			t.Fatalf("simulation failed on run %d with seed %d (rerun with %s=%d)", i+1, seed, SeedEnv, seed)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package promise2test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/phucps89/go-promise2"
+)
+
+// simProgram chạy một chương trình nhỏ trên sim và trả về thứ tự các bước
+func simProgram(sim *Sim) ([]string, error) {
+	var order []string
+	for i := 0; i < 3; i++ {
+		i := i
+		sim.Go(func() {
+			for step := 0; step < 3; step++ {
+				order = append(order, fmt.Sprintf("%d.%d", i, step))
+				sim.Yield()
+			}
+		})
+	}
+	err := sim.Run()
+	return order, err
+}
+
+// TestSimDeterministic kiểm tra cùng seed cho cùng lịch chạy
+func TestSimDeterministic(t *testing.T) {
+	a, errA := simProgram(NewSim(7))
+	b, errB := simProgram(NewSim(7))
+	if errA != nil || errB != nil {
+		t.Fatal(errA, errB)
+	}
+	if fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Fatalf("expected identical schedules, got %v and %v", a, b)
+	}
+}
+
+// TestSimulateFakeClock chạy All trên promise của Sim với đồng hồ giả
+func TestSimulateFakeClock(t *testing.T) {
+	Simulate(t, 20, func(t testing.TB, sim *Sim) {
+		slow := Promise(sim, func() (int, error) {
+			sim.Sleep(time.Hour)
+			return 1, nil
+		})
+		fast := Promise(sim, func() (int, error) {
+			sim.Sleep(time.Minute)
+			return 2, nil
+		})
+
+		var vals []int
+		var err error
+		var finished time.Time
+		sim.Go(func() {
+			vals, err = Await(sim, promise2.All(context.Background(), slow, fast))
+			finished = sim.Now()
+		})
+
+		if runErr := sim.Run(); runErr != nil {
+			t.Fatal(runErr)
+		}
+		if err != nil || fmt.Sprint(vals) != "[1 2]" {
+			t.Fatalf("unexpected %v, %v", vals, err)
+		}
+		if elapsed := finished.Sub(simEpoch); elapsed != time.Hour {
+			t.Fatalf("expected fake clock at 1h, got %v", elapsed)
+		}
+	})
+}
+
+// TestSimDeadlock kiểm tra Run báo deadlock khi task chờ promise không bao giờ settle
+func TestSimDeadlock(t *testing.T) {
+	sim := NewSim(1)
+	never := promise2.NewPromiseWithExecutor(func(resolve func(int), reject func(error)) {})
+	sim.Go(func() { Await(sim, never) })
+
+	if err := sim.Run(); !errors.Is(err, ErrSimDeadlock) {
+		t.Fatalf("expected ErrSimDeadlock, got %v", err)
+	}
+}
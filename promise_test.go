@@ -1,10 +1,26 @@
 package promise2
 
 import (
+	"bytes"
 	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/phucps89/go-promise2/backoff"
 )
 
 // TestNewPromise kiểm tra tạo promise cơ bản
@@ -331,7 +347,3956 @@ func TestContextCancellation(t *testing.T) {
 	})
 
 	_, err := promise.Await(ctx)
-	if err != context.Canceled {
+	if !errors.Is(err, context.Canceled) {
 		t.Fatalf("expected context.Canceled, got %v", err)
 	}
 }
+
+// TestWorkerPoolSubmitAffinity kiểm tra cùng key luôn chạy trên cùng worker
+func TestWorkerPoolSubmitAffinity(t *testing.T) {
+	pool := NewWorkerPool[int](4)
+	defer pool.Close()
+
+	for _, key := range []string{"user-1", "user-2", "tenant-a"} {
+		w := pool.workerFor(key)
+		if w < 0 || w >= 4 {
+			t.Fatalf("worker %d out of range for key %q", w, key)
+		}
+		if pool.workerFor(key) != w {
+			t.Fatalf("key %q routed to different workers", key)
+		}
+	}
+
+	// Tasks cùng key chạy tuần tự trên một worker nên không bao giờ chồng nhau
+	var running, maxRunning int32
+	promises := make([]*Promise[int], 10)
+	for i := range promises {
+		i := i
+		promises[i] = pool.SubmitAffinity("same-key", func() (int, error) {
+			if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, n)
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return i, nil
+		})
+	}
+
+	results, err := All(context.Background(), promises...).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, r := range results {
+		if r != i {
+			t.Fatalf("expected %d at index %d, got %d", i, i, r)
+		}
+	}
+	if maxRunning != 1 {
+		t.Fatalf("expected tasks with same key to run serially, max concurrent %d", maxRunning)
+	}
+}
+
+// TestFileQueueStoreRecover kiểm tra chạy lại task chưa ack sau khi restart
+func TestFileQueueStoreRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+
+	// Giả lập process crash: task đã journal nhưng chưa kịp ack
+	store, err := OpenFileQueueStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.Append(TaskDescriptor{Name: "double", Payload: []byte("21")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store.Close()
+
+	store, err = OpenFileQueueStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	pool := NewWorkerPool[int](2, WithQueueStore(store))
+	defer pool.Close()
+	pool.Register("double", func(payload []byte) (int, error) {
+		n, err := strconv.Atoi(string(payload))
+		return n * 2, err
+	})
+
+	promises, err := pool.Recover()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(promises) != 1 {
+		t.Fatalf("expected 1 recovered task, got %d", len(promises))
+	}
+
+	result, err := promises[0].Await(context.Background())
+	if err != nil || result != 42 {
+		t.Fatalf("expected 42, got %d (%v)", result, err)
+	}
+
+	records, _ := store.Recover()
+	if len(records) != 0 {
+		t.Fatalf("expected recovered task to be acked, %d pending", len(records))
+	}
+
+	_, err = pool.SubmitTask(TaskDescriptor{Name: "missing"}).Await(context.Background())
+	if !errors.Is(err, ErrUnknownTask) {
+		t.Fatalf("expected ErrUnknownTask, got %v", err)
+	}
+}
+
+// TestWorkerPoolExecBackend kiểm tra task được chuyển cho ExecBackend
+func TestWorkerPoolExecBackend(t *testing.T) {
+	backend := NewHandlerBackend()
+	backend.Handle("greet", func(ctx context.Context, payload []byte) (any, error) {
+		return "hello " + string(payload), nil
+	})
+
+	pool := NewWorkerPool[string](2, WithExecBackend(backend))
+	defer pool.Close()
+
+	result, err := pool.SubmitTask(TaskDescriptor{Name: "greet", Payload: []byte("gopher")}).
+		Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "hello gopher" {
+		t.Fatalf("expected 'hello gopher', got '%s'", result)
+	}
+
+	_, err = pool.SubmitTask(TaskDescriptor{Name: "missing"}).Await(context.Background())
+	if !errors.Is(err, ErrUnknownTask) {
+		t.Fatalf("expected ErrUnknownTask, got %v", err)
+	}
+
+	// ctx của Exec bị hủy bởi CancelAll như task SubmitCtx
+	started := make(chan struct{})
+	backend.Handle("block", func(ctx context.Context, payload []byte) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	blocked := pool.SubmitTask(TaskDescriptor{Name: "block"})
+	<-started
+	pool.CancelAll()
+	if _, err := blocked.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected backend ctx to be cancelled, got %v", err)
+	}
+}
+
+// TestResultEncoding kiểm tra serialize Result và PromiseStatus giữ được errors.Is
+func TestResultEncoding(t *testing.T) {
+	data, err := json.Marshal(Result[int]{Err: fmt.Errorf("worker 3: %w", ErrPoolClosed)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded Result[int]
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(decoded.Err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed after decode, got %v", decoded.Err)
+	}
+	if decoded.Err.Error() != "worker 3: worker pool is closed" {
+		t.Fatalf("unexpected message: %s", decoded.Err)
+	}
+
+	var buf bytes.Buffer
+	status := PromiseStatus[string]{Status: StatusFulfilled, Value: "cached"}
+	if err := gob.NewEncoder(&buf).Encode(status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decodedStatus PromiseStatus[string]
+	if err := gob.NewDecoder(&buf).Decode(&decodedStatus); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decodedStatus != status {
+		t.Fatalf("expected %+v, got %+v", status, decodedStatus)
+	}
+
+	result, err := FromResult(decodedStatus.Result()).Await(context.Background())
+	if err != nil || result != "cached" {
+		t.Fatalf("expected 'cached', got '%s' (%v)", result, err)
+	}
+}
+
+// TestResultClass kiểm tra phân loại lỗi trên Result
+func TestResultClass(t *testing.T) {
+	cases := []struct {
+		err   error
+		class ErrorClass
+	}{
+		{nil, ClassNone},
+		{fmt.Errorf("boom"), ClassError},
+		{fmt.Errorf("fetch: %w", context.DeadlineExceeded), ClassTimeout},
+		{context.Canceled, ClassCancelled},
+		{ErrTaskPanicked, ClassPanic},
+		{ErrPoolClosed, ClassPoolClosed},
+	}
+
+	for _, c := range cases {
+		r := Result[int]{Err: c.err}
+		if r.Class() != c.class {
+			t.Fatalf("expected class %s for %v, got %s", c.class, c.err, r.Class())
+		}
+	}
+
+	if !(Result[int]{Err: context.DeadlineExceeded}).IsTimeout() {
+		t.Fatal("expected IsTimeout")
+	}
+	if !(Result[int]{Err: context.Canceled}).IsCancelled() {
+		t.Fatal("expected IsCancelled")
+	}
+
+	pool := NewWorkerPool[int](1)
+	defer pool.Close()
+	val, err := pool.Submit(func() (int, error) { panic("boom") }).Await(context.Background())
+	if !(Result[int]{Value: val, Err: err}).IsPanic() {
+		t.Fatalf("expected panic class, got %v", err)
+	}
+}
+
+// TestAwaitErrorLabel kiểm tra lỗi Await chứa label và thời gian chờ
+func TestAwaitErrorLabel(t *testing.T) {
+	skipInLean(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	promise := NewPromise(func() (int, error) {
+		time.Sleep(200 * time.Millisecond)
+		return 1, nil
+	}).SetLabel("load-config")
+
+	_, err := promise.Await(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	var awaitErr *AwaitError
+	if !errors.As(err, &awaitErr) {
+		t.Fatalf("expected *AwaitError, got %T", err)
+	}
+	if awaitErr.Label != "load-config" || awaitErr.Pending < 10*time.Millisecond {
+		t.Fatalf("unexpected AwaitError: %+v", awaitErr)
+	}
+	if !strings.HasPrefix(err.Error(), `promise "load-config" timed out after `) {
+		t.Fatalf("unexpected message: %s", err)
+	}
+}
+
+// TestPromiseDebugString kiểm tra debug dump của chain
+func TestPromiseDebugString(t *testing.T) {
+	skipInLean(t)
+
+	EnableDebug(true)
+	defer EnableDebug(false)
+
+	block := make(chan struct{})
+	promise := NewPromise(func() (int, error) {
+		return 1, nil
+	}).SetLabel("load").Map(func(val int) (int, error) {
+		<-block
+		return val + 1, nil
+	})
+
+	// Đợi bước đầu settle để trạng thái ổn định
+	time.Sleep(10 * time.Millisecond)
+
+	lines := strings.Split(strings.TrimSpace(promise.DebugString()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 chain steps, got:\n%s", promise.DebugString())
+	}
+	if !strings.Contains(lines[0], `NewPromise "load" fulfilled in`) {
+		t.Fatalf("unexpected root step: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "Map pending for") {
+		t.Fatalf("unexpected leaf step: %s", lines[1])
+	}
+
+	close(block)
+	_, _ = promise.Await(context.Background())
+
+	var buf bytes.Buffer
+	if err := promise.DumpChain(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Map fulfilled in") {
+		t.Fatalf("expected settled leaf, got:\n%s", buf.String())
+	}
+}
+
+// TestExportGraph kiểm tra xuất đồ thị fan-in dạng DOT
+func TestExportGraph(t *testing.T) {
+	skipInLean(t)
+
+	EnableDebug(true)
+	defer EnableDebug(false)
+
+	p1 := NewPromise(func() (int, error) { return 1, nil }).SetLabel("left")
+	p2 := NewPromise(func() (int, error) { return 2, nil }).SetLabel("right")
+	all := All(context.Background(), p1, p2)
+	_, _ = all.Await(context.Background())
+
+	var buf bytes.Buffer
+	if err := ExportGraph(&buf, all); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "digraph promises {") {
+		t.Fatalf("expected DOT graph, got:\n%s", dot)
+	}
+	for _, want := range []string{
+		fmt.Sprintf("n%d -> n%d;", p1.node.id, all.node.id),
+		fmt.Sprintf("n%d -> n%d;", p2.node.id, all.node.id),
+		`\"left\"`,
+		"All fulfilled",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("expected %q in graph:\n%s", want, dot)
+		}
+	}
+}
+
+// TestAnyPromise kiểm tra gom Promise khác kiểu qua AnyPromise
+func TestAnyPromise(t *testing.T) {
+	promises := []AnyPromise{
+		NewPromise(func() (int, error) { return 1, nil }),
+		NewPromise(func() (string, error) { return "two", nil }),
+		NewPromise(func() (bool, error) { return false, fmt.Errorf("three") }),
+	}
+
+	for _, p := range promises {
+		<-p.Done()
+	}
+
+	v0, _ := promises[0].AwaitAny(context.Background())
+	v1, _ := promises[1].AwaitAny(context.Background())
+	if v0 != 1 || v1 != "two" {
+		t.Fatalf("unexpected values: %v, %v", v0, v1)
+	}
+
+	if promises[0].StateAny() != StatusFulfilled || promises[2].StateAny() != StatusRejected {
+		t.Fatalf("unexpected states: %s, %s", promises[0].StateAny(), promises[2].StateAny())
+	}
+}
+
+// TestWaitAllWaitAny kiểm tra chờ tập promises khác kiểu
+func TestWaitAllWaitAny(t *testing.T) {
+	p1 := NewPromise(func() (int, error) { return 1, nil })
+	p2 := NewPromise(func() (string, error) {
+		time.Sleep(20 * time.Millisecond)
+		return "slow", nil
+	})
+
+	if err := WaitAll(context.Background(), p1, p2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p2.State() != StatusFulfilled {
+		t.Fatal("expected all promises settled after WaitAll")
+	}
+
+	failing := NewPromise(func() (bool, error) { return false, fmt.Errorf("boom") })
+	if err := WaitAll(context.Background(), p1, failing); err == nil {
+		t.Fatal("expected error from rejected promise")
+	}
+
+	slow := NewPromise(func() (int, error) {
+		time.Sleep(100 * time.Millisecond)
+		return 0, nil
+	})
+	fast := NewPromise(func() (string, error) { return "fast", nil })
+	idx, err := WaitAny(context.Background(), slow, fast)
+	if err != nil || idx != 1 {
+		t.Fatalf("expected index 1, got %d (%v)", idx, err)
+	}
+}
+
+// TestGovernorAcrossPools kiểm tra Governor giới hạn tổng concurrency của nhiều pool
+func TestGovernorAcrossPools(t *testing.T) {
+	gov := NewGovernor(2)
+	poolA := NewWorkerPool[int](3, WithGovernor(gov))
+	defer poolA.Close()
+	poolB := NewWorkerPool[int](3, WithGovernor(gov))
+	defer poolB.Close()
+
+	var running, maxRunning int32
+	task := func() (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&maxRunning)
+			if n <= old || atomic.CompareAndSwapInt32(&maxRunning, old, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return 0, nil
+	}
+
+	var promises []*Promise[int]
+	for i := 0; i < 6; i++ {
+		promises = append(promises, poolA.Submit(task), poolB.Submit(task))
+	}
+	if _, err := All(context.Background(), promises...).Await(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if maxRunning > 2 {
+		t.Fatalf("expected at most 2 concurrent tasks, got %d", maxRunning)
+	}
+	if gov.InUse() != 0 {
+		t.Fatalf("expected all slots released, %d in use", gov.InUse())
+	}
+}
+
+// TestAIMDController kiểm tra pool giảm concurrency khi task lỗi và tăng lại khi khỏe
+func TestAIMDController(t *testing.T) {
+	pool := NewWorkerPool[int](8, WithConcurrencyController(&AIMDController{}))
+	defer pool.Close()
+
+	if limit := pool.Stats().ConcurrencyLimit; limit != 8 {
+		t.Fatalf("expected initial limit 8, got %d", limit)
+	}
+
+	for i := 0; i < 4; i++ {
+		_, _ = pool.Submit(func() (int, error) { return 0, fmt.Errorf("overloaded") }).Await(context.Background())
+	}
+	if limit := pool.Stats().ConcurrencyLimit; limit != 1 {
+		t.Fatalf("expected limit to back off to 1, got %d", limit)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, _ = pool.Submit(func() (int, error) { return 0, nil }).Await(context.Background())
+	}
+	if limit := pool.Stats().ConcurrencyLimit; limit != 4 {
+		t.Fatalf("expected limit to grow to 4, got %d", limit)
+	}
+}
+
+// TestWorkerPoolLoadShedder kiểm tra shedder từ chối task khi queue đầy
+func TestWorkerPoolLoadShedder(t *testing.T) {
+	block := make(chan struct{})
+	pool := NewWorkerPool[int](1, WithLoadShedder(func(s PoolStats) bool {
+		return s.QueueSize >= 1
+	}))
+	defer pool.Close()
+	defer close(block)
+
+	pool.Submit(func() (int, error) {
+		<-block
+		return 1, nil
+	})
+	// Đợi task đầu được worker nhận để queue trống
+	for pool.Stats().QueueSize != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	pool.Submit(func() (int, error) { return 2, nil })
+	for pool.Stats().QueueSize != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := pool.Submit(func() (int, error) { return 3, nil }).Await(context.Background())
+	if !errors.Is(err, ErrShedded) {
+		t.Fatalf("expected ErrShedded, got %v", err)
+	}
+	if ClassOf(err) != ClassShed {
+		t.Fatalf("expected shed class, got %s", ClassOf(err))
+	}
+}
+
+// TestWorkerPoolDeadlockDetection kiểm tra task Await task khác trên cùng pool đã bão hòa
+func TestWorkerPoolDeadlockDetection(t *testing.T) {
+	pool := NewWorkerPool[int](1, WithDeadlockDetection(20*time.Millisecond, nil))
+	defer pool.Close()
+
+	outer := pool.Submit(func() (int, error) {
+		inner := pool.Submit(func() (int, error) { return 1, nil }).SetLabel("inner")
+		return inner.Await(context.Background())
+	})
+
+	_, err := outer.Await(context.Background())
+	if !errors.Is(err, ErrPossibleDeadlock) {
+		t.Fatalf("expected ErrPossibleDeadlock, got %v", err)
+	}
+
+	var reported atomic.Int32
+	warnPool := NewWorkerPool[int](2, WithDeadlockDetection(10*time.Millisecond, func(info DeadlockInfo) {
+		reported.Add(1)
+	}))
+	defer warnPool.Close()
+
+	result, err := warnPool.Submit(func() (int, error) {
+		time.Sleep(30 * time.Millisecond)
+		return 7, nil
+	}).Await(context.Background())
+	if err != nil || result != 7 {
+		t.Fatalf("expected 7, got %d (%v)", result, err)
+	}
+	if reported.Load() != 0 {
+		t.Fatal("expected no deadlock report for a running task")
+	}
+}
+
+// TestWorkerPoolSubmitNested kiểm tra nested submit không deadlock khi pool bão hòa
+func TestWorkerPoolSubmitNested(t *testing.T) {
+	pool := NewWorkerPool[int](1)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result, err := pool.Submit(func() (int, error) {
+		inner := pool.SubmitNested(func() (int, error) { return 21, nil })
+		val, err := inner.Await(ctx)
+		return val * 2, err
+	}).Await(ctx)
+	if err != nil || result != 42 {
+		t.Fatalf("expected 42, got %d (%v)", result, err)
+	}
+}
+
+// TestRecoverPolicies kiểm tra các cách xử lý panic của pool và Promise
+func TestRecoverPolicies(t *testing.T) {
+	handled := NewWorkerPool[int](1, WithRecover(HandlePanic(func(r any) error {
+		return fmt.Errorf("recovered: %v", r)
+	})))
+	defer handled.Close()
+
+	_, err := handled.Submit(func() (int, error) { panic("boom") }).Await(context.Background())
+	if err == nil || err.Error() != "recovered: boom" {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+
+	repanic := NewWorkerPool[int](1, WithRecover(RepanicOnAwait()))
+	defer repanic.Close()
+
+	promise := repanic.Submit(func() (int, error) { panic("boom") })
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected re-panic with 'boom', got %v", r)
+			}
+		}()
+		_, _ = promise.Await(context.Background())
+		t.Fatal("expected Await to panic")
+	}()
+
+	_, err = NewPromise(func() (int, error) { panic("boom") }, WithPromiseRecover(RejectOnPanic())).
+		Await(context.Background())
+	if !errors.Is(err, ErrTaskPanicked) {
+		t.Fatalf("expected ErrTaskPanicked, got %v", err)
+	}
+}
+
+// TestExecutorNeverSettled kiểm tra phát hiện executor không settle
+func TestExecutorNeverSettled(t *testing.T) {
+	_, err := NewPromiseWithExecutor[int](func(resolve func(int), reject func(error)) {
+		// Quên gọi resolve
+	}, WithUnsettledCheck(nil)).Await(context.Background())
+	if !errors.Is(err, ErrExecutorNeverSettled) {
+		t.Fatalf("expected ErrExecutorNeverSettled, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "promise_test.go") {
+		t.Fatalf("expected caller location in error, got %v", err)
+	}
+
+	reported := make(chan UnsettledInfo, 1)
+	promise := NewPromiseWithExecutor[int](func(resolve func(int), reject func(error)) {},
+		WithUnsettledCheck(func(info UnsettledInfo) { reported <- info }))
+
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Fatal("expected unsettled hook to be called")
+	}
+	if promise.State() != StatusPending {
+		t.Fatalf("expected promise to stay pending, got %s", promise.State())
+	}
+}
+
+// TestDoubleSettleHook kiểm tra báo lỗi khi executor settle hai lần
+func TestDoubleSettleHook(t *testing.T) {
+	reported := make(chan DoubleSettleInfo, 1)
+	promise := NewPromiseWithExecutor[int](func(resolve func(int), reject func(error)) {
+		resolve(1)
+		reject(fmt.Errorf("late"))
+	}, WithDoubleSettleHook(func(info DoubleSettleInfo) { reported <- info }))
+
+	result, err := promise.Await(context.Background())
+	if err != nil || result != 1 {
+		t.Fatalf("expected first resolve to win, got %d (%v)", result, err)
+	}
+
+	select {
+	case info := <-reported:
+		if info.First.Kind != "resolve" || info.Second.Kind != "reject" {
+			t.Fatalf("unexpected kinds: %+v", info)
+		}
+		if !strings.Contains(info.Second.Caller, "promise_test.go") {
+			t.Fatalf("expected call site in test file, got %s", info.Second.Caller)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected double settle hook to be called")
+	}
+}
+
+// TestSequenceTasksWith kiểm tra timeout từng bước và continue-on-error
+func TestSequenceTasksWith(t *testing.T) {
+	var mu sync.Mutex
+	var order []int
+	step := func(i int, d time.Duration, err error) func(context.Context) (int, error) {
+		return func(ctx context.Context) (int, error) {
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			select {
+			case <-time.After(d):
+				return i, err
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+	}
+
+	statuses, err := SequenceTasksWith(context.Background(), SequenceOptions{
+		StepTimeout:     20 * time.Millisecond,
+		ContinueOnError: true,
+	},
+		step(0, 0, nil),
+		step(1, time.Second, nil),
+		step(2, 0, fmt.Errorf("non-fatal")),
+		step(3, 0, nil),
+	).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Status{StatusFulfilled, StatusRejected, StatusRejected, StatusFulfilled}
+	for i, s := range statuses {
+		if s.Status != expected[i] {
+			t.Fatalf("step %d: expected %s, got %s (%v)", i, expected[i], s.Status, s.Err)
+		}
+	}
+	if !errors.Is(statuses[1].Err, context.DeadlineExceeded) {
+		t.Fatalf("expected step timeout, got %v", statuses[1].Err)
+	}
+
+	mu.Lock()
+	order = nil
+	mu.Unlock()
+	_, err = SequenceTasks(context.Background(),
+		step(0, 0, nil),
+		step(1, 0, fmt.Errorf("fatal")),
+		step(2, 0, nil),
+	).Await(context.Background())
+	if err == nil || len(order) != 2 {
+		t.Fatalf("expected to stop after failing step, ran %v (%v)", order, err)
+	}
+}
+
+// TestWorkflow kiểm tra state machine rẽ nhánh theo lỗi và trace các bước
+func TestWorkflow(t *testing.T) {
+	errNotFound := fmt.Errorf("not found")
+
+	wf := NewWorkflow("lookup")
+	AddStep(wf, "lookup", func(ctx context.Context, id int) (string, error) {
+		if id == 0 {
+			return "", errNotFound
+		}
+		return fmt.Sprintf("user-%d", id), nil
+	}, func(name string, err error) string {
+		if errors.Is(err, errNotFound) {
+			return "create"
+		}
+		return "greet"
+	})
+	AddStep(wf, "create", func(ctx context.Context, _ string) (string, error) {
+		return "new-user", nil
+	}, Next[string]("greet"))
+	AddStep(wf, "greet", func(ctx context.Context, name string) (string, error) {
+		return "hello " + name, nil
+	}, nil)
+
+	result, err := wf.Run(context.Background(), 0).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Output != "hello new-user" {
+		t.Fatalf("unexpected output: %v", result.Output)
+	}
+
+	var steps []string
+	for _, s := range result.Trace {
+		steps = append(steps, s.Step)
+	}
+	if strings.Join(steps, ",") != "lookup,create,greet" {
+		t.Fatalf("unexpected trace: %v", steps)
+	}
+
+	_, err = wf.Run(context.Background(), "wrong type").Await(context.Background())
+	var wfErr *WorkflowError
+	if !errors.As(err, &wfErr) || wfErr.Step != "lookup" {
+		t.Fatalf("expected WorkflowError at lookup, got %v", err)
+	}
+}
+
+// TestRecursiveMap kiểm tra duyệt cây song song với giới hạn concurrency
+func TestRecursiveMap(t *testing.T) {
+	tree := map[int][]int{
+		1: {2, 3},
+		2: {4, 5},
+		3: {6},
+	}
+
+	var running, maxRunning atomic.Int32
+	results, err := RecursiveMap(context.Background(), 1,
+		func(n int) []int { return tree[n] },
+		func(ctx context.Context, n int) (int, error) {
+			cur := running.Add(1)
+			for {
+				old := maxRunning.Load()
+				if cur <= old || maxRunning.CompareAndSwap(old, cur) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return n * 10, nil
+		}, 2).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 6 || results[0] != 10 {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	sum := 0
+	for _, r := range results {
+		sum += r
+	}
+	if sum != 210 {
+		t.Fatalf("expected sum 210, got %d (%v)", sum, results)
+	}
+	if maxRunning.Load() > 2 {
+		t.Fatalf("expected at most 2 concurrent nodes, got %d", maxRunning.Load())
+	}
+
+	_, err = RecursiveMap(context.Background(), 1,
+		func(n int) []int { return tree[n] },
+		func(ctx context.Context, n int) (int, error) {
+			if n == 5 {
+				return 0, fmt.Errorf("node 5 failed")
+			}
+			return n, nil
+		}, 4).Await(context.Background())
+	if err == nil {
+		t.Fatal("expected error from failing node")
+	}
+}
+
+// TestProcessFiles kiểm tra xử lý các file khớp glob trên pool
+func TestProcessFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.log":          "1",
+		"b.txt":          "skip",
+		"nested/c.log":   "22",
+		"nested/x/d.log": "333",
+	} {
+		full := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool := NewWorkerPool[int](2)
+	defer pool.Close()
+
+	sizes, err := ProcessFiles(context.Background(), pool, filepath.Join(dir, "**", "*.log"),
+		func(ctx context.Context, path string) (int, error) {
+			data, err := os.ReadFile(path)
+			return len(data), err
+		}).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []int{1, 2, 3}
+	if fmt.Sprint(sizes) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, sizes)
+	}
+
+	top, err := ProcessFiles(context.Background(), pool, filepath.Join(dir, "*.log"),
+		func(ctx context.Context, path string) (int, error) { return 1, nil }).Await(context.Background())
+	if err != nil || len(top) != 1 {
+		t.Fatalf("expected 1 top-level file, got %v (%v)", top, err)
+	}
+}
+
+// TestProcessLines kiểm tra xử lý dòng song song giữ thứ tự
+func TestProcessLines(t *testing.T) {
+	var input strings.Builder
+	for i := 1; i <= 50; i++ {
+		fmt.Fprintf(&input, "%d\n", i)
+	}
+
+	results, err := ProcessLines(context.Background(), strings.NewReader(input.String()),
+		func(ctx context.Context, line string) (int, error) {
+			n, err := strconv.Atoi(line)
+			// Dòng nhỏ xử lý lâu hơn để kết quả về không theo thứ tự
+			time.Sleep(time.Duration(50-n) * 50 * time.Microsecond)
+			return n * n, err
+		}, LineOptions{Concurrency: 4, Ordered: true}).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 50 {
+		t.Fatalf("expected 50 results, got %d", len(results))
+	}
+	for i, r := range results {
+		if r != (i+1)*(i+1) {
+			t.Fatalf("expected %d at index %d, got %d", (i+1)*(i+1), i, r)
+		}
+	}
+
+	_, err = ProcessLines(context.Background(), strings.NewReader("1\nx\n3\n"),
+		func(ctx context.Context, line string) (int, error) {
+			return strconv.Atoi(line)
+		}, LineOptions{Concurrency: 2}).Await(context.Background())
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Fatalf("expected error on line 2, got %v", err)
+	}
+}
+
+// TestProduce kiểm tra producer-consumer có backpressure qua pool
+func TestProduce(t *testing.T) {
+	pool := NewWorkerPool[struct{}](2)
+	defer pool.Close()
+
+	var sum atomic.Int64
+	count, err := Produce(context.Background(), func(ctx context.Context, emit func(int) error) error {
+		for i := 1; i <= 100; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, pool, func(ctx context.Context, item int) error {
+		sum.Add(int64(item))
+		return nil
+	}).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 100 || sum.Load() != 5050 {
+		t.Fatalf("expected 100 items summing 5050, got %d items, sum %d", count, sum.Load())
+	}
+
+	_, err = Produce(context.Background(), func(ctx context.Context, emit func(int) error) error {
+		for i := 0; ; i++ {
+			if err := emit(i); err != nil {
+				return err
+			}
+		}
+	}, pool, func(ctx context.Context, item int) error {
+		if item == 10 {
+			return fmt.Errorf("bad item %d", item)
+		}
+		return nil
+	}).Await(context.Background())
+	if err == nil || err.Error() != "bad item 10" {
+		t.Fatalf("expected consumer error, got %v", err)
+	}
+}
+
+// TestRetryIdempotencyKey kiểm tra retry giữ nguyên idempotency key qua các lần thử
+func TestRetryIdempotencyKey(t *testing.T) {
+	pool := NewWorkerPool[string](2, WithRetry(backoff.Limit(backoff.Constant{Delay: time.Millisecond}, 3)))
+	defer pool.Close()
+
+	var mu sync.Mutex
+	keys := make(map[string]int)
+	val, err := pool.SubmitCtx(context.Background(), func(ctx context.Context) (string, error) {
+		key, ok := IdempotencyKey(ctx)
+		if !ok {
+			return "", errors.New("missing idempotency key")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		keys[key]++
+		if keys[key] < 3 {
+			return "", errors.New("transient")
+		}
+		return key, nil
+	}).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 1 || keys[val] != 3 {
+		t.Fatalf("expected one key used for 3 attempts, got %v", keys)
+	}
+
+	var attempts atomic.Int32
+	ctx := WithIdempotencyKey(context.Background(), "order-42")
+	_, err = Retry(ctx, backoff.Limit(backoff.Constant{}, 2), func(ctx context.Context) (int, error) {
+		attempts.Add(1)
+		if key, _ := IdempotencyKey(ctx); key != "order-42" {
+			return 0, fmt.Errorf("unexpected key %q", key)
+		}
+		return 0, errors.New("always fails")
+	}).Await(context.Background())
+	if err == nil || err.Error() != "always fails" || attempts.Load() != 2 {
+		t.Fatalf("expected 2 failed attempts, got %d attempts, err %v", attempts.Load(), err)
+	}
+}
+
+// TestSubmitKeyedResultCache kiểm tra SubmitKeyed gộp task cùng key và cache kết quả trong TTL
+func TestSubmitKeyedResultCache(t *testing.T) {
+	pool := NewWorkerPool[int](2, WithResultCache(50*time.Millisecond))
+	defer pool.Close()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (int, error) {
+		<-release
+		return int(calls.Add(1)), nil
+	}
+
+	p1 := pool.SubmitKeyed("hot", fn)
+	p2 := pool.SubmitKeyed("hot", fn)
+	if p1 != p2 {
+		t.Fatal("expected in-flight task to be shared")
+	}
+	close(release)
+	if val, _ := p1.Await(context.Background()); val != 1 {
+		t.Fatalf("expected 1, got %d", val)
+	}
+
+	// Chờ kết quả được đưa vào cache
+	time.Sleep(10 * time.Millisecond)
+	if val, _ := pool.SubmitKeyed("hot", fn).Await(context.Background()); val != 1 || calls.Load() != 1 {
+		t.Fatalf("expected cached result, got %d after %d calls", val, calls.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if val, _ := pool.SubmitKeyed("hot", fn).Await(context.Background()); val != 2 {
+		t.Fatalf("expected re-execution after TTL, got %d", val)
+	}
+}
+
+// TestWithReserved kiểm tra task của class được dành riêng vẫn chạy khi pool bận hết
+func TestWithReserved(t *testing.T) {
+	pool := NewWorkerPool[string](1, WithReserved("health", 1))
+	defer pool.Close()
+
+	block := make(chan struct{})
+	defer close(block)
+	for i := 0; i < 4; i++ {
+		pool.Submit(func() (string, error) {
+			<-block
+			return "busy", nil
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	val, err := pool.SubmitClass("health", func() (string, error) {
+		return "ok", nil
+	}).Await(ctx)
+	if err != nil || val != "ok" {
+		t.Fatalf("expected reserved task to run, got %q, %v", val, err)
+	}
+}
+
+// TestContextPriority kiểm tra SubmitCtx sắp xếp queue theo độ ưu tiên trong ctx
+func TestContextPriority(t *testing.T) {
+	pool := NewWorkerPool[int](1, WithContextPriority())
+	defer pool.Close()
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() (int, error) {
+		close(started)
+		<-block
+		return 0, nil
+	})
+	<-started
+
+	var mu sync.Mutex
+	var order []int
+	var promises []*Promise[int]
+	for _, prio := range []int{1, 5, 3} {
+		prio := prio
+		ctx := WithPriority(context.Background(), prio)
+		promises = append(promises, pool.SubmitCtx(ctx, func(ctx context.Context) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, prio)
+			return prio, nil
+		}))
+	}
+	// Chờ các task vào priorityQueue trước khi giải phóng worker
+	for pool.Stats().QueueSize < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+
+	for _, p := range promises {
+		if _, err := p.Await(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if fmt.Sprint(order) != "[5 3 1]" {
+		t.Fatalf("expected priority order [5 3 1], got %v", order)
+	}
+}
+
+// TestConnect kiểm tra pipeline hai pool với buffer giới hạn và Close drain cả chain
+func TestConnect(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	conduit := Connect(NewWorkerPool[int](2), func(v int) (string, error) {
+		defer inFlight.Add(-1)
+		time.Sleep(time.Millisecond)
+		return strconv.Itoa(v * 2), nil
+	}, NewWorkerPool[string](1), 3)
+
+	var promises []*Promise[string]
+	for i := 0; i < 10; i++ {
+		i := i
+		promises = append(promises, conduit.Submit(context.Background(), func() (int, error) {
+			if n := inFlight.Add(1); n > maxInFlight.Load() {
+				maxInFlight.Store(n)
+			}
+			return i, nil
+		}))
+	}
+
+	if err := conduit.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	for i, p := range promises {
+		if p.State() != StatusFulfilled {
+			t.Fatalf("expected promise %d to be settled after Close, got %v", i, p.State())
+		}
+		if val, _ := p.Await(context.Background()); val != strconv.Itoa(i*2) {
+			t.Fatalf("expected %d, got %s", i*2, val)
+		}
+	}
+	if maxInFlight.Load() > 3 {
+		t.Fatalf("expected at most 3 items in pipeline, got %d", maxInFlight.Load())
+	}
+
+	if _, err := conduit.Submit(context.Background(), func() (int, error) { return 0, nil }).Await(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed after Close, got %v", err)
+	}
+}
+
+// TestCancelQueuedAndAll kiểm tra hủy task đang chờ và ctx của task đang chạy
+func TestCancelQueuedAndAll(t *testing.T) {
+	pool := NewWorkerPool[int](1)
+	defer pool.Close()
+
+	started := make(chan struct{})
+	running := pool.SubmitCtx(context.Background(), func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+
+	var queued []*Promise[int]
+	for i := 0; i < 3; i++ {
+		queued = append(queued, pool.Submit(func() (int, error) { return 1, nil }))
+	}
+	for pool.Stats().QueueSize < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	pool.CancelQueued()
+	for i, p := range queued {
+		if _, err := p.Await(context.Background()); !errors.Is(err, ErrTaskCancelled) {
+			t.Fatalf("expected queued task %d to be cancelled, got %v", i, err)
+		}
+	}
+	if running.State() != StatusPending {
+		t.Fatal("expected running task to be unaffected by CancelQueued")
+	}
+
+	pool.CancelAll()
+	if _, err := running.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected running task ctx to be cancelled, got %v", err)
+	}
+
+	if val, err := pool.Submit(func() (int, error) { return 7, nil }).Await(context.Background()); err != nil || val != 7 {
+		t.Fatalf("expected pool to keep working after CancelAll, got %d, %v", val, err)
+	}
+}
+
+// TestSubmitterStats kiểm tra thống kê theo submitter trong Stats
+func TestSubmitterStats(t *testing.T) {
+	pool := NewWorkerPool[int](1)
+	defer pool.Close()
+
+	billing := WithSubmitter(context.Background(), "billing")
+	block := make(chan struct{})
+	var unblock sync.Once
+	defer unblock.Do(func() { close(block) })
+
+	running := pool.SubmitCtx(billing, func(ctx context.Context) (int, error) {
+		<-block
+		return 1, nil
+	})
+	for pool.Stats().Submitters["billing"].Running != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	failed := pool.SubmitCtx(billing, func(ctx context.Context) (int, error) {
+		return 0, errors.New("boom")
+	})
+	search := pool.SubmitCtx(WithSubmitter(context.Background(), "search"), func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+	for pool.Stats().QueueSize < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	stats := pool.Stats().Submitters
+	if stats["billing"].Queued != 1 || stats["search"].Queued != 1 {
+		t.Fatalf("unexpected queued counts: %+v", stats)
+	}
+
+	unblock.Do(func() { close(block) })
+	running.Await(context.Background())
+	failed.Await(context.Background())
+	search.Await(context.Background())
+
+	// Bộ đếm được cập nhật trước khi Promise settle
+	billingStats := pool.Stats().Submitters["billing"]
+	if billingStats.Completed != 1 || billingStats.Failed != 1 || billingStats.Queued != 0 || billingStats.Running != 0 {
+		t.Fatalf("unexpected billing stats: %+v", billingStats)
+	}
+}
+
+// TestSubscribeStats kiểm tra snapshot định kỳ và channel đóng khi pool đóng
+func TestSubscribeStats(t *testing.T) {
+	pool := NewWorkerPool[int](3)
+	updates := pool.SubscribeStats(5 * time.Millisecond)
+
+	select {
+	case stats := <-updates:
+		if stats.NumWorkers != 3 {
+			t.Fatalf("expected 3 workers, got %d", stats.NumWorkers)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a stats snapshot")
+	}
+
+	pool.Close()
+	for range updates {
+	}
+
+	// interval không hợp lệ panic ngay tại chỗ gọi, không phải trong goroutine
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected SubscribeStats(0) to panic")
+		}
+	}()
+	pool.SubscribeStats(0)
+}
+
+// TestMemoryBudget kiểm tra submit chờ khi kết quả in flight vượt memory budget
+func TestMemoryBudget(t *testing.T) {
+	pool := NewWorkerPool[[]byte](2, WithMemoryBudget(100))
+	defer pool.Close()
+
+	first := pool.SubmitSized(60, func() ([]byte, error) { return make([]byte, 60), nil })
+	second := pool.SubmitSized(30, func() ([]byte, error) { return make([]byte, 30), nil })
+
+	submitted := make(chan *Promise[[]byte])
+	go func() {
+		submitted <- pool.SubmitSized(50, func() ([]byte, error) { return make([]byte, 50), nil })
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatal("expected submission to block while budget is exhausted")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Đọc kết quả đầu tiên trả lại budget cho task đang chờ
+	first.Await(context.Background())
+	select {
+	case third := <-submitted:
+		if val, err := third.Await(context.Background()); err != nil || len(val) != 50 {
+			t.Fatalf("unexpected result: %d bytes, %v", len(val), err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected submission to proceed after result was consumed")
+	}
+	second.Await(context.Background())
+}
+
+// TestShutdownReport kiểm tra Shutdown báo cáo task hoàn thành, bị bỏ và lỗi
+func TestShutdownReport(t *testing.T) {
+	pool := NewWorkerPool[int](1)
+
+	started := make(chan struct{})
+	pool.SubmitCtx(context.Background(), func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+	queued := pool.Submit(func() (int, error) { return 1, nil })
+	for pool.Stats().QueueSize < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report := pool.Shutdown(ctx)
+
+	if report.Duration < 20*time.Millisecond {
+		t.Fatalf("expected shutdown to wait for ctx, took %s", report.Duration)
+	}
+	if len(report.Errors) == 0 || !errors.Is(report.Errors[len(report.Errors)-1], context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error in report, got %v", report.Errors)
+	}
+	if _, err := queued.Await(context.Background()); !errors.Is(err, ErrTaskCancelled) {
+		t.Fatalf("expected queued task to be abandoned, got %v", err)
+	}
+	if report.Abandoned != 1 {
+		t.Fatalf("expected queued task to be reported as abandoned, got %+v", report)
+	}
+
+	// Report chỉ tính task xong trong lúc shutdown, nên giữ task tới khi Shutdown đã bắt đầu
+	pool = NewWorkerPool[int](2)
+	gate := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		pool.Submit(func() (int, error) {
+			<-gate
+			return 0, errors.New("fail")
+		})
+	}
+	// task chờ chỗ trong queue lúc Close bị reject ErrPoolClosed, nên chờ mọi task vào queue trước
+	for stats := pool.Stats(); stats.Running+stats.QueueSize < 5; stats = pool.Stats() {
+		time.Sleep(time.Millisecond)
+	}
+	reports := make(chan ShutdownReport)
+	go func() { reports <- pool.Shutdown(context.Background()) }()
+	for pool.shutdown.Load() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	close(gate)
+	report = <-reports
+	if report.Completed != 5 || len(report.Errors) != 5 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+// TestAttemptMetadata kiểm tra Attempt và FirstAttemptAt qua các lần retry
+func TestAttemptMetadata(t *testing.T) {
+	if Attempt(context.Background()) != 0 || !FirstAttemptAt(context.Background()).IsZero() {
+		t.Fatal("expected no attempt metadata outside tasks")
+	}
+
+	var first time.Time
+	val, err := Retry(context.Background(), backoff.Limit(backoff.Constant{Delay: time.Millisecond}, 3), func(ctx context.Context) (int, error) {
+		if Attempt(ctx) == 1 {
+			first = FirstAttemptAt(ctx)
+		} else if !FirstAttemptAt(ctx).Equal(first) {
+			return 0, errors.New("first attempt time changed")
+		}
+		if Attempt(ctx) < 3 {
+			return 0, errors.New("transient")
+		}
+		return Attempt(ctx), nil
+	}).Await(context.Background())
+	if err != nil || val != 3 || first.IsZero() {
+		t.Fatalf("expected success on attempt 3, got %d, %v", val, err)
+	}
+}
+
+// TestWorkerWarmup kiểm tra Ready chờ warmup của mọi worker và báo lỗi warmup
+func TestWorkerWarmup(t *testing.T) {
+	release := make(chan struct{})
+	var warmed atomic.Int32
+	pool := NewWorkerPool[int](3, WithWorkerWarmup(func(ctx context.Context, workerID int) error {
+		<-release
+		warmed.Add(1)
+		if workerID == 2 {
+			return errors.New("model not found")
+		}
+		return nil
+	}))
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Ready(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected pool not ready before warmup, got %v", err)
+	}
+
+	close(release)
+	err := pool.Ready(context.Background())
+	if warmed.Load() != 3 {
+		t.Fatalf("expected all workers warmed up, got %d", warmed.Load())
+	}
+	if err == nil || err.Error() != "warmup worker 2: model not found" {
+		t.Fatalf("expected warmup error, got %v", err)
+	}
+}
+
+// TestHealthy kiểm tra Healthy theo các HealthCheck và HealthHandler
+func TestHealthy(t *testing.T) {
+	pool := NewWorkerPool[int](1, WithHealthChecks(WorkersAlive(), QueueBelow(2)))
+
+	if err := pool.Healthy(); err != nil {
+		t.Fatalf("expected healthy pool, got %v", err)
+	}
+
+	block := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		pool.Submit(func() (int, error) {
+			<-block
+			return 0, nil
+		})
+	}
+	for pool.Stats().QueueSize < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := pool.Healthy(); !errors.Is(err, ErrUnhealthy) {
+		t.Fatalf("expected unhealthy pool, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	HealthHandler(pool).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+
+	close(block)
+	pool.Close()
+	if err := pool.Healthy(); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed after Close, got %v", err)
+	}
+}
+
+// TestAllMap kiểm tra AllMap và AllSettledMap theo key
+func TestAllMap(t *testing.T) {
+	ctx := context.Background()
+	users := map[int]*Promise[string]{
+		1: NewPromise(func() (string, error) { return "alice", nil }),
+		2: NewPromise(func() (string, error) { return "bob", nil }),
+	}
+
+	names, err := AllMap(ctx, users).Await(ctx)
+	if err != nil || len(names) != 2 || names[1] != "alice" || names[2] != "bob" {
+		t.Fatalf("unexpected AllMap result: %v, %v", names, err)
+	}
+
+	errNotFound := errors.New("not found")
+	users[3] = NewPromise(func() (string, error) { return "", errNotFound })
+	if _, err := AllMap(ctx, users).Await(ctx); !errors.Is(err, errNotFound) || !strings.Contains(err.Error(), "key 3") {
+		t.Fatalf("expected error for key 3, got %v", err)
+	}
+
+	statuses, _ := AllSettledMap(ctx, users).Await(ctx)
+	if statuses[2].Status != StatusFulfilled || statuses[3].Status != StatusRejected {
+		t.Fatalf("unexpected AllSettledMap result: %+v", statuses)
+	}
+
+	empty, err := AllMap(ctx, map[string]*Promise[int]{}).Await(ctx)
+	if err != nil || len(empty) != 0 {
+		t.Fatalf("expected empty map, got %v, %v", empty, err)
+	}
+}
+
+// TestGroupBy kiểm tra chia nhóm theo key và xử lý từng nhóm
+func TestGroupBy(t *testing.T) {
+	type order struct {
+		tenant string
+		amount int
+	}
+	orders := []order{{"a", 1}, {"b", 10}, {"a", 2}, {"c", 100}, {"b", 20}}
+
+	totals, err := GroupBy(context.Background(), orders, func(o order) string { return o.tenant },
+		func(ctx context.Context, tenant string, group []order) (int, error) {
+			sum := 0
+			for _, o := range group {
+				sum += o.amount
+			}
+			return sum, nil
+		}, 2).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(totals) != 3 || totals["a"] != 3 || totals["b"] != 30 || totals["c"] != 100 {
+		t.Fatalf("unexpected totals: %v", totals)
+	}
+
+	_, err = GroupBy(context.Background(), orders, func(o order) string { return o.tenant },
+		func(ctx context.Context, tenant string, group []order) (int, error) {
+			if tenant == "b" {
+				return 0, errors.New("tenant suspended")
+			}
+			return len(group), nil
+		}, 1).Await(context.Background())
+	if err == nil || err.Error() != "group b: tenant suspended" {
+		t.Fatalf("expected group error, got %v", err)
+	}
+}
+
+// TestTopN kiểm tra TopN chỉ giữ n kết quả tốt nhất khi promises settle
+func TestTopN(t *testing.T) {
+	source := make(chan *Promise[int])
+	go func() {
+		defer close(source)
+		for i := 0; i < 1000; i++ {
+			score := (i * 7919) % 1000
+			source <- NewPromise(func() (int, error) { return score, nil })
+		}
+	}()
+
+	top, err := TopN(context.Background(), 3, func(a, b int) bool { return a > b }, source).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(top) != "[999 998 997]" {
+		t.Fatalf("expected [999 998 997], got %v", top)
+	}
+
+	failing := make(chan *Promise[int], 2)
+	failing <- NewPromise(func() (int, error) { return 1, nil })
+	failing <- NewPromise(func() (int, error) { return 0, errors.New("scoring failed") })
+	close(failing)
+	if _, err := TopN(context.Background(), 3, func(a, b int) bool { return a > b }, failing).Await(context.Background()); err == nil {
+		t.Fatal("expected error from failing promise")
+	}
+}
+
+// TestCombinatorMetrics kiểm tra All báo số liệu qua Metrics khi ctx bật instrument
+func TestCombinatorMetrics(t *testing.T) {
+	var mu sync.Mutex
+	var reports []CombinatorStats
+	ctx := WithCombinatorMetrics(context.Background(), MetricsFunc(func(name string, stats CombinatorStats) {
+		if name != "All" {
+			t.Errorf("unexpected combinator %q", name)
+		}
+		mu.Lock()
+		reports = append(reports, stats)
+		mu.Unlock()
+	}), 5*time.Millisecond)
+
+	var promises []*Promise[int]
+	for i := 0; i < 10; i++ {
+		i := i
+		promises = append(promises, NewPromise(func() (int, error) {
+			time.Sleep(time.Duration(i) * 3 * time.Millisecond)
+			return i, nil
+		}))
+	}
+	if _, err := All(ctx, promises...).Await(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) < 2 || !reports[len(reports)-1].Final {
+		t.Fatalf("expected periodic reports ending with a final one, got %+v", reports)
+	}
+	total, maxInFlight := 0, 0
+	for _, r := range reports {
+		total += r.Completed
+		maxInFlight = max(maxInFlight, r.MaxInFlight)
+	}
+	if total != 10 || maxInFlight != 10 {
+		t.Fatalf("expected 10 completed with 10 in flight, got %d completed, max %d in flight", total, maxInFlight)
+	}
+}
+
+// TestAllErrorIndex kiểm tra lỗi của All cho biết index và label của promise lỗi
+func TestAllErrorIndex(t *testing.T) {
+	skipInLean(t)
+
+	errTimeout := errors.New("upstream timeout")
+	promises := []*Promise[int]{
+		NewPromise(func() (int, error) { return 1, nil }),
+		NewPromise(func() (int, error) { return 0, errTimeout }).SetLabel("fetch user"),
+	}
+
+	_, err := All(context.Background(), promises...).Await(context.Background())
+	if !errors.Is(err, errTimeout) {
+		t.Fatalf("expected errors.Is to match original error, got %v", err)
+	}
+	if err.Error() != `promise[1] "fetch user": upstream timeout` {
+		t.Fatalf("unexpected error message: %q", err.Error())
+	}
+
+	_, err = All(context.Background(), NewPromise(func() (int, error) { return 0, errTimeout })).Await(context.Background())
+	if err == nil || err.Error() != "promise[0]: upstream timeout" {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+}
+
+// TestSchedulingOrderLIFO kiểm tra pool LIFO chạy task mới nhất trước
+func TestSchedulingOrderLIFO(t *testing.T) {
+	pool := NewWorkerPool[int](1, WithSchedulingOrder(OrderLIFO))
+	defer pool.Close()
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() (int, error) {
+		close(started)
+		<-block
+		return 0, nil
+	})
+	<-started
+
+	var mu sync.Mutex
+	var order []int
+	var promises []*Promise[int]
+	for i := 1; i <= 3; i++ {
+		i := i
+		promises = append(promises, pool.Submit(func() (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, i)
+			return i, nil
+		}))
+	}
+	for pool.Stats().QueueSize < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+
+	for _, p := range promises {
+		p.Await(context.Background())
+	}
+	if fmt.Sprint(order) != "[3 2 1]" {
+		t.Fatalf("expected LIFO order [3 2 1], got %v", order)
+	}
+}
+
+// TestSelect kiểm tra Select gọi callback đúng kiểu của promise settle đầu tiên
+func TestSelect(t *testing.T) {
+	slow := NewPromise(func() (int, error) {
+		time.Sleep(time.Second)
+		return 1, nil
+	})
+	fast := NewPromise(func() (string, error) { return "event", nil })
+
+	var got string
+	idx, err := Select(context.Background(),
+		Case(slow, func(val int, err error) error {
+			t.Error("slow case should not be selected")
+			return nil
+		}),
+		Case(fast, func(val string, err error) error {
+			got = val
+			return err
+		}),
+	)
+	if err != nil || idx != 1 || got != "event" {
+		t.Fatalf("expected case 1 with %q, got %d, %q, %v", "event", idx, got, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	pending := NewPromiseWithExecutor[int](func(resolve func(int), reject func(error)) {})
+	if idx, err := Select(ctx, Case(pending, func(int, error) error { return nil })); idx != -1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ctx timeout, got %d, %v", idx, err)
+	}
+}
+
+// TestResultSink kiểm tra sink nhận mọi lần settle ở mức promise và pool
+func TestResultSink(t *testing.T) {
+	skipInLean(t)
+
+	var mu sync.Mutex
+	var labels []string
+	var failures int
+	sink := func(label string, dur time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		labels = append(labels, label)
+		if err != nil {
+			failures++
+		}
+	}
+
+	NewPromise(func() (int, error) { return 1, nil }, WithResultSink(sink)).Await(context.Background())
+
+	pool := NewWorkerPool[int](1, WithPoolResultSink(sink))
+	pool.Submit(func() (int, error) { return 0, errors.New("boom") }).Await(context.Background())
+	pool.Close()
+	pool.Submit(func() (int, error) { return 2, nil }).Await(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(labels) != 3 || failures != 2 {
+		t.Fatalf("expected 3 settlements with 2 failures, got %d with %d failures", len(labels), failures)
+	}
+}
+
+// TestTransientErrors kiểm tra Fallback, Any và Retry phân biệt lỗi tạm thời/vĩnh viễn
+func TestTransientErrors(t *testing.T) {
+	errNotFound := errors.New("404 not found")
+	ctx := WithTransientErrors(context.Background(), IsTransient)
+
+	// Timeout là lỗi tạm thời: chuyển sang nguồn tiếp theo
+	val, err := Fallback(ctx,
+		func(ctx context.Context) (string, error) { return "", context.DeadlineExceeded },
+		func(ctx context.Context) (string, error) { return "replica", nil },
+	).Await(context.Background())
+	if err != nil || val != "replica" {
+		t.Fatalf("expected fallback to replica, got %q, %v", val, err)
+	}
+
+	// Lỗi vĩnh viễn dừng ngay, nguồn sau không được gọi
+	_, err = Fallback(ctx,
+		func(ctx context.Context) (string, error) { return "", errNotFound },
+		func(ctx context.Context) (string, error) {
+			t.Error("second source should not be called")
+			return "", nil
+		},
+	).Await(context.Background())
+	if !errors.Is(err, errNotFound) {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+
+	// Any reject ngay với lỗi vĩnh viễn thay vì chờ promise chậm
+	slow := NewPromise(func() (int, error) {
+		time.Sleep(time.Second)
+		return 1, nil
+	})
+	start := time.Now()
+	_, err = Any(ctx, slow, NewPromise(func() (int, error) { return 0, errNotFound })).Await(context.Background())
+	if !errors.Is(err, errNotFound) || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("expected Any to fail fast on permanent error, got %v after %s", err, time.Since(start))
+	}
+
+	var attempts atomic.Int32
+	Retry(ctx, backoff.Limit(backoff.Constant{}, 5), func(ctx context.Context) (int, error) {
+		attempts.Add(1)
+		return 0, errNotFound
+	}).Await(context.Background())
+	if attempts.Load() != 1 {
+		t.Fatalf("expected permanent error not to be retried, got %d attempts", attempts.Load())
+	}
+}
+
+// TestUntil kiểm tra Until reject với ErrSignalled khi signal phát trước
+func TestUntil(t *testing.T) {
+	shutdown := make(chan struct{})
+	slow := NewPromise(func() (int, error) {
+		time.Sleep(time.Second)
+		return 1, nil
+	})
+
+	interrupted := Until(slow, shutdown)
+	close(shutdown)
+	if _, err := interrupted.Await(context.Background()); !errors.Is(err, ErrSignalled) || ClassOf(err) != ClassCancelled {
+		t.Fatalf("expected ErrSignalled, got %v", err)
+	}
+
+	fast := NewPromise(func() (int, error) { return 2, nil })
+	if val, err := Until(fast, make(chan struct{})).Await(context.Background()); err != nil || val != 2 {
+		t.Fatalf("expected 2, got %d, %v", val, err)
+	}
+}
+
+// TestRequestScope kiểm tra Close hủy và chờ mọi promise của scope
+func TestRequestScope(t *testing.T) {
+	scope := Scope(context.Background())
+
+	var finished atomic.Int32
+	var promises []*Promise[int]
+	for i := 0; i < 3; i++ {
+		promises = append(promises, Spawn(scope, func(ctx context.Context) (int, error) {
+			defer finished.Add(1)
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}))
+	}
+
+	scope.Close()
+	if finished.Load() != 3 {
+		t.Fatalf("expected all spawned work to finish before Close returns, got %d", finished.Load())
+	}
+	for _, p := range promises {
+		if _, err := p.Await(context.Background()); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected cancelled promise, got %v", err)
+		}
+	}
+
+	if _, err := Spawn(scope, func(ctx context.Context) (int, error) { return 1, nil }).Await(context.Background()); !errors.Is(err, ErrScopeClosed) {
+		t.Fatalf("expected ErrScopeClosed, got %v", err)
+	}
+}
+
+// TestRecorderReplay kiểm tra kết quả được ghi lại và phát lại mà không chạy task
+func TestRecorderReplay(t *testing.T) {
+	rec := NewRecorder()
+	var calls atomic.Int32
+	double := func(ctx context.Context, n int) (int, error) {
+		calls.Add(1)
+		if n < 0 {
+			return 0, ErrShedded
+		}
+		return n * 2, nil
+	}
+
+	recorded := Recorded(rec, "double", JSONCodec[int](), JSONCodec[int](), double)
+	if val, err := recorded(context.Background(), 21); err != nil || val != 42 {
+		t.Fatalf("expected 42, got %d, %v", val, err)
+	}
+	if _, err := recorded(context.Background(), -1); !errors.Is(err, ErrShedded) {
+		t.Fatalf("expected ErrShedded, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := rec.Save(&buf); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	replay, err := LoadRecording(&buf)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	replayed := Recorded(replay, "double", JSONCodec[int](), JSONCodec[int](), double)
+	if val, err := replayed(context.Background(), 21); err != nil || val != 42 {
+		t.Fatalf("expected replayed 42, got %d, %v", val, err)
+	}
+	if _, err := replayed(context.Background(), -1); !errors.Is(err, ErrShedded) {
+		t.Fatalf("expected replayed ErrShedded, got %v", err)
+	}
+	if _, err := replayed(context.Background(), 21); !errors.Is(err, ErrNotRecorded) {
+		t.Fatalf("expected ErrNotRecorded, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected task to run only while recording, got %d calls", calls.Load())
+	}
+}
+
+// TestDeadlineWarnings kiểm tra hook được gọi khi deadline ngắn hơn thời gian dự kiến
+func TestDeadlineWarnings(t *testing.T) {
+	warnings := make(chan DeadlineWarning, 2)
+	pool := NewWorkerPool[int](1, WithDeadlineWarnings(time.Second, func(w DeadlineWarning) {
+		warnings <- w
+	}))
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(WithSubmitter(context.Background(), "api"), 100*time.Millisecond)
+	defer cancel()
+	noop := func(ctx context.Context) (int, error) { return 1, nil }
+
+	if _, err := pool.SubmitCtx(ctx, noop).Await(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case w := <-warnings:
+		if w.Expected != time.Second || w.Remaining > 100*time.Millisecond || w.Submitter != "api" {
+			t.Fatalf("unexpected warning %+v", w)
+		}
+	default:
+		t.Fatal("expected deadline warning")
+	}
+
+	// hint riêng của task ngắn hơn deadline thì không cảnh báo
+	if _, err := pool.SubmitCtx(WithExpectedDuration(ctx, time.Millisecond), noop).Await(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warning %+v", <-warnings)
+	}
+}
+
+// TestResultTransform kiểm tra transform được áp dụng trước khi kết quả đến sink và caller
+func TestResultTransform(t *testing.T) {
+	var sinkErr atomic.Value
+	pool := NewWorkerPool[string](1,
+		WithResultTransform(func(r Result[string]) Result[string] {
+			if r.Err != nil {
+				return Result[string]{Err: fmt.Errorf("upstream failed: %w", ErrShedded)}
+			}
+			return Result[string]{Value: strings.Repeat("*", len(r.Value))}
+		}),
+		WithPoolResultSink(func(label string, dur time.Duration, err error) {
+			if err != nil {
+				sinkErr.Store(err.Error())
+			}
+		}),
+	)
+	defer pool.Close()
+
+	if val, err := pool.Submit(func() (string, error) { return "secret", nil }).Await(context.Background()); err != nil || val != "******" {
+		t.Fatalf("expected redacted value, got %q, %v", val, err)
+	}
+	_, err := pool.Submit(func() (string, error) { return "", errors.New("password=hunter2") }).Await(context.Background())
+	if !errors.Is(err, ErrShedded) || strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("expected normalized error, got %v", err)
+	}
+	if msg, _ := sinkErr.Load().(string); strings.Contains(msg, "hunter2") {
+		t.Fatalf("sink saw raw error %q", msg)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for mismatched transform type")
+		}
+	}()
+	NewWorkerPool[int](1, WithResultTransform(func(r Result[string]) Result[string] { return r }))
+}
+
+// TestSharedOptions kiểm tra cùng một Option dùng được cho NewPromise, pool và Submit
+func TestSharedOptions(t *testing.T) {
+	skipInLean(t)
+
+	policy := backoff.Limit(backoff.Constant{Delay: time.Millisecond}, 3)
+	var sunk atomic.Int32
+	sink := WithResultSink(func(label string, dur time.Duration, err error) { sunk.Add(1) })
+
+	var calls atomic.Int32
+	flaky := func() (int, error) {
+		if calls.Add(1) < 3 {
+			return 0, errors.New("flaky")
+		}
+		return 7, nil
+	}
+	p := NewPromise(flaky, WithLabel("flaky"), WithRetry(policy), sink)
+	if val, err := p.Await(context.Background()); err != nil || val != 7 || p.Label() != "flaky" {
+		t.Fatalf("expected 7 after retries, got %d, %v (label %q)", val, err, p.Label())
+	}
+
+	slow := func() (int, error) {
+		time.Sleep(200 * time.Millisecond)
+		return 1, nil
+	}
+	if _, err := NewPromise(slow, WithTimeout(10*time.Millisecond)).Await(context.Background()); !errors.Is(err, ErrTimeout) || ClassOf(err) != ClassTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	pool := NewWorkerPool[int](2, WithTimeout(10*time.Millisecond), sink)
+	defer pool.Close()
+
+	if _, err := pool.Submit(slow).Await(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected pool default timeout, got %v", err)
+	}
+	if val, err := pool.Submit(func() (int, error) { return 2, nil }, WithLabel("fast"), WithTimeout(time.Second)).Await(context.Background()); err != nil || val != 2 {
+		t.Fatalf("expected 2, got %d, %v", val, err)
+	}
+	ctxErr := make(chan error, 1)
+	pool.SubmitCtx(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		ctxErr <- ctx.Err()
+		return 0, ctx.Err()
+	}, WithTimeout(5*time.Millisecond))
+	if err := <-ctxErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected task ctx to expire, got %v", err)
+	}
+	if sunk.Load() < 3 {
+		t.Fatalf("expected sink to see every settlement, got %d", sunk.Load())
+	}
+}
+
+// TestCombinatorOptionsAndTracer kiểm tra combinator nhận Option qua WithOptions
+// và WithTracer mở/đóng span cho Promise, combinator và task của pool
+func TestCombinatorOptionsAndTracer(t *testing.T) {
+	skipInLean(t)
+
+	var mu sync.Mutex
+	spans := map[string]error{}
+	tracer := WithTracer(func(label string) func(err error) {
+		return func(err error) {
+			mu.Lock()
+			spans[label] = err
+			mu.Unlock()
+		}
+	})
+
+	ctx := WithOptions(context.Background(), WithLabel("load"), tracer)
+	all := All(ctx, Resolve(1), Resolve(2))
+	if vals, err := all.Await(context.Background()); err != nil || len(vals) != 2 || all.Label() != "load" {
+		t.Fatalf("expected labelled All, got %v, %v (label %q)", vals, err, all.Label())
+	}
+
+	never := NewPromiseWithExecutor[int](func(resolve func(int), reject func(error)) {})
+	ctx = WithOptions(context.Background(), WithTimeout(10*time.Millisecond))
+	if _, err := Race(ctx, never).Await(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected combinator timeout, got %v", err)
+	}
+
+	pool := NewWorkerPool[int](1, tracer)
+	defer pool.Close()
+	errFail := errors.New("fail")
+	pool.Submit(func() (int, error) { return 0, errFail }, WithLabel("task")).Await(context.Background())
+	NewPromise(func() (int, error) { return 1, nil }, WithLabel("promise"), tracer).Await(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err, ok := spans["load"]; !ok || err != nil {
+		t.Fatalf("expected span for All, got %v", spans)
+	}
+	if err, ok := spans["promise"]; !ok || err != nil {
+		t.Fatalf("expected span for NewPromise, got %v", spans)
+	}
+	if !errors.Is(spans["task"], errFail) {
+		t.Fatalf("expected span of task to end with its error, got %v", spans)
+	}
+}
+
+// TestAllSettledTasks kiểm tra giới hạn concurrency, thứ tự kết quả và task chưa chạy khi ctx hủy
+func TestAllSettledTasks(t *testing.T) {
+	var running, peak atomic.Int32
+	task := func(i int) func(ctx context.Context) (int, error) {
+		return func(ctx context.Context) (int, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			switch i {
+			case 1:
+				return 0, errors.New("boom")
+			case 2:
+				panic("oops")
+			}
+			return i * 10, nil
+		}
+	}
+
+	statuses, err := AllSettledTasks(context.Background(), 2, task(0), task(1), task(2), task(3), task(4)).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if peak.Load() > 2 {
+		t.Fatalf("expected at most 2 concurrent tasks, got %d", peak.Load())
+	}
+	if statuses[0].Value != 0 || statuses[3].Value != 30 || statuses[4].Value != 40 || statuses[4].Status != StatusFulfilled {
+		t.Fatalf("unexpected statuses %+v", statuses)
+	}
+	if statuses[1].Status != StatusRejected || !errors.Is(statuses[2].Err, ErrTaskPanicked) {
+		t.Fatalf("expected rejected statuses, got %+v %+v", statuses[1], statuses[2])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var started atomic.Int32
+	blocking := func(ctx context.Context) (int, error) {
+		started.Add(1)
+		cancel()
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	statuses, _ = AllSettledTasks(ctx, 1, blocking, blocking, blocking).Await(context.Background())
+	if started.Load() != 1 || !errors.Is(statuses[2].Err, context.Canceled) {
+		t.Fatalf("expected only first task to start, got %d started, %+v", started.Load(), statuses)
+	}
+}
+
+// TestBoundary kiểm tra lỗi bị chặn tại Boundary và các bước sau vẫn chạy
+func TestBoundary(t *testing.T) {
+	failing := NewPromise(func() (int, error) { return 0, errors.New("fetch failed") })
+
+	var collected []error
+	contained := Boundary(failing).Map(func(r Result[int]) (Result[int], error) {
+		if r.Err != nil {
+			collected = append(collected, r.Err)
+			return Result[int]{Value: -1}, nil
+		}
+		return r, nil
+	})
+
+	r, err := contained.Await(context.Background())
+	if err != nil || r.Value != -1 || len(collected) != 1 {
+		t.Fatalf("expected contained error, got %+v, %v (collected %v)", r, err, collected)
+	}
+
+	if _, err := Rethrow(Boundary(failing)).Await(context.Background()); err == nil || err.Error() != "fetch failed" {
+		t.Fatalf("expected rethrown error, got %v", err)
+	}
+	if val, err := Rethrow(contained).Await(context.Background()); err != nil || val != -1 {
+		t.Fatalf("expected -1, got %d, %v", val, err)
+	}
+}
+
+// TestResultHelpers kiểm tra Map/AndThen/OrElse/Unwrap trên Result
+func TestResultHelpers(t *testing.T) {
+	double := func(v int) int { return v * 2 }
+	if val, err := Ok(21).Map(double).Unwrap(); err != nil || val != 42 {
+		t.Fatalf("expected 42, got %d, %v", val, err)
+	}
+
+	failed := Ok(1).AndThen(func(v int) (int, error) { return 0, errors.New("bad") })
+	if failed.Map(double).UnwrapOr(-1) != -1 {
+		t.Fatal("expected Map to skip failed result")
+	}
+	if val, err := failed.OrElse(func(error) (int, error) { return 7, nil }).Unwrap(); err != nil || val != 7 {
+		t.Fatalf("expected recovered 7, got %d, %v", val, err)
+	}
+
+	s := MapResult(Ok(5), func(v int) (string, error) { return strconv.Itoa(v), nil })
+	if s.Value != "5" || MapResult(Err[int](ErrShedded), func(v int) (string, error) { return "x", nil }).Err != ErrShedded {
+		t.Fatalf("unexpected MapResult %+v", s)
+	}
+
+	statuses, _ := AllSettled(context.Background(), FromResult(Ok(3)), NewPromise(func() (int, error) { return 0, ErrTimeout })).Await(context.Background())
+	sum := 0
+	for _, st := range statuses {
+		sum += st.Result().UnwrapOr(100)
+	}
+	if sum != 103 {
+		t.Fatalf("expected 103, got %d", sum)
+	}
+}
+
+// TestValues kiểm tra tách giá trị và lỗi từ kết quả AllSettled
+func TestValues(t *testing.T) {
+	statuses := []PromiseStatus[int]{
+		{Status: StatusFulfilled, Value: 1},
+		{Status: StatusRejected, Err: ErrShedded},
+		{Status: StatusFulfilled, Value: 3},
+	}
+
+	values, errs := Values(statuses)
+	if len(values) != 2 || values[1] != 3 || len(errs) != 1 || errs[0] != ErrShedded {
+		t.Fatalf("unexpected values %v, errors %v", values, errs)
+	}
+
+	indexed, indexedErrs := IndexedValues(statuses)
+	if indexed[2] != 3 || indexedErrs[1] != ErrShedded || len(indexed) != 2 {
+		t.Fatalf("unexpected indexed values %v, errors %v", indexed, indexedErrs)
+	}
+
+	if got := MustValues(statuses[:1]); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("unexpected MustValues %v", got)
+	}
+	defer func() {
+		var ae *AggregateError
+		if err, _ := recover().(error); !errors.As(err, &ae) || ae.Count() != 1 {
+			t.Fatalf("expected AggregateError panic, got %v", err)
+		}
+	}()
+	MustValues(statuses)
+}
+
+// TestWatchdogAncestry kiểm tra watchdog báo Promise bị kẹt kèm chain từ task gốc
+func TestWatchdogAncestry(t *testing.T) {
+	skipInLean(t)
+
+	EnableDebug(true)
+	defer EnableDebug(false)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	root := NewPromise(func() (int, error) {
+		<-release
+		return 1, nil
+	}).SetLabel("fetch")
+	stuck := root.Map(func(v int) (int, error) { return v, nil })
+
+	ancestry := stuck.Ancestry()
+	if len(ancestry) != 2 || ancestry[0].Label != "fetch" || ancestry[1].Op != "Map" {
+		t.Fatalf("unexpected ancestry %+v", ancestry)
+	}
+	if len(ancestry[1].Stack) == 0 || !strings.Contains(ancestry[1].Stack[0], "TestWatchdogAncestry") {
+		t.Fatalf("expected creation stack to point at test, got %v", ancestry[1].Stack)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reports := make(chan StuckPromise, 16)
+	StartWatchdog(ctx, 20*time.Millisecond, func(s StuckPromise) { reports <- s })
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case s := <-reports:
+			last := s.Ancestry[len(s.Ancestry)-1]
+			if last.ID != ancestry[1].ID {
+				continue
+			}
+			if len(s.Ancestry) != 2 || s.Pending < 20*time.Millisecond {
+				t.Fatalf("unexpected report %+v", s)
+			}
+			return
+		case <-deadline:
+			t.Fatal("expected watchdog report for stuck continuation")
+		}
+	}
+}
+
+// TestAdaptiveQueue kiểm tra queue tăng kích thước đến max và Stats báo peak
+func TestAdaptiveQueue(t *testing.T) {
+	pool := NewWorkerPool[int](1, WithAdaptiveQueue(8))
+	defer pool.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() (int, error) {
+		close(started)
+		<-release
+		return 0, nil
+	})
+	<-started
+
+	var promises []*Promise[int]
+	for i := 0; i < 20; i++ {
+		i := i
+		promises = append(promises, pool.Submit(func() (int, error) { return i, nil }))
+	}
+
+	for pool.Stats().QueueSize < 8 {
+		time.Sleep(time.Millisecond)
+	}
+	stats := pool.Stats()
+	if stats.QueueSize != 8 || stats.PeakQueueSize != 8 || stats.QueueCapacity != 8 {
+		t.Fatalf("expected queue capped at 8, got %+v", stats)
+	}
+
+	close(release)
+	for i, p := range promises {
+		if val, err := p.Await(context.Background()); err != nil || val != i {
+			t.Fatalf("task %d: got %d, %v", i, val, err)
+		}
+	}
+	if peak := pool.Stats().PeakQueueSize; peak != 8 {
+		t.Fatalf("expected peak 8, got %d", peak)
+	}
+}
+
+// TestAdmission kiểm tra hook admission reject task trước khi vào queue
+func TestAdmission(t *testing.T) {
+	errQuota := errors.New("tenant over quota")
+	var seen []TaskInfo
+	var mu sync.Mutex
+	pool := NewWorkerPool[int](1, WithReserved("admin", 1), WithAdmission(func(info TaskInfo) error {
+		mu.Lock()
+		seen = append(seen, info)
+		mu.Unlock()
+		if info.Submitter == "noisy" || info.Key == "admin" {
+			return errQuota
+		}
+		return nil
+	}))
+	defer pool.Close()
+
+	ran := false
+	noisy := WithSubmitter(WithPriority(context.Background(), 3), "noisy")
+	_, err := pool.SubmitCtx(noisy, func(ctx context.Context) (int, error) {
+		ran = true
+		return 1, nil
+	}).Await(context.Background())
+	if !errors.Is(err, errQuota) || ran {
+		t.Fatalf("expected quota rejection without running, got %v (ran %v)", err, ran)
+	}
+	if _, err := pool.SubmitClass("admin", func() (int, error) { return 1, nil }).Await(context.Background()); !errors.Is(err, errQuota) {
+		t.Fatalf("expected reserved class to pass through admission, got %v", err)
+	}
+	if val, err := pool.Submit(func() (int, error) { return 2, nil }, WithLabel("ok")).Await(context.Background()); err != nil || val != 2 {
+		t.Fatalf("expected admitted task, got %d, %v", val, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 3 || seen[0].Method != "SubmitCtx" || seen[0].Priority != 3 || seen[2].Label != "ok" {
+		t.Fatalf("unexpected task infos %+v", seen)
+	}
+}
+
+// TestFairScheduling kiểm tra task ưu tiên thấp vẫn được chạy theo trọng số khi tải ưu tiên cao kéo dài
+func TestFairScheduling(t *testing.T) {
+	pool := NewWorkerPool[int](1, WithFairScheduling(map[int]int{10: 3, 0: 1}))
+	defer pool.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() (int, error) {
+		close(started)
+		<-release
+		return 0, nil
+	})
+	<-started
+
+	var mu sync.Mutex
+	var order []int
+	var promises []*Promise[int]
+	for i := 0; i < 8; i++ {
+		for _, prio := range []int{10, 0} {
+			prio := prio
+			ctx := WithPriority(context.Background(), prio)
+			promises = append(promises, pool.SubmitCtx(ctx, func(ctx context.Context) (int, error) {
+				mu.Lock()
+				order = append(order, prio)
+				mu.Unlock()
+				return prio, nil
+			}))
+		}
+	}
+	for pool.Stats().QueueSize < 16 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	for _, p := range promises {
+		p.Await(context.Background())
+	}
+
+	low := 0
+	for _, prio := range order[:8] {
+		if prio == 0 {
+			low++
+		}
+	}
+	if low != 2 {
+		t.Fatalf("expected 2 low priority tasks among first 8 (3:1 weights), got order %v", order)
+	}
+}
+
+// TestGovernorCPUFraction kiểm tra task CPU-bound bị giới hạn theo GOMAXPROCS còn task IO thì không
+func TestGovernorCPUFraction(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	gov := NewGovernor(16, WithCPUFraction(0.5))
+	if got := gov.EffectiveParallelism(); got != 2 {
+		t.Fatalf("expected effective parallelism 2, got %d", got)
+	}
+
+	cpuPool := NewWorkerPool[int](4, WithGovernor(gov), WithCPUBound())
+	defer cpuPool.Close()
+	ioPool := NewWorkerPool[int](4, WithGovernor(gov))
+	defer ioPool.Close()
+
+	track := func(running, maxRunning *int32) func() (int, error) {
+		return func() (int, error) {
+			n := atomic.AddInt32(running, 1)
+			for {
+				old := atomic.LoadInt32(maxRunning)
+				if n <= old || atomic.CompareAndSwapInt32(maxRunning, old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(running, -1)
+			return 0, nil
+		}
+	}
+
+	var cpuRunning, cpuMax, ioRunning, ioMax int32
+	var promises []*Promise[int]
+	for i := 0; i < 8; i++ {
+		promises = append(promises, cpuPool.Submit(track(&cpuRunning, &cpuMax)))
+		promises = append(promises, ioPool.Submit(track(&ioRunning, &ioMax)))
+	}
+	if _, err := All(context.Background(), promises...).Await(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cpuMax > 2 {
+		t.Fatalf("expected at most 2 concurrent CPU-bound tasks, got %d", cpuMax)
+	}
+	if ioMax < 3 {
+		t.Fatalf("expected IO-bound tasks to use more than the CPU cap, got %d", ioMax)
+	}
+	if gov.CPUInUse() != 0 || gov.InUse() != 0 {
+		t.Fatalf("expected all slots released, cpu=%d total=%d", gov.CPUInUse(), gov.InUse())
+	}
+}
+
+// TestCancelGroup kiểm tra CancelGroup hủy task đang chạy và đang chờ của group mà không ảnh hưởng group khác
+func TestCancelGroup(t *testing.T) {
+	pool := NewWorkerPool[int](1)
+	defer pool.Close()
+
+	started := make(chan struct{}, 1)
+	blocking := func(ctx context.Context) (int, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+
+	report := WithGroup(context.Background(), "report-1")
+	running := pool.SubmitCtx(report, blocking)
+	<-started
+	queued := pool.SubmitCtx(report, func(ctx context.Context) (int, error) { return 1, nil })
+
+	other := pool.SubmitCtx(WithGroup(context.Background(), "report-2"), func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+
+	if n := pool.CancelGroup("report-1"); n != 2 {
+		t.Fatalf("expected 2 pending tasks in group, got %d", n)
+	}
+
+	err := pool.WaitGroupID(context.Background(), "report-1")
+	var ae *AggregateError
+	if !errors.As(err, &ae) || ae.Count() != 2 {
+		t.Fatalf("expected aggregate error of 2 tasks, got %v", err)
+	}
+	if _, err := running.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected running task ctx cancelled, got %v", err)
+	}
+	if _, err := queued.Await(context.Background()); !errors.Is(err, ErrTaskCancelled) {
+		t.Fatalf("expected queued task cancelled, got %v", err)
+	}
+
+	if v, err := other.Await(context.Background()); err != nil || v != 2 {
+		t.Fatalf("expected other group unaffected, got %v, %v", v, err)
+	}
+	if err := pool.WaitGroupID(context.Background(), "report-2"); err != nil {
+		t.Fatalf("expected nil error for fulfilled group, got %v", err)
+	}
+
+	// Group đã settle hết nên ID được dùng lại như group mới
+	if v, err := pool.SubmitCtx(report, func(ctx context.Context) (int, error) { return 3, nil }).Await(context.Background()); err != nil || v != 3 {
+		t.Fatalf("expected reused group ID to run, got %v, %v", v, err)
+	}
+}
+
+// BenchmarkSubmitParallel đo Submit từ 64 goroutine cùng lúc
+func BenchmarkSubmitParallel(b *testing.B) {
+	pool := NewWorkerPool[int](runtime.GOMAXPROCS(0))
+	defer pool.Close()
+
+	fn := func() (int, error) { return 1, nil }
+	b.SetParallelism(divCeil(64, runtime.GOMAXPROCS(0)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Submit(fn).Await(context.Background())
+		}
+	})
+}
+
+// BenchmarkSubmitParallelNoWait đo riêng đường Submit (không Await) từ 64 goroutine
+func BenchmarkSubmitParallelNoWait(b *testing.B) {
+	pool := NewWorkerPool[int](runtime.GOMAXPROCS(0))
+	defer pool.Close()
+
+	fn := func() (int, error) { return 1, nil }
+	b.SetParallelism(divCeil(64, runtime.GOMAXPROCS(0)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Submit(fn)
+		}
+	})
+}
+
+// divCeil chia làm tròn lên
+func divCeil(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// TestAwaitAll kiểm tra AwaitAll trả về giá trị theo thứ tự, fail fast và tôn trọng ctx
+func TestAwaitAll(t *testing.T) {
+	skipInLean(t)
+
+	gate := make(chan struct{})
+	ps := []*Promise[int]{
+		NewPromise(func() (int, error) { <-gate; return 1, nil }),
+		NewPromise(func() (int, error) { return 2, nil }),
+		NewPromise(func() (int, error) { return 3, nil }),
+	}
+	close(gate)
+	values, err := AwaitAll(context.Background(), ps)
+	if err != nil || fmt.Sprint(values) != "[1 2 3]" {
+		t.Fatalf("expected [1 2 3], got %v, %v", values, err)
+	}
+
+	boom := errors.New("boom")
+	never := NewPromiseWithExecutor[int](func(resolve func(int), reject func(error)) {})
+	failing := NewPromise(func() (int, error) { return 0, boom }).SetLabel("fetch")
+	if _, err := AwaitAll(context.Background(), []*Promise[int]{never, failing}); !errors.Is(err, boom) || !strings.Contains(err.Error(), `promise[1] "fetch"`) {
+		t.Fatalf("expected fail fast with indexed error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var awaitErr *AwaitError
+	if _, err := AwaitAll(ctx, []*Promise[int]{ps[0], never}); !errors.As(err, &awaitErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected AwaitError on ctx timeout, got %v", err)
+	}
+
+	if values, err := AwaitAll[int](context.Background(), nil); err != nil || len(values) != 0 {
+		t.Fatalf("expected empty result, got %v, %v", values, err)
+	}
+}
+
+// BenchmarkAwaitAll so sánh AwaitAll với All(...).Await trên 16 promise đã settle
+func BenchmarkAwaitAll(b *testing.B) {
+	ps := make([]*Promise[int], 16)
+	for i := range ps {
+		ps[i] = NewPromise(func() (int, error) { return 1, nil })
+	}
+	AwaitAll(context.Background(), ps)
+
+	b.Run("AwaitAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			AwaitAll(context.Background(), ps)
+		}
+	})
+	b.Run("All", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			All(context.Background(), ps...).Await(context.Background())
+		}
+	})
+}
+
+// TestPromiseOn kiểm tra các bước chain sau On chạy trên workers của pool
+func TestPromiseOn(t *testing.T) {
+	pool := NewWorkerPool[int](1)
+	defer pool.Close()
+
+	gate := make(chan struct{})
+	src := NewPromise(func() (int, error) { <-gate; return 1, nil })
+
+	var running, maxRunning int32
+	step := func(v int) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		if n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return v + 1, nil
+	}
+
+	on := src.On(pool)
+	var chains []*Promise[int]
+	for i := 0; i < 4; i++ {
+		chains = append(chains, on.Map(step).Map(step).Catch(func(err error) (int, error) { return 0, err }))
+	}
+	close(gate)
+
+	values, err := AwaitAll(context.Background(), chains)
+	if err != nil || fmt.Sprint(values) != "[3 3 3 3]" {
+		t.Fatalf("expected [3 3 3 3], got %v, %v", values, err)
+	}
+	if maxRunning != 1 {
+		t.Fatalf("expected chain steps bounded by 1 worker, got %d concurrent", maxRunning)
+	}
+	pool.Close()
+	if completed := pool.completed.Load(); completed != 12 {
+		t.Fatalf("expected 12 steps to run on the pool, got %d", completed)
+	}
+
+	// Pool đã đóng thì bước chain vẫn chạy trên goroutine riêng
+	closed := NewWorkerPool[int](1)
+	closed.Close()
+	if v, err := src.On(closed).Map(step).Await(context.Background()); err != nil || v != 2 {
+		t.Fatalf("expected fallback after pool closed, got %v, %v", v, err)
+	}
+}
+
+// TestEventLoop kiểm tra continuation trên EventLoop chạy tuần tự theo thứ tự settle
+func TestEventLoop(t *testing.T) {
+	loop := NewEventLoop()
+	defer loop.Close()
+
+	var mu sync.Mutex
+	var order []int
+	var running, maxRunning int32
+	record := func(v int) error {
+		n := atomic.AddInt32(&running, 1)
+		if n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		mu.Lock()
+		order = append(order, v)
+		mu.Unlock()
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+
+	var sources []chan int
+	var chains []*Promise[int]
+	for i := 0; i < 5; i++ {
+		ch := make(chan int)
+		sources = append(sources, ch)
+		src := NewPromise(func() (int, error) { return <-ch, nil })
+		chains = append(chains, src.OnLoop(loop).Then(record).Map(func(v int) (int, error) { return v * 10, nil }).Then(record))
+	}
+	// Settle theo thứ tự ngược, từng cái một để thứ tự xác định
+	for i := 4; i >= 0; i-- {
+		sources[i] <- i
+		chains[i].Await(context.Background())
+	}
+
+	if maxRunning != 1 {
+		t.Fatalf("expected steps to run one at a time, got %d", maxRunning)
+	}
+	if got := fmt.Sprint(order); got != "[4 40 3 30 2 20 1 10 0 0]" {
+		t.Fatalf("unexpected order %s", got)
+	}
+
+	SetDefaultLoop(loop)
+	defer SetDefaultLoop(nil)
+	if v, err := NewPromise(func() (int, error) { return 2, nil }).Map(func(v int) (int, error) { return v + 1, nil }).Await(context.Background()); err != nil || v != 3 {
+		t.Fatalf("expected default loop continuation to run, got %v, %v", v, err)
+	}
+}
+
+// TestJoin2 kiểm tra Join2 trả về hai giá trị khác kiểu và reject khi một bên lỗi
+func TestJoin2(t *testing.T) {
+	skipInLean(t)
+
+	user := NewPromise(func() (string, error) { return "alice", nil })
+	count := NewPromise(func() (int, error) { return 3, nil })
+
+	name, n, err := Join2(context.Background(), user, count).Await(context.Background())
+	if err != nil || name != "alice" || n != 3 {
+		t.Fatalf("expected alice, 3, got %q, %d, %v", name, n, err)
+	}
+
+	boom := errors.New("boom")
+	failing := NewPromise(func() (int, error) { return 0, boom }).SetLabel("orders")
+	never := NewPromiseWithExecutor[string](func(resolve func(string), reject func(error)) {})
+	_, _, err = Join2(context.Background(), never, failing).Await(context.Background())
+	if !errors.Is(err, boom) || !strings.Contains(err.Error(), `promise[1] "orders"`) {
+		t.Fatalf("expected fail fast with indexed error, got %v", err)
+	}
+}
+
+// TestCatchNotFound kiểm tra lỗi sentinel thành None còn lỗi khác vẫn reject
+func TestCatchNotFound(t *testing.T) {
+	errNotFound := errors.New("not found")
+
+	found, err := CatchNotFound(NewPromise(func() (int, error) { return 7, nil }), errNotFound).Await(context.Background())
+	if err != nil || found != Some(7) {
+		t.Fatalf("expected Some(7), got %+v, %v", found, err)
+	}
+
+	missing, err := CatchNotFound(NewPromise(func() (int, error) {
+		return 0, fmt.Errorf("user 42: %w", errNotFound)
+	}), errNotFound).Await(context.Background())
+	if err != nil || missing.OK {
+		t.Fatalf("expected None, got %+v, %v", missing, err)
+	}
+	if v := missing.OrElse(-1); v != -1 {
+		t.Fatalf("expected fallback -1, got %d", v)
+	}
+
+	boom := errors.New("boom")
+	if _, err := CatchNotFound(NewPromise(func() (int, error) { return 0, boom }), errNotFound).Await(context.Background()); !errors.Is(err, boom) {
+		t.Fatalf("expected other errors to reject, got %v", err)
+	}
+}
+
+// TestMaybeCombinators kiểm tra Filter, AllMaybe và FirstSome phân biệt "không có" với lỗi
+func TestMaybeCombinators(t *testing.T) {
+	even := func(v int) bool { return v%2 == 0 }
+	var stages []*Promise[Maybe[int]]
+	for i := 1; i <= 6; i++ {
+		i := i
+		stages = append(stages, Filter(NewPromise(func() (int, error) { return i, nil }), even))
+	}
+	values, err := AllMaybe(context.Background(), stages...).Await(context.Background())
+	if err != nil || fmt.Sprint(values) != "[2 4 6]" {
+		t.Fatalf("expected [2 4 6], got %v, %v", values, err)
+	}
+
+	none := NewPromise(func() (Maybe[string], error) { return None[string](), nil })
+	slow := NewPromise(func() (Maybe[string], error) {
+		time.Sleep(5 * time.Millisecond)
+		return Some("cache"), nil
+	})
+	first, err := FirstSome(context.Background(), none, slow).Await(context.Background())
+	if err != nil || first != Some("cache") {
+		t.Fatalf("expected Some(cache), got %+v, %v", first, err)
+	}
+
+	empty, err := FirstSome(context.Background(), none, none).Await(context.Background())
+	if err != nil || empty.OK {
+		t.Fatalf("expected None when nothing found, got %+v, %v", empty, err)
+	}
+
+	boom := errors.New("boom")
+	failing := NewPromise(func() (Maybe[string], error) { return Maybe[string]{}, boom })
+	var ae *AggregateError
+	if _, err := FirstSome(context.Background(), none, failing).Await(context.Background()); !errors.As(err, &ae) || !errors.Is(ae.Errors()[0], boom) {
+		t.Fatalf("expected AggregateError when lookups fail, got %v", err)
+	}
+}
+
+// TestThenTypeChanging kiểm tra Then/MapTo đổi kiểu kết quả và chuyển lỗi
+func TestThenTypeChanging(t *testing.T) {
+	p := NewPromise(func() (int, error) { return 42, nil })
+	s, err := MapTo(Then(p, func(v int) (string, error) {
+		return strconv.Itoa(v), nil
+	}), func(s string) []byte { return []byte(s + "!") }).Await(context.Background())
+	if err != nil || string(s) != "42!" {
+		t.Fatalf("expected 42!, got %q, %v", s, err)
+	}
+
+	boom := errors.New("boom")
+	called := false
+	failing := NewPromise(func() (int, error) { return 0, boom })
+	if _, err := Then(failing, func(v int) (string, error) {
+		called = true
+		return "", nil
+	}).Await(context.Background()); !errors.Is(err, boom) || called {
+		t.Fatalf("expected error to pass through without calling fn, got %v, called=%v", err, called)
+	}
+}
+
+// TestFlatMap kiểm tra FlatMap làm phẳng Promise trả về từ continuation
+func TestFlatMap(t *testing.T) {
+	fetch := NewPromise(func() (int, error) { return 7, nil })
+	saved := FlatMap(fetch, func(id int) *Promise[string] {
+		return NewPromise(func() (string, error) {
+			time.Sleep(time.Millisecond)
+			return "user-" + strconv.Itoa(id), nil
+		})
+	})
+	if v, err := saved.Await(context.Background()); err != nil || v != "user-7" {
+		t.Fatalf("expected user-7, got %q, %v", v, err)
+	}
+
+	boom := errors.New("boom")
+	inner := FlatMap(fetch, func(id int) *Promise[string] {
+		return NewPromise(func() (string, error) { return "", boom })
+	})
+	if _, err := inner.Await(context.Background()); !errors.Is(err, boom) {
+		t.Fatalf("expected inner error, got %v", err)
+	}
+
+	if _, err := FlatMap(fetch, func(id int) *Promise[string] { return nil }).Await(context.Background()); !errors.Is(err, ErrNilPromise) {
+		t.Fatalf("expected ErrNilPromise, got %v", err)
+	}
+}
+
+// TestMemoryAccounting kiểm tra kích thước kết quả được cộng theo label và trả khi Await
+func TestMemoryAccounting(t *testing.T) {
+	pool := NewWorkerPool[[]byte](2, WithMemoryAccounting(func(result any) int64 {
+		return int64(len(result.([]byte)))
+	}))
+	defer pool.Close()
+
+	var promises []*Promise[[]byte]
+	for i := 0; i < 3; i++ {
+		promises = append(promises, pool.Submit(func() ([]byte, error) {
+			return make([]byte, 100), nil
+		}, WithLabel("decode")))
+	}
+	small := pool.Submit(func() ([]byte, error) { return make([]byte, 10), nil })
+	failed := pool.Submit(func() ([]byte, error) { return nil, errors.New("boom") }, WithLabel("decode"))
+	for _, p := range append(promises, small, failed) {
+		<-p.Done()
+	}
+
+	usage := pool.MemoryUsage()
+	if u := usage["decode"]; u.Retained != 300 || u.Total != 300 || u.Count != 3 {
+		t.Fatalf("expected 300 retained bytes for decode, got %+v", u)
+	}
+	if u := usage[""]; u.Retained != 10 {
+		t.Fatalf("expected 10 retained bytes for unlabeled tasks, got %+v", u)
+	}
+
+	for _, p := range promises {
+		p.Await(context.Background())
+		p.Await(context.Background())
+	}
+	if u := pool.MemoryUsage()["decode"]; u.Retained != 0 || u.PeakRetained != 300 || u.Total != 300 {
+		t.Fatalf("expected decode results released after Await, got %+v", u)
+	}
+
+	plain := NewWorkerPool[int](1)
+	defer plain.Close()
+	if plain.MemoryUsage() != nil {
+		t.Fatal("expected nil MemoryUsage without WithMemoryAccounting")
+	}
+}
+
+// TestAllWithin kiểm tra AllWithin trả về kết quả đã xong khi hết hạn và hủy phần còn lại
+func TestAllWithin(t *testing.T) {
+	boom := errors.New("boom")
+	block := make(chan struct{})
+	defer close(block)
+
+	fast := NewPromise(func() (int, error) { return 1, nil })
+	failing := NewPromise(func() (int, error) { return 0, boom })
+	slow := NewPromise(func() (int, error) { <-block; return 3, nil })
+	<-fast.Done()
+	<-failing.Done()
+
+	report, err := AllWithin(context.Background(), 20*time.Millisecond, fast, failing, slow).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(report.Completed, report.Failed, report.Cancelled) != "[0] [1] [2]" {
+		t.Fatalf("unexpected report: completed=%v failed=%v cancelled=%v", report.Completed, report.Failed, report.Cancelled)
+	}
+	if fmt.Sprint(report.Values()) != "[1]" {
+		t.Fatalf("expected values [1], got %v", report.Values())
+	}
+	if _, err := slow.Await(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected remaining promise cancelled with ErrTimeout, got %v", err)
+	}
+
+	// Xong hết trước hạn thì không phải chờ tới hạn
+	start := time.Now()
+	report, _ = AllWithin(context.Background(), time.Minute, fast).Await(context.Background())
+	if time.Since(start) > time.Second || len(report.Completed) != 1 {
+		t.Fatalf("expected early resolve, got %+v after %s", report, time.Since(start))
+	}
+}
+
+// TestPhases kiểm tra barrier giữa các phase và policy abort/continue
+func TestPhases(t *testing.T) {
+	var extracted atomic.Int32
+	var violations atomic.Int32
+	extract := func(ctx context.Context) error {
+		time.Sleep(time.Millisecond)
+		extracted.Add(1)
+		return nil
+	}
+	transform := func(ctx context.Context) error {
+		if extracted.Load() != 4 {
+			violations.Add(1)
+		}
+		return nil
+	}
+	boom := errors.New("boom")
+
+	stats, err := NewPhases().
+		Add("extract", AbortOnError, extract, extract, extract, extract).
+		Add("transform", ContinueOnError, transform, func(ctx context.Context) error { return boom }, transform).
+		Run(context.Background(), 2).Await(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if violations.Load() != 0 {
+		t.Fatal("expected transform to start after every extract task finished")
+	}
+	if len(stats) != 2 || stats[0].Succeeded != 4 || stats[1].Succeeded != 2 || stats[1].Failed != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	var loaded atomic.Bool
+	_, err = NewPhases().
+		Add("validate", AbortOnError, func(ctx context.Context) error { return boom }, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}).
+		Add("load", AbortOnError, func(ctx context.Context) error { loaded.Store(true); return nil }).
+		Run(context.Background(), 0).Await(context.Background())
+	var pe *PhaseError
+	var ae *AggregateError
+	if !errors.As(err, &pe) || pe.Phase != "validate" || !errors.As(err, &ae) || !errors.Is(ae.Errors()[0], boom) {
+		t.Fatalf("expected PhaseError for validate, got %v", err)
+	}
+	if loaded.Load() || len(pe.Stats) != 1 || pe.Stats[0].Failed != 2 {
+		t.Fatalf("expected abort before load with sibling task cancelled, got %+v", pe.Stats)
+	}
+}
+
+// TestResolveReject kiểm tra Resolve/Reject trả về Promise đã settle sẵn
+func TestResolveReject(t *testing.T) {
+	p := Resolve("cached")
+	if p.State() != StatusFulfilled {
+		t.Fatalf("expected fulfilled immediately, got %s", p.State())
+	}
+	if v, err := p.Await(context.Background()); err != nil || v != "cached" {
+		t.Fatalf("expected cached, got %q, %v", v, err)
+	}
+
+	boom := errors.New("boom")
+	r := Reject[int](boom)
+	if r.State() != StatusRejected {
+		t.Fatalf("expected rejected immediately, got %s", r.State())
+	}
+	if _, err := r.Await(context.Background()); !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+
+	if v, err := r.Catch(func(err error) (int, error) { return 1, nil }).Await(context.Background()); err != nil || v != 1 {
+		t.Fatalf("expected chain on rejected promise to recover, got %d, %v", v, err)
+	}
+}
+
+// TestLazyPromise kiểm tra NewLazyPromise chỉ chạy khi được dùng và chỉ chạy một lần
+func TestLazyPromise(t *testing.T) {
+	var runs atomic.Int32
+	var order []int
+	var mu sync.Mutex
+	lazy := func(i int) *Promise[int] {
+		return NewLazyPromise(func() (int, error) {
+			runs.Add(1)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			return i, nil
+		})
+	}
+
+	p := lazy(0)
+	time.Sleep(5 * time.Millisecond)
+	if runs.Load() != 0 || p.State() != StatusPending {
+		t.Fatal("expected lazy promise not to run before first use")
+	}
+	p.Await(context.Background())
+	p.Await(context.Background())
+	if runs.Load() != 1 {
+		t.Fatalf("expected exactly one run, got %d", runs.Load())
+	}
+
+	// Sequence chạy lần lượt vì mỗi promise chỉ bắt đầu khi được Await
+	order = nil
+	values, err := Sequence(context.Background(), lazy(1), lazy(2), lazy(3)).Await(context.Background())
+	if err != nil || fmt.Sprint(values) != "[1 2 3]" || fmt.Sprint(order) != "[1 2 3]" {
+		t.Fatalf("expected sequential run, got values %v order %v, %v", values, order, err)
+	}
+
+	timed := NewLazyPromise(func() (int, error) { return 1, nil }, WithTimeout(5*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	if v, err := timed.Start().Await(context.Background()); err != nil || v != 1 {
+		t.Fatalf("expected timeout to count from Start, got %d, %v", v, err)
+	}
+}
+
+// TestApplyConfig kiểm tra Apply đổi số workers, timeout và rate limit của pool đang chạy
+func TestApplyConfig(t *testing.T) {
+	pool := NewWorkerPool[int](2)
+	defer pool.Close()
+
+	// peak đo số task chạy cùng lúc lớn nhất của một batch
+	runBatch := func(n int) int {
+		var running, peak atomic.Int32
+		promises := make([]*Promise[int], n)
+		for i := range promises {
+			promises[i] = pool.Submit(func() (int, error) {
+				cur := running.Add(1)
+				for {
+					old := peak.Load()
+					if cur <= old || peak.CompareAndSwap(old, cur) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+				return 0, nil
+			})
+		}
+		if _, err := All(context.Background(), promises...).Await(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return int(peak.Load())
+	}
+
+	if err := pool.Apply(Config{Workers: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if s := pool.Stats(); s.NumWorkers != 4 || s.ConcurrencyLimit != 4 {
+		t.Fatalf("expected 4 workers, got %+v", s)
+	}
+	if peak := runBatch(8); peak != 4 {
+		t.Fatalf("expected 4 tasks in parallel, got %d", peak)
+	}
+
+	if err := pool.Apply(Config{Workers: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if s := pool.Stats(); s.ConcurrencyLimit != 1 {
+		t.Fatalf("expected concurrency limit 1, got %d", s.ConcurrencyLimit)
+	}
+	if peak := runBatch(4); peak != 1 {
+		t.Fatalf("expected 1 task at a time, got %d", peak)
+	}
+
+	pool.Apply(Config{Timeout: 5 * time.Millisecond})
+	_, err := pool.Submit(func() (int, error) {
+		time.Sleep(50 * time.Millisecond)
+		return 0, nil
+	}).Await(context.Background())
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout after Apply, got %v", err)
+	}
+
+	pool.Apply(Config{Workers: 2, Timeout: -1, RateLimit: 50})
+	start := time.Now()
+	runBatch(4)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected rate limit to space out task starts, took %v", elapsed)
+	}
+
+	if err := pool.Apply(Config{QueueSize: 10}); !errors.Is(err, ErrNotReconfigurable) {
+		t.Fatalf("expected ErrNotReconfigurable for channel queue, got %v", err)
+	}
+	pool.Close()
+	if err := pool.Apply(Config{Workers: 3}); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed, got %v", err)
+	}
+}
+
+// TestCancelablePromise kiểm tra Cancel hủy ctx của task và reject với context.Canceled
+func TestCancelablePromise(t *testing.T) {
+	stopped := make(chan struct{})
+	p := NewCancelablePromise(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(stopped)
+		return 0, ctx.Err()
+	})
+	p.Cancel()
+
+	if _, err := p.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected task ctx to be cancelled")
+	}
+
+	done := NewCancelablePromise(context.Background(), func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	done.Await(context.Background())
+	done.Cancel()
+	if v, err := done.Await(context.Background()); err != nil || v != 1 {
+		t.Fatalf("expected Cancel after settle to be a no-op, got %d, %v", v, err)
+	}
+}
+
+// TestShadow kiểm tra Shadow trả kết quả primary và báo khác biệt của candidate
+func TestShadow(t *testing.T) {
+	reports := make(chan ShadowReport[int], 1)
+	equal := func(a, b int) bool { return a == b }
+
+	v, err := Shadow(context.Background(),
+		func(ctx context.Context) (int, error) { return 1, nil },
+		func(ctx context.Context) (int, error) {
+			time.Sleep(20 * time.Millisecond)
+			return 2, nil
+		},
+		equal,
+		func(r ShadowReport[int]) { reports <- r },
+	).Await(context.Background())
+	if err != nil || v != 1 {
+		t.Fatalf("expected primary result 1, got %d, %v", v, err)
+	}
+
+	r := <-reports
+	if r.Match || r.Candidate.Value != 2 || r.LatencyDelta() <= 0 {
+		t.Fatalf("expected slower mismatching candidate, got %+v", r)
+	}
+
+	Shadow(context.Background(),
+		func(ctx context.Context) (int, error) { return 3, nil },
+		func(ctx context.Context) (int, error) { panic("boom") },
+		equal,
+		func(r ShadowReport[int]) { reports <- r },
+	)
+	if r := <-reports; r.Match || r.Candidate.Err == nil {
+		t.Fatalf("expected candidate panic to be reported as mismatch, got %+v", r)
+	}
+}
+
+// TestNewPromiseCtx kiểm tra fn của NewPromiseCtx thấy ctx bị hủy và hết timeout
+func TestNewPromiseCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPromiseCtx(ctx, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	cancel()
+	if _, err := p.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	stopped := make(chan struct{})
+	timed := NewPromiseCtx(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(stopped)
+		return 0, nil
+	}, WithTimeout(5*time.Millisecond))
+	if _, err := timed.Await(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected task ctx to be cancelled on timeout")
+	}
+}
+
+// BenchmarkNewPromise đo NewPromise + Await; chạy thêm với -tags promise2lean để so với lean mode
+// và với BenchmarkRawGoroutine (goroutine + channel thuần)
+func BenchmarkNewPromise(b *testing.B) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewPromise(func() (int, error) { return i, nil }).Await(ctx)
+	}
+}
+
+func BenchmarkRawGoroutine(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ch := make(chan Result[int], 1)
+		go func() { ch <- Result[int]{Value: i} }()
+		<-ch
+	}
+}
+
+// TestLeanMode kiểm tra label và thời gian chờ chỉ bị bỏ khi build với tag promise2lean
+func TestLeanMode(t *testing.T) {
+	p := Resolve(1).SetLabel("fetch")
+	if got, want := p.Label() == "", leanMode; got != want {
+		t.Fatalf("expected label dropped only in lean mode (lean=%v), got %q", leanMode, p.Label())
+	}
+}
+
+// skipInLean bỏ qua test dựa vào label, thời gian hoặc debug tracking khi build với promise2lean
+func skipInLean(t *testing.T) {
+	if leanMode {
+		t.Skip("labels and debug tracking are compiled out in lean mode")
+	}
+}
+
+// TestPromiseWithTimeout kiểm tra p.WithTimeout reject với ErrTimeout và hủy ctx của công việc
+func TestPromiseWithTimeout(t *testing.T) {
+	fast := NewPromise(func() (int, error) { return 1, nil }).WithTimeout(time.Second)
+	if v, err := fast.Await(context.Background()); err != nil || v != 1 {
+		t.Fatalf("expected 1, got %d, %v", v, err)
+	}
+
+	stopped := make(chan struct{})
+	work := NewPromiseCtx(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(stopped)
+		return 0, ctx.Err()
+	})
+	if _, err := work.WithTimeout(5 * time.Millisecond).Await(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected underlying work to be cancelled")
+	}
+
+	pool := NewWorkerPool[int](1)
+	defer pool.Close()
+	task := pool.SubmitCtx(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}).WithTimeout(5 * time.Millisecond)
+	if _, err := task.Await(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout for pool task, got %v", err)
+	}
+}
+
+// TestCancelCauses kiểm tra mỗi nguồn cancellation có lỗi và CancelCause riêng
+func TestCancelCauses(t *testing.T) {
+	skipInLean(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, callerErr := NewPromise(func() (int, error) { select {} }).Await(ctx)
+
+	timeoutErr := func() error {
+		_, err := NewPromise(func() (int, error) { select {} }, WithTimeout(time.Millisecond)).Await(context.Background())
+		return err
+	}()
+
+	pool := NewWorkerPool[int](1, WithTimeout(time.Millisecond))
+	_, deadlineErr := pool.Submit(func() (int, error) {
+		time.Sleep(20 * time.Millisecond)
+		return 0, nil
+	}).Await(context.Background())
+	pool.Close()
+
+	pool = NewWorkerPool[int](1)
+	release := make(chan struct{})
+	pool.Submit(func() (int, error) { <-release; return 0, nil })
+	queued := pool.Submit(func() (int, error) { return 0, nil })
+	sctx, scancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer scancel()
+	pool.Shutdown(sctx)
+	// Task trong channel bị reject khi worker lấy ra
+	close(release)
+	_, shutdownErr := queued.Await(context.Background())
+
+	EnableDebug(true)
+	stuck := NewPromise(func() (int, error) { select {} })
+	EnableDebug(false)
+	wctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	StartWatchdog(wctx, 5*time.Millisecond, func(s StuckPromise) { s.Kill() })
+	_, watchdogErr := stuck.Await(context.Background())
+
+	for _, tc := range []struct {
+		err      error
+		sentinel error
+		cause    CancelCause
+	}{
+		{callerErr, ErrCallerCancelled, CauseCaller},
+		{timeoutErr, ErrTimeout, CauseTimeout},
+		{deadlineErr, ErrTaskDeadline, CauseTaskDeadline},
+		{watchdogErr, ErrWatchdogKilled, CauseWatchdog},
+		{shutdownErr, ErrPoolShutdown, CausePoolShutdown},
+		{ErrTaskCancelled, context.Canceled, CauseOther},
+		{errors.New("boom"), nil, CauseNone},
+	} {
+		if tc.sentinel != nil && !errors.Is(tc.err, tc.sentinel) {
+			t.Errorf("expected %v to wrap %v", tc.err, tc.sentinel)
+		}
+		if got := CancelCauseOf(tc.err); got != tc.cause {
+			t.Errorf("expected cause %s for %v, got %s", tc.cause, tc.err, got)
+		}
+	}
+	if !errors.Is(deadlineErr, ErrTimeout) {
+		t.Errorf("expected task deadline to still match ErrTimeout")
+	}
+}
+
+// TestRetryN kiểm tra số lần thử, policy nil và predicate retryIf của RetryN
+func TestRetryN(t *testing.T) {
+	errBusy := errors.New("busy")
+	errFatal := errors.New("fatal")
+	retryIf := func(err error) bool { return errors.Is(err, errBusy) }
+
+	var calls atomic.Int32
+	v, err := RetryN(context.Background(), 5, &backoff.Exponential{Initial: time.Millisecond, Jitter: 0.5}, retryIf, func(ctx context.Context) (int, error) {
+		if calls.Add(1) < 3 {
+			return 0, errBusy
+		}
+		return 7, nil
+	}).Await(context.Background())
+	if err != nil || v != 7 || calls.Load() != 3 {
+		t.Fatalf("expected success on third attempt, got %d %v after %d calls", v, err, calls.Load())
+	}
+
+	calls.Store(0)
+	_, err = RetryN(context.Background(), 4, nil, nil, func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 0, errBusy
+	}).Await(context.Background())
+	if !errors.Is(err, errBusy) || calls.Load() != 4 {
+		t.Fatalf("expected 4 attempts, got %d calls, %v", calls.Load(), err)
+	}
+
+	calls.Store(0)
+	_, err = RetryN(context.Background(), 4, nil, retryIf, func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 0, errFatal
+	}).Await(context.Background())
+	if !errors.Is(err, errFatal) || calls.Load() != 1 {
+		t.Fatalf("expected predicate to stop retry, got %d calls, %v", calls.Load(), err)
+	}
+}
+
+// TestRetryAttemptsAndPredicate kiểm tra Retry dừng sau số lần thử của backoff.Limit
+// và không retry lỗi mà predicate của WithTransientErrors coi là vĩnh viễn
+func TestRetryAttemptsAndPredicate(t *testing.T) {
+	errBusy := errors.New("busy")
+	errFatal := errors.New("fatal")
+	ctx := WithTransientErrors(context.Background(), func(err error) bool { return errors.Is(err, errBusy) })
+	policy := backoff.Limit(&backoff.Exponential{Initial: time.Millisecond, Jitter: 0.5}, 3)
+
+	var calls atomic.Int32
+	_, err := Retry(ctx, policy, func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 0, errBusy
+	}).Await(context.Background())
+	if !errors.Is(err, errBusy) || calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d calls, %v", calls.Load(), err)
+	}
+
+	calls.Store(0)
+	_, err = Retry(ctx, policy, func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 0, errFatal
+	}).Await(context.Background())
+	if !errors.Is(err, errFatal) || calls.Load() != 1 {
+		t.Fatalf("expected no retry for permanent error, got %d calls, %v", calls.Load(), err)
+	}
+}
+
+// TestChain kiểm tra Chain nối các bước khác kiểu và báo lỗi kiểu rõ ràng
+func TestChain(t *testing.T) {
+	parse := Step("parse", func(ctx context.Context, s string) (int, error) { return strconv.Atoi(s) })
+	double := Step("double", func(ctx context.Context, n int) (int, error) { return n * 2, nil })
+	format := Step("format", func(ctx context.Context, n int) (string, error) { return fmt.Sprintf("#%d", n), nil })
+
+	c := NewChain(parse, double).Then(format)
+	if v, err := RunChain[string](context.Background(), c, "21").Await(context.Background()); err != nil || v != "#42" {
+		t.Fatalf("expected #42, got %q, %v", v, err)
+	}
+
+	_, err := RunChain[string](context.Background(), c, "x").Await(context.Background())
+	var ce *ChainError
+	if !errors.As(err, &ce) || ce.Step != "parse" || ce.Index != 0 {
+		t.Fatalf("expected ChainError from parse, got %v", err)
+	}
+
+	bad := NewChain(parse, format, double)
+	if err := bad.Err(); !errors.As(err, &ce) || ce.Step != "double" || !strings.Contains(err.Error(), `step "format" returns string`) {
+		t.Fatalf("expected build-time type error, got %v", err)
+	}
+
+	if _, err := RunChain[string](context.Background(), c, 21).Await(context.Background()); err == nil || !strings.Contains(err.Error(), "expects input string, got int") {
+		t.Fatalf("expected input type error, got %v", err)
+	}
+	if _, err := RunChain[int](context.Background(), c, "1").Await(context.Background()); err == nil {
+		t.Fatal("expected result type error")
+	}
+}
+
+// TestTap kiểm tra Tap/TapErr chạy side effect và giữ nguyên kết quả
+func TestTap(t *testing.T) {
+	var seen int
+	var seenErr error
+	boom := errors.New("boom")
+
+	v, err := Resolve(5).Tap(func(v int) { seen = v }).TapErr(func(err error) { seenErr = err }).Await(context.Background())
+	if err != nil || v != 5 || seen != 5 || seenErr != nil {
+		t.Fatalf("expected 5 passed through with tap, got %d, %v (seen %d, %v)", v, err, seen, seenErr)
+	}
+
+	seen = 0
+	_, err = Reject[int](boom).Tap(func(v int) { seen = 1 }).TapErr(func(err error) { seenErr = err }).Await(context.Background())
+	if !errors.Is(err, boom) || seen != 0 || !errors.Is(seenErr, boom) {
+		t.Fatalf("expected rejection passed through with TapErr, got %v (seen %d, %v)", err, seen, seenErr)
+	}
+}
+
+// TestWithProgress kiểm tra callback tiến độ của AllSettled và AllSettledTasks
+func TestWithProgress(t *testing.T) {
+	var mu sync.Mutex
+	var calls [][2]int
+	ctx := WithProgress(context.Background(), func(done, total int) {
+		mu.Lock()
+		calls = append(calls, [2]int{done, total})
+		mu.Unlock()
+	})
+
+	promises := []*Promise[int]{Resolve(1), Reject[int](errors.New("boom")), Resolve(3)}
+	if _, err := AllSettled(ctx, promises...).Await(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(calls) != "[[1 3] [2 3] [3 3]]" {
+		t.Fatalf("expected monotonic progress to 3/3, got %v", calls)
+	}
+
+	calls = nil
+	tasks := make([]func(ctx context.Context) (int, error), 5)
+	for i := range tasks {
+		tasks[i] = func(ctx context.Context) (int, error) { return i, nil }
+	}
+	AllSettledTasks(ctx, 2, tasks...).Await(context.Background())
+	if len(calls) != 5 || calls[4] != [2]int{5, 5} {
+		t.Fatalf("expected 5 progress calls ending at 5/5, got %v", calls)
+	}
+}
+
+// TestConstructorPanicRecovery kiểm tra NewPromise và executor panic bị reject với PanicError
+func TestConstructorPanicRecovery(t *testing.T) {
+	_, err := NewPromise(func() (int, error) { panic("boom") }).Await(context.Background())
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" || !errors.Is(err, ErrTaskPanicked) {
+		t.Fatalf("expected PanicError with value boom, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = NewPromiseWithExecutor(func(resolve func(int), reject func(error)) {
+		panic(errors.New("executor failed"))
+	}).Await(ctx)
+	if !errors.As(err, &pe) || fmt.Sprint(pe.Value) != "executor failed" {
+		t.Fatalf("expected executor panic to reject with PanicError, got %v", err)
+	}
+
+	v, err := NewPromiseWithExecutor(func(resolve func(int), reject func(error)) {
+		resolve(1)
+		panic("after resolve")
+	}).Await(ctx)
+	if err != nil || v != 1 {
+		t.Fatalf("expected panic after resolve to keep result, got %d, %v", v, err)
+	}
+}
+
+// TestProgressBar kiểm tra ProgressBar vẽ thanh, tốc độ, ETA và xuống dòng khi xong
+func TestProgressBar(t *testing.T) {
+	bar := NewProgressBar(io.Discard, "sync")
+	bar.Width = 10
+	if line := bar.render(5, 10, 2*time.Second); line != "sync [=====>    ] 5/10 2.5/s ETA 2s" {
+		t.Fatalf("unexpected line %q", line)
+	}
+	if line := bar.render(0, 10, 0); line != "sync [>         ] 0/10" {
+		t.Fatalf("unexpected line %q", line)
+	}
+
+	var buf bytes.Buffer
+	bar = NewProgressBar(&buf, "batch")
+	tasks := make([]func(ctx context.Context) (int, error), 3)
+	for i := range tasks {
+		tasks[i] = func(ctx context.Context) (int, error) { return i, nil }
+	}
+	AllSettledTasks(WithProgress(context.Background(), bar.Update), 1, tasks...).Await(context.Background())
+	if out := buf.String(); !strings.Contains(out, "3/3") || !strings.HasSuffix(out, "\n") {
+		t.Fatalf("expected final 3/3 line, got %q", out)
+	}
+}
+
+// TestPanicErrorStack kiểm tra PanicError của pool và Promise giữ giá trị panic và stack
+func TestPanicErrorStack(t *testing.T) {
+	pool := NewWorkerPool[int](1)
+	defer pool.Close()
+
+	explode := func() (int, error) { panic(fmt.Sprintf("bad input %d", 7)) }
+	for name, p := range map[string]*Promise[int]{
+		"pool":    pool.Submit(explode),
+		"promise": NewPromise(explode),
+	} {
+		_, err := p.Await(context.Background())
+		var pe *PanicError
+		if !errors.As(err, &pe) || pe.Value != "bad input 7" || !errors.Is(err, ErrTaskPanicked) {
+			t.Fatalf("%s: expected PanicError with value, got %v", name, err)
+		}
+		if !bytes.Contains(pe.Stack, []byte("TestPanicErrorStack")) {
+			t.Fatalf("%s: expected stack to include the panicking function, got:\n%s", name, pe.Stack)
+		}
+	}
+}
+
+// TestKeyedLimiter kiểm tra KeyedLimiter giới hạn số task đồng thời của mỗi key
+func TestKeyedLimiter(t *testing.T) {
+	hosts := NewKeyedLimiter(2)
+	var mu sync.Mutex
+	running, peak := map[string]int{}, map[string]int{}
+
+	var tasks []func(ctx context.Context) (int, error)
+	for i := 0; i < 12; i++ {
+		host := []string{"a.example", "b.example"}[i%2]
+		tasks = append(tasks, KeyLimited(hosts, host, func(ctx context.Context) (int, error) {
+			mu.Lock()
+			running[host]++
+			peak[host] = max(peak[host], running[host])
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running[host]--
+			mu.Unlock()
+			return i, nil
+		}))
+	}
+
+	statuses, _ := AllSettledTasks(context.Background(), 12, tasks...).Await(context.Background())
+	if _, errs := Values(statuses); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if peak["a.example"] != 2 || peak["b.example"] != 2 {
+		t.Fatalf("expected at most 2 in flight per host, got %v", peak)
+	}
+	if hosts.InFlight("a.example") != 0 || len(hosts.keys) != 0 {
+		t.Fatalf("expected all slots released and keys cleaned up")
+	}
+
+	release, _ := hosts.Acquire(context.Background(), "c")
+	release2, _ := hosts.Acquire(context.Background(), "c")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := hosts.Acquire(ctx, "c"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ctx error while key is full, got %v", err)
+	}
+	release()
+	release2()
+}
+
+func TestRateLimitRequeue(t *testing.T) {
+	errThrottled := errors.New("429 too many requests")
+	pool := NewWorkerPool[string](1, WithRateLimitRequeue(backoff.Limit(&backoff.Constant{}, 3)))
+	defer pool.Close()
+
+	var mu sync.Mutex
+	var order []string
+	record := func(s string) {
+		mu.Lock()
+		order = append(order, s)
+		mu.Unlock()
+	}
+
+	var calls atomic.Int32
+	throttled := pool.Submit(func() (string, error) {
+		record("throttled")
+		if calls.Add(1) < 3 {
+			return "", &backoff.RetryAfterError{Err: errThrottled, After: 10 * time.Millisecond}
+		}
+		return "ok", nil
+	})
+	other := pool.Submit(func() (string, error) {
+		record("other")
+		return "other", nil
+	})
+
+	if v, err := throttled.Await(context.Background()); err != nil || v != "ok" {
+		t.Fatalf("expected requeued task to succeed, got %q %v", v, err)
+	}
+	if _, err := other.Await(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(order) != 4 || order[1] != "other" {
+		t.Fatalf("expected other task to run while throttled task waits, got %v", order)
+	}
+	mu.Unlock()
+
+	always := pool.Submit(func() (string, error) {
+		return "", &backoff.RetryAfterError{Err: errThrottled, After: time.Millisecond}
+	})
+	if _, err := always.Await(context.Background()); !errors.Is(err, errThrottled) {
+		t.Fatalf("expected rate limit error once requeues are exhausted, got %v", err)
+	}
+	plain := pool.Submit(func() (string, error) { return "", errThrottled })
+	if _, err := plain.Await(context.Background()); !errors.Is(err, errThrottled) {
+		t.Fatalf("expected error without Retry-After to settle immediately, got %v", err)
+	}
+}
+
+func TestChainContextPropagation(t *testing.T) {
+	var rootCtxErr atomic.Value
+	root := NewPromiseCtx(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		rootCtxErr.Store(ctx.Err())
+		return 0, ctx.Err()
+	})
+	var ran atomic.Bool
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	tail := root.Map(func(v int) (int, error) { return v + 1, nil }).Then(func(int) error {
+		ran.Store(true)
+		return nil
+	}).WithContext(ctx)
+
+	if _, err := tail.Await(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected bound ctx deadline, got %v", err)
+	}
+	if _, err := root.Await(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected root to be cancelled through the chain, got %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for rootCtxErr.Load() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if rootCtxErr.Load() == nil {
+		t.Fatal("expected root ctx to be cancelled")
+	}
+	if ran.Load() {
+		t.Fatal("expected cancelled stage not to run")
+	}
+
+	bound, stop := context.WithCancel(context.Background())
+	slow := NewPromise(func() (int, error) {
+		time.Sleep(50 * time.Millisecond)
+		return 1, nil
+	})
+	chained := Then(slow, func(v int) (string, error) { return strconv.Itoa(v), nil }).WithContext(bound)
+	stop()
+	if _, err := chained.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected bound ctx to cancel chain, got %v", err)
+	}
+	if _, err := slow.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected upstream promise to be rejected, got %v", err)
+	}
+
+	v, err := NewPromise(func() (int, error) { return 2, nil }).Map(func(v int) (int, error) { return v * 2, nil }).
+		WithContext(context.Background()).Await(context.Background())
+	if err != nil || v != 4 {
+		t.Fatalf("expected 4, got %d %v", v, err)
+	}
+}
+
+func TestCell(t *testing.T) {
+	counter := NewCell(0)
+	ctx := context.Background()
+
+	promises := make([]*Promise[int], 50)
+	for i := range promises {
+		promises[i] = NewPromise(func() (int, error) { return 1, nil }).Map(func(v int) (int, error) {
+			return counter.Update(ctx, func(n int) (int, error) { return n + v, nil }).Await(ctx)
+		})
+	}
+	if _, err := All(ctx, promises...).Await(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := counter.Read(ctx).Await(ctx); err != nil || v != 50 {
+		t.Fatalf("expected 50, got %d %v", v, err)
+	}
+
+	errRejected := errors.New("rejected")
+	if _, err := counter.Update(ctx, func(n int) (int, error) { return -1, errRejected }).Await(ctx); !errors.Is(err, errRejected) {
+		t.Fatalf("expected update error, got %v", err)
+	}
+	if _, err := counter.Update(ctx, func(n int) (int, error) { panic("boom") }).Await(ctx); !errors.Is(err, ErrTaskPanicked) {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+	if v, _ := counter.Read(ctx).Await(ctx); v != 50 {
+		t.Fatalf("expected failed updates to keep value, got %d", v)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	holding := counter.Update(ctx, func(n int) (int, error) {
+		close(started)
+		<-release
+		return n, nil
+	})
+	<-started
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := counter.Read(waitCtx).Await(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected read to give up while cell is busy, got %v", err)
+	}
+	close(release)
+	if _, err := holding.Await(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProgressPromise(t *testing.T) {
+	step := make(chan struct{})
+	p := NewProgressPromise(func(report func(int)) (string, error) {
+		for i := 1; i <= 3; i++ {
+			<-step
+			report(i * 25)
+		}
+		return "done", nil
+	})
+
+	var mu sync.Mutex
+	var seen []int
+	p.OnProgress(func(pct int) {
+		mu.Lock()
+		seen = append(seen, pct)
+		mu.Unlock()
+	})
+	progress := p.Progress()
+
+	step <- struct{}{}
+	if pct := <-progress; pct != 25 {
+		t.Fatalf("expected 25 on progress channel, got %d", pct)
+	}
+	step <- struct{}{}
+	step <- struct{}{}
+	if v, err := p.Await(context.Background()); err != nil || v != "done" {
+		t.Fatalf("expected done, got %q %v", v, err)
+	}
+
+	var last int
+	for pct := range progress {
+		last = pct
+	}
+	if last != 75 {
+		t.Fatalf("expected channel to keep latest progress 75, got %d", last)
+	}
+	mu.Lock()
+	if fmt.Sprint(seen) != "[25 50 75]" {
+		t.Fatalf("expected every report in order, got %v", seen)
+	}
+	mu.Unlock()
+
+	var late int
+	p.OnProgress(func(pct int) { late = pct })
+	if late != 75 {
+		t.Fatalf("expected late handler to get latest progress, got %d", late)
+	}
+	if _, ok := <-p.Progress(); ok {
+		t.Fatal("expected progress channel to be closed after settle")
+	}
+}
+
+func TestChainSharedStageCancel(t *testing.T) {
+	release := make(chan struct{})
+	shared := NewPromise(func() (int, error) {
+		<-release
+		return 1, nil
+	}).Map(func(v int) (int, error) { return v + 1, nil })
+
+	// Await hết ctx chỉ trả lỗi cho caller đó, awaiter khác và lần Await sau vẫn nhận kết quả
+	short, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := shared.Await(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected await deadline, got %v", err)
+	}
+
+	// Hủy một nhánh WithContext không hủy stage dùng chung khi còn nhánh khác
+	branchCtx, cancelBranch := context.WithCancel(context.Background())
+	cancelled := shared.WithContext(branchCtx)
+	sibling := shared.WithContext(context.Background())
+	cancelBranch()
+	if _, err := cancelled.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled branch, got %v", err)
+	}
+
+	other := make(chan error, 1)
+	go func() {
+		_, err := shared.Await(context.Background())
+		other <- err
+	}()
+	close(release)
+	if v, err := shared.Await(context.Background()); err != nil || v != 2 {
+		t.Fatalf("expected shared stage to settle with 2, got %d %v", v, err)
+	}
+	if err := <-other; err != nil {
+		t.Fatalf("expected second awaiter to get the value, got %v", err)
+	}
+	if v, err := sibling.Await(context.Background()); err != nil || v != 2 {
+		t.Fatalf("expected sibling branch to get 2, got %d %v", v, err)
+	}
+}
+
+// TestFlatMapCancel kiểm tra FlatMap bị hủy không gọi fn và hủy Promise fn đang chạy
+func TestFlatMapCancel(t *testing.T) {
+	release := make(chan struct{})
+	source := NewPromise(func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	stage := FlatMap(source, func(v int) *Promise[int] {
+		calls.Add(1)
+		return Resolve(v)
+	}).WithContext(ctx)
+	cancel()
+	if _, err := stage.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled stage, got %v", err)
+	}
+	close(release)
+	if _, err := source.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation to reach the source, got %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if calls.Load() != 0 {
+		t.Fatal("expected fn not to run after the stage was cancelled")
+	}
+
+	// Hủy khi Promise của fn đang chạy: ctx của nó bị hủy
+	started := make(chan struct{})
+	innerErr := make(chan error, 1)
+	ctx, cancel = context.WithCancel(context.Background())
+	stage = FlatMap(Resolve(1), func(v int) *Promise[int] {
+		return NewPromiseCtx(context.Background(), func(ctx context.Context) (int, error) {
+			close(started)
+			<-ctx.Done()
+			innerErr <- ctx.Err()
+			return 0, ctx.Err()
+		})
+	}).WithContext(ctx)
+	<-started
+	cancel()
+	if _, err := stage.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled stage, got %v", err)
+	}
+	select {
+	case err := <-innerErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected inner ctx to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected cancellation to reach the inner promise")
+	}
+}
+
+func TestRepanicOnlyInUserAwait(t *testing.T) {
+	panicking := func() *Promise[int] {
+		return NewPromise(func() (int, error) { panic("boom") }, WithPromiseRecover(RepanicOnAwait()))
+	}
+
+	// Chain và combinator chờ trong goroutine của thư viện: nhận *PanicError thay vì làm sập process
+	mapped := panicking().Map(func(v int) (int, error) { return v, nil })
+	_, err := All(context.Background(), mapped, Resolve(1)).Await(context.Background())
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" || len(pe.Stack) == 0 {
+		t.Fatalf("expected PanicError from chain, got %v", err)
+	}
+	if _, err := Then(panicking(), func(v int) (string, error) { return "", nil }).Await(context.Background()); !errors.As(err, &pe) {
+		t.Fatalf("expected PanicError from Then, got %v", err)
+	}
+
+	p := panicking()
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected user Await to re-panic with 'boom', got %v", r)
+			}
+		}()
+		_, _ = p.Await(context.Background())
+		t.Fatal("expected Await to panic")
+	}()
+}
+
+func TestRetryPanic(t *testing.T) {
+	var calls atomic.Int32
+	_, err := Retry(context.Background(), backoff.Limit(&backoff.Constant{}, 3), func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		panic("boom")
+	}).Await(context.Background())
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" || calls.Load() != 1 {
+		t.Fatalf("expected PanicError without retry, got %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestSequenceStepPanic(t *testing.T) {
+	// cả bước có và không có StepTimeout đều phải recover panic và tôn trọng ContinueOnError
+	for _, timeout := range []time.Duration{0, time.Second} {
+		statuses, err := SequenceTasksWith(context.Background(), SequenceOptions{StepTimeout: timeout, ContinueOnError: true},
+			func(ctx context.Context) (int, error) { panic("step") },
+			func(ctx context.Context) (int, error) { return 2, nil },
+		).Await(context.Background())
+		var pe *PanicError
+		if err != nil || !errors.As(statuses[0].Err, &pe) || statuses[1].Value != 2 {
+			t.Fatalf("timeout %s: expected panicking step to reject with PanicError, got %+v %v", timeout, statuses, err)
+		}
+	}
+}
+
+func TestSubmitKeyedDoesNotBlockOtherKeys(t *testing.T) {
+	pool := NewWorkerPool[int](2, WithMemoryBudget(1), WithResultMeasurer(func(any) int64 { return 1 }))
+	defer pool.Close()
+
+	// x giữ hết memory budget cho tới khi được Await, nên Submit của a phải chờ
+	x := pool.SubmitKeyed("x", func() (int, error) { return 1, nil })
+	<-x.Done()
+
+	first := make(chan *Promise[int], 1)
+	go func() { first <- pool.SubmitKeyed("a", func() (int, error) { return 2, nil }) }()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		pool.keyed.mu.Lock()
+		_, reserved := pool.keyed.inflight["a"]
+		_, xDone := pool.keyed.inflight["x"]
+		pool.keyed.mu.Unlock()
+		if reserved && !xDone {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected a to reserve its slot and x to finish while a waits in Submit")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	dup := make(chan *Promise[int], 1)
+	go func() { dup <- pool.SubmitKeyed("a", func() (int, error) { return 3, nil }) }()
+	var waiting *Promise[int]
+	select {
+	case waiting = <-dup:
+	case <-time.After(time.Second):
+		t.Fatal("expected duplicate key to get the reserved slot without waiting")
+	}
+
+	if _, err := x.Await(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := (<-first).Await(context.Background()); err != nil || v != 2 {
+		t.Fatalf("expected 2, got %d %v", v, err)
+	}
+	if v, err := waiting.Await(context.Background()); err != nil || v != 2 {
+		t.Fatalf("expected duplicate to share result 2, got %d %v", v, err)
+	}
+
+	pool.Close()
+	if _, err := pool.SubmitKeyed("b", func() (int, error) { return 4, nil }).Await(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed, got %v", err)
+	}
+	pool.keyed.mu.Lock()
+	_, leaked := pool.keyed.inflight["b"]
+	pool.keyed.mu.Unlock()
+	if leaked {
+		t.Fatal("expected rejected submit to give its slot back")
+	}
+}
+
+// TestFileQueueStorePartialLine kiểm tra dòng cuối ghi dở bị cắt bỏ và Append sau đó vẫn đọc lại được
+func TestFileQueueStorePartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+	store, err := OpenFileQueueStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Append(TaskDescriptor{Name: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Giả lập crash giữa lúc ghi: dòng cuối không có \n
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"append","id":2,"task":{"na`)
+	f.Close()
+
+	store, err = OpenFileQueueStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Append(TaskDescriptor{Name: "second"}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = OpenFileQueueStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	records, _ := store.Recover()
+	if len(records) != 2 || records[0].Task.Name != "first" || records[1].Task.Name != "second" {
+		t.Fatalf("expected both complete records to survive, got %+v", records)
+	}
+}
+
+// TestFileQueueStoreCorruptAndCompact kiểm tra dòng hỏng được đếm và Compact chỉ giữ task chưa ack
+func TestFileQueueStoreCorruptAndCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+	store, err := OpenFileQueueStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		id, err := store.Append(TaskDescriptor{Name: "done"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.Ack(id)
+	}
+	if _, err := store.Append(TaskDescriptor{Name: "pending"}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n")
+	f.Close()
+
+	store, err = OpenFileQueueStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.CorruptLines() != 1 {
+		t.Fatalf("expected 1 corrupt line, got %d", store.CorruptLines())
+	}
+
+	before, _ := os.Stat(path)
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() || store.CorruptLines() != 0 {
+		t.Fatalf("expected compaction to shrink journal, %d -> %d bytes", before.Size(), after.Size())
+	}
+
+	// store vẫn ghi được sau Compact, và ID không bị dùng lại sau khi mở lại
+	id, err := store.Append(TaskDescriptor{Name: "after"})
+	if err != nil || id != 12 {
+		t.Fatalf("expected id 12 after compaction, got %d, %v", id, err)
+	}
+	store.Close()
+
+	store, err = OpenFileQueueStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	records, _ := store.Recover()
+	if len(records) != 2 || records[0].Task.Name != "pending" || records[1].Task.Name != "after" {
+		t.Fatalf("expected pending tasks to survive compaction, got %+v", records)
+	}
+	if id, _ := store.Append(TaskDescriptor{Name: "next"}); id != 13 {
+		t.Fatalf("expected id 13 after reopen, got %d", id)
+	}
+}
+
+// failingAckStore là QueueStore không ack được
+type failingAckStore struct {
+	*MemoryQueueStore
+}
+
+func (failingAckStore) Ack(uint64) error { return errors.New("disk full") }
+
+// TestQueueStoreAckErrorHook kiểm tra lỗi Ack được báo qua WithErrorHook
+func TestQueueStoreAckErrorHook(t *testing.T) {
+	errs := make(chan error, 1)
+	pool := NewWorkerPool[int](1, WithQueueStore(failingAckStore{NewMemoryQueueStore()}), WithErrorHook(func(err error) {
+		errs <- err
+	}))
+	defer pool.Close()
+	pool.Register("one", func([]byte) (int, error) { return 1, nil })
+
+	if val, err := pool.SubmitTask(TaskDescriptor{Name: "one"}).Await(context.Background()); err != nil || val != 1 {
+		t.Fatalf("expected task result despite ack failure, got %d, %v", val, err)
+	}
+	select {
+	case err := <-errs:
+		if err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Fatalf("unexpected hook error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected ack error to reach the hook")
+	}
+}
+
+// sequencePolicy là policy có trạng thái, kiểm tra mỗi chuỗi retry dùng bản riêng
+// (không có mutex nên -race cũng phát hiện khi bị dùng chung)
+type sequencePolicy struct {
+	calls int
+	mixed *atomic.Bool
+}
+
+func (s *sequencePolicy) Next(attempt int) (time.Duration, bool) {
+	s.calls++
+	if s.calls != attempt {
+		s.mixed.Store(true)
+	}
+	return time.Millisecond, attempt < 3
+}
+
+func (s *sequencePolicy) Fresh() backoff.Policy {
+	return &sequencePolicy{mixed: s.mixed}
+}
+
+func TestRetryPolicyPerTask(t *testing.T) {
+	var mixed atomic.Bool
+	policy := &sequencePolicy{mixed: &mixed}
+	pool := NewWorkerPool[int](4, WithRetry(policy))
+	defer pool.Close()
+
+	errBusy := errors.New("busy")
+	var promises []*Promise[int]
+	for i := 0; i < 8; i++ {
+		promises = append(promises, pool.Submit(func() (int, error) { return 0, errBusy }))
+		promises = append(promises, Retry(context.Background(), policy, func(ctx context.Context) (int, error) { return 0, errBusy }))
+	}
+	AllSettled(context.Background(), promises...).Await(context.Background())
+	if mixed.Load() || policy.calls != 0 {
+		t.Fatal("expected every task to retry with its own copy of the stateful policy")
+	}
+}
@@ -0,0 +1,291 @@
+package promise2
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// TaskDescriptor mô tả một task có thể serialize để journal hoặc gửi đi nơi khác
+// Name xác định handler đã Register trên pool, Payload là dữ liệu đầu vào
+type TaskDescriptor struct {
+	Name    string `json:"name"`
+	Payload []byte `json:"payload,omitempty"`
+}
+
+// QueueRecord là một task đã được ghi vào QueueStore
+type QueueRecord struct {
+	ID   uint64         `json:"id"`
+	Task TaskDescriptor `json:"task"`
+}
+
+// QueueStore lưu trữ các task đã submit cho tới khi được ack
+// Sau khi restart, Recover trả về các task chưa được ack để chạy lại
+type QueueStore interface {
+	// Append ghi task và trả về ID dùng để ack
+	Append(desc TaskDescriptor) (uint64, error)
+	// Ack đánh dấu task đã xử lý xong
+	Ack(id uint64) error
+	// Recover trả về các task chưa được ack theo thứ tự submit
+	Recover() ([]QueueRecord, error)
+}
+
+// MemoryQueueStore là QueueStore trong bộ nhớ, mặc định của WorkerPool
+type MemoryQueueStore struct {
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]TaskDescriptor
+}
+
+// NewMemoryQueueStore tạo một MemoryQueueStore rỗng
+func NewMemoryQueueStore() *MemoryQueueStore {
+	return &MemoryQueueStore{pending: make(map[uint64]TaskDescriptor)}
+}
+
+// Append ghi task vào bộ nhớ
+func (s *MemoryQueueStore) Append(desc TaskDescriptor) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	s.pending[s.nextID] = desc
+	return s.nextID, nil
+}
+
+// Ack xóa task khỏi bộ nhớ
+func (s *MemoryQueueStore) Ack(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, id)
+	return nil
+}
+
+// Recover trả về các task chưa được ack
+func (s *MemoryQueueStore) Recover() ([]QueueRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedRecords(s.pending), nil
+}
+
+// FileQueueStore là QueueStore ghi journal dạng JSON lines vào file
+// Mỗi Append/Ack là một dòng, file được fsync sau mỗi lần ghi
+// Journal chỉ lớn thêm theo mỗi Append/Ack, gọi Compact định kỳ để bỏ các task đã ack
+type FileQueueStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	nextID  uint64
+	pending map[uint64]TaskDescriptor
+	corrupt int
+}
+
+// journalEntry là một dòng trong file journal
+type journalEntry struct {
+	Op   string          `json:"op"`
+	ID   uint64          `json:"id"`
+	Task *TaskDescriptor `json:"task,omitempty"`
+}
+
+// OpenFileQueueStore mở (hoặc tạo) file journal và đọc lại các task chưa ack
+// Dòng cuối ghi dở (crash giữa lúc ghi) bị cắt bỏ; dòng hỏng khác được bỏ qua và đếm trong CorruptLines
+func OpenFileQueueStore(path string) (*FileQueueStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileQueueStore{
+		path:    path,
+		file:    file,
+		pending: make(map[uint64]TaskDescriptor),
+	}
+
+	// valid là độ dài phần journal gồm các dòng đầy đủ (kết thúc bằng \n)
+	var valid int64
+	partial := false
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			partial = len(line) > 0
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("read queue journal: %w", err)
+		}
+		valid += int64(len(line))
+
+		// Dòng đầy đủ nhưng không đọc được không chặn việc khôi phục các task còn lại,
+		// chỉ được đếm để caller phát hiện qua CorruptLines
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			s.corrupt++
+			continue
+		}
+
+		switch entry.Op {
+		case "append":
+			if entry.Task != nil {
+				s.pending[entry.ID] = *entry.Task
+			}
+		case "ack":
+			delete(s.pending, entry.ID)
+		}
+		if entry.ID > s.nextID {
+			s.nextID = entry.ID
+		}
+	}
+
+	// Dòng cuối có thể bị ghi dở khi process crash: cắt bỏ để Append sau không ghi nối vào nó
+	if partial {
+		if err := file.Truncate(valid); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("truncate partial queue journal: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Append ghi task vào journal
+func (s *FileQueueStore) Append(desc TaskDescriptor) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID + 1
+	if err := s.write(journalEntry{Op: "append", ID: id, Task: &desc}); err != nil {
+		return 0, err
+	}
+
+	s.nextID = id
+	s.pending[id] = desc
+	return id, nil
+}
+
+// Ack ghi dấu ack vào journal
+func (s *FileQueueStore) Ack(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pending[id]; !ok {
+		return nil
+	}
+	if err := s.write(journalEntry{Op: "ack", ID: id}); err != nil {
+		return err
+	}
+
+	delete(s.pending, id)
+	return nil
+}
+
+// Recover trả về các task chưa được ack
+func (s *FileQueueStore) Recover() ([]QueueRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedRecords(s.pending), nil
+}
+
+// CorruptLines trả về số dòng hỏng bị bỏ qua khi mở journal
+// Task của các dòng này không thể khôi phục
+func (s *FileQueueStore) CorruptLines() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.corrupt
+}
+
+// Compact ghi lại journal chỉ gồm các task chưa ack (bỏ task đã ack và dòng hỏng)
+// File mới được ghi ra file tạm rồi rename đè lên journal, nên crash giữa chừng không làm mất task
+func (s *FileQueueStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".compact-*")
+	if err != nil {
+		return fmt.Errorf("compact queue journal: %w", err)
+	}
+	// Không làm gì nếu rename đã thành công
+	defer os.Remove(tmp.Name())
+
+	// Entry seq giữ lại ID lớn nhất để ID không bị dùng lại sau khi mở lại journal
+	entries := []journalEntry{{Op: "seq", ID: s.nextID}}
+	for _, rec := range sortedRecords(s.pending) {
+		entries = append(entries, journalEntry{Op: "append", ID: rec.ID, Task: &rec.Task})
+	}
+
+	w := bufio.NewWriter(tmp)
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact queue journal: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact queue journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("compact queue journal: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("compact queue journal: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("reopen compacted queue journal: %w", err)
+	}
+
+	s.file.Close()
+	s.file = file
+	s.corrupt = 0
+	return nil
+}
+
+// Close đóng file journal
+func (s *FileQueueStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// write ghi một entry và fsync
+func (s *FileQueueStore) write(entry journalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// sortedRecords chuyển map pending thành slice sắp xếp theo ID
+func sortedRecords(pending map[uint64]TaskDescriptor) []QueueRecord {
+	records := make([]QueueRecord, 0, len(pending))
+	for id, desc := range pending {
+		records = append(records, QueueRecord{ID: id, Task: desc})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	return records
+}
@@ -0,0 +1,144 @@
+package promise2
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Config là cấu hình pool có thể đổi lúc đang chạy bằng Apply
+// Trường bằng 0 được giữ nguyên
+type Config struct {
+	// Workers là số task được chạy đồng thời. Tăng quá số workers hiện có thì thêm
+	// worker mới; giảm thì dừng các worker đã thêm trước, rồi giới hạn số task chạy
+	// cùng lúc của các worker ban đầu (task đang chạy không bị ngắt)
+	Workers int
+	// QueueSize là số task chờ tối đa, chỉ đổi được với pool tự quản lý queue
+	// (WithAdaptiveQueue, WithContextPriority, WithSchedulingOrder, WithFairScheduling)
+	QueueSize int
+	// Timeout là timeout mặc định cho các task submit sau đó, < 0 để tắt
+	Timeout time.Duration
+	// RateLimit là số task tối đa được bắt đầu chạy mỗi giây, < 0 để tắt
+	RateLimit float64
+}
+
+// ErrNotReconfigurable được bọc bởi lỗi của Apply khi pool không đổi được cấu hình yêu cầu
+var ErrNotReconfigurable = fmt.Errorf("pool setting cannot be changed at runtime")
+
+// Apply đổi cấu hình của pool đang chạy mà không cần tạo pool mới hay chuyển task
+// Task đang chờ và đang chạy được giữ nguyên; cấu hình mới áp dụng cho các task bắt đầu sau đó
+// Trả về lỗi (không đổi gì) nếu cấu hình không hợp lệ hoặc pool đã đóng
+func (p *WorkerPool[T]) Apply(cfg Config) error {
+	if cfg.Workers < 0 || cfg.QueueSize < 0 {
+		return fmt.Errorf("invalid pool config: workers=%d queue=%d", cfg.Workers, cfg.QueueSize)
+	}
+	if cfg.QueueSize > 0 && p.prio == nil {
+		return fmt.Errorf("%w: queue size of channel-backed queue", ErrNotReconfigurable)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	p.scaling.Lock()
+	defer p.scaling.Unlock()
+
+	if cfg.Workers > 0 {
+		p.scaleWorkers(cfg.Workers)
+	}
+	if cfg.QueueSize > 0 {
+		p.prio.setMax(cfg.QueueSize)
+	}
+	if cfg.Timeout != 0 {
+		p.timeout.Store(int64(max(cfg.Timeout, 0)))
+	}
+	switch {
+	case cfg.RateLimit > 0:
+		p.rate.Store(newRateGate(cfg.RateLimit))
+	case cfg.RateLimit < 0:
+		p.rate.Store(nil)
+	}
+	return nil
+}
+
+// scaleWorkers đưa số task chạy đồng thời về n, gọi khi giữ p.scaling và p.mu.RLock
+func (p *WorkerPool[T]) scaleWorkers(n int) {
+	for p.workers+len(p.extra) < n {
+		stop := make(chan struct{})
+		p.extra = append(p.extra, stop)
+		p.wg.Add(1)
+		p.alive.Add(1)
+		go p.extraWorker(stop)
+	}
+	for len(p.extra) > 0 && p.workers+len(p.extra) > n {
+		last := len(p.extra) - 1
+		close(p.extra[last])
+		p.extra = p.extra[:last]
+	}
+	p.extraCount.Store(int32(len(p.extra)))
+
+	// Ít hơn số workers ban đầu thì giới hạn số task chạy cùng lúc bằng limiter
+	if n >= p.workers {
+		p.capacity.Store(nil)
+		return
+	}
+	l := p.capacity.Load()
+	if l == nil {
+		l = newDynamicLimiter(p.workers)
+	}
+	l.setLimit(n)
+	p.capacity.Store(l)
+}
+
+// extraWorker là worker thêm bởi Apply, chỉ nhận task từ queue chung và dừng khi stop đóng
+func (p *WorkerPool[T]) extraWorker(stop <-chan struct{}) {
+	defer p.wg.Done()
+	defer p.alive.Add(-1)
+
+	for {
+		select {
+		case t, ok := <-p.taskQueue:
+			if !ok {
+				return
+			}
+			p.executeTask(t)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// rateGate giới hạn số task bắt đầu chạy mỗi giây, các task cách nhau ít nhất interval
+type rateGate struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateGate(perSecond float64) *rateGate {
+	return &rateGate{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait chờ tới lượt của task tiếp theo hoặc tới khi done đóng
+func (g *rateGate) wait(done <-chan struct{}) {
+	g.mu.Lock()
+	now := time.Now()
+	at := g.next
+	if at.Before(now) {
+		at = now
+	}
+	g.next = at.Add(g.interval)
+	g.mu.Unlock()
+
+	if delay := time.Until(at); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-done:
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package promise2
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/phucps89/go-promise2/backoff"
+)
+
+// RecoverMode chọn cách xử lý khi task panic
+type RecoverMode int
+
+const (
+	// RecoverReject reject Promise với *PanicError (bọc ErrTaskPanicked, mặc định)
+	RecoverReject RecoverMode = iota
+	// RecoverRepanic lưu giá trị panic và panic lại tại nơi gọi Await; chain và combinator
+	// nối sau Promise đó nhận *PanicError như lỗi thường
+	RecoverRepanic
+	// RecoverHandle gọi Handler với giá trị panic, lỗi trả về được dùng để reject
+	RecoverHandle
+)
+
+// RecoverPolicy cấu hình cách xử lý panic cho pool hoặc Promise
+type RecoverPolicy struct {
+	Mode RecoverMode
+	// Handler dùng với RecoverHandle; trả về nil thì Promise fulfill với zero value
+	Handler func(recovered any) error
+}
+
+// RejectOnPanic reject Promise với *PanicError (giá trị panic và stack) khi task panic
+func RejectOnPanic() RecoverPolicy {
+	return RecoverPolicy{Mode: RecoverReject}
+}
+
+// RepanicOnAwait panic lại với giá trị gốc khi caller Await Promise của task đã panic
+// Then/Map, All, Race, ... không panic lại mà reject với *PanicError
+func RepanicOnAwait() RecoverPolicy {
+	return RecoverPolicy{Mode: RecoverRepanic}
+}
+
+// HandlePanic gọi handler với giá trị panic để quyết định lỗi của Promise
+func HandlePanic(handler func(recovered any) error) RecoverPolicy {
+	return RecoverPolicy{Mode: RecoverHandle, Handler: handler}
+}
+
+// WithRecover chọn cách pool xử lý task panic
+func WithRecover(policy RecoverPolicy) PoolOption {
+	return func(c *poolConfig) {
+		c.recover = policy
+	}
+}
+
+// PromiseOption cấu hình thêm cho Promise
+type PromiseOption func(*promiseConfig)
+
+// promiseConfig chứa các cấu hình tùy chọn của Promise
+type promiseConfig struct {
+	recover        *RecoverPolicy
+	checkUnsettled bool
+	onUnsettled    func(UnsettledInfo)
+	onDoubleSettle func(DoubleSettleInfo)
+	sink           ResultSink
+	tracer         Tracer
+	label          string
+	timeout        time.Duration
+	retry          backoff.Policy
+}
+
+// WithPromiseRecover chọn cách NewPromise xử lý panic theo policy
+// Mặc định panic được recover và Promise bị reject với *PanicError
+func WithPromiseRecover(policy RecoverPolicy) PromiseOption {
+	return func(c *promiseConfig) {
+		c.recover = &policy
+	}
+}
+
+// newPromiseConfig áp dụng các Option
+func newPromiseConfig(opts []Option) promiseConfig {
+	// cfg thoát ra heap qua applyPromise, nên chỉ cấp phát khi có option
+	if len(opts) == 0 {
+		return promiseConfig{}
+	}
+
+	cfg := new(promiseConfig)
+	for _, opt := range opts {
+		opt.applyPromise(cfg)
+	}
+	return *cfg
+}
+
+// applyPromiseConfig gắn label, sink, tracer và timeout của cấu hình vào Promise mới tạo
+func applyPromiseConfig[T any](p *Promise[T], cfg promiseConfig) {
+	p.sink = cfg.sink
+	if cfg.label != "" {
+		p.SetLabel(cfg.label)
+	}
+	p.trace(cfg.tracer)
+	p.expireAfter(cfg.timeout)
+}
+
+// panicErr chuyển giá trị recover thành lỗi của Promise theo policy
+func (rp RecoverPolicy) panicErr(recovered any) error {
+	switch rp.Mode {
+	case RecoverRepanic:
+		return &repanicError{panic: newPanicError(recovered)}
+	case RecoverHandle:
+		if rp.Handler != nil {
+			return rp.Handler(recovered)
+		}
+	}
+	return newPanicError(recovered)
+}
+
+// repanicError giữ panic để Await panic lại với giá trị gốc
+// Bên trong thư viện (await) nó được trả về dưới dạng *PanicError
+type repanicError struct {
+	panic *PanicError
+}
+
+func (e *repanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.panic.Value)
+}
+
+func (e *repanicError) Unwrap() error {
+	return e.panic
+}
+
+// newPanicError tạo PanicError kèm stack của goroutine đang recover
+// Phải được gọi trong hàm defer để stack còn chứa chỗ panic
+func newPanicError(recovered any) *PanicError {
+	return &PanicError{Value: recovered, Stack: debug.Stack()}
+}
+
+// recoverPanic chạy fn và chuyển panic thành Result lỗi *PanicError
+func recoverPanic[T any](fn func() (T, error)) (result Result[T]) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = Result[T]{Err: newPanicError(r)}
+		}
+	}()
+
+	val, err := fn()
+	return Result[T]{Value: val, Err: err}
+}
+
+// recovered chạy fn và chuyển panic thành Result theo policy
+func recovered[T any](policy RecoverPolicy, fn func() (T, error)) (result Result[T]) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = Result[T]{Err: policy.panicErr(r)}
+		}
+	}()
+
+	val, err := fn()
+	return Result[T]{Value: val, Err: err}
+}
@@ -0,0 +1,168 @@
+package promise2
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Codec chuyển giá trị kiểu T sang bytes và ngược lại, dùng bởi Recorder
+type Codec[T any] struct {
+	Encode func(T) ([]byte, error)
+	Decode func([]byte) (T, error)
+}
+
+// JSONCodec là Codec dùng encoding/json
+func JSONCodec[T any]() Codec[T] {
+	return Codec[T]{
+		Encode: func(v T) ([]byte, error) { return json.Marshal(v) },
+		Decode: func(data []byte) (T, error) {
+			var v T
+			err := json.Unmarshal(data, &v)
+			return v, err
+		},
+	}
+}
+
+// Recorder ghi lại input/output của các task được bọc bằng Recorded (chế độ record)
+// hoặc trả về kết quả đã ghi thay vì chạy task (chế độ replay), giúp test tích hợp
+// các pipeline nhiều promise chạy lặp lại được
+type Recorder struct {
+	replay bool
+
+	mu      sync.Mutex
+	entries []recordEntry
+	// pending giữ các entry chưa được phát lại theo task và input
+	pending map[recordKey][]recordEntry
+}
+
+// recordEntry là một lần chạy task được ghi lại
+type recordEntry struct {
+	Task   string          `json:"task"`
+	Input  json.RawMessage `json:"input"`
+	Output json.RawMessage `json:"output,omitempty"`
+	Err    *wireError      `json:"error,omitempty"`
+}
+
+type recordKey struct {
+	task  string
+	input string
+}
+
+// NewRecorder tạo Recorder ở chế độ record
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// LoadRecording đọc bản ghi đã Save và tạo Recorder ở chế độ replay
+func LoadRecording(r io.Reader) (*Recorder, error) {
+	rec := &Recorder{replay: true, pending: make(map[recordKey][]recordEntry)}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry recordEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("recording line %d: %w", line, err)
+		}
+		key := recordKey{task: entry.Task, input: string(entry.Input)}
+		rec.pending[key] = append(rec.pending[key], entry)
+		rec.entries = append(rec.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Save ghi các lần chạy đã record dưới dạng JSON lines
+func (r *Recorder) Save(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	for _, entry := range r.entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replaying cho biết Recorder đang ở chế độ replay
+func (r *Recorder) Replaying() bool {
+	return r.replay
+}
+
+// record lưu một lần chạy task
+func (r *Recorder) record(entry recordEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entry)
+}
+
+// next lấy lần chạy đã ghi tiếp theo của task với input
+// Các lần gọi cùng task và input được phát lại theo đúng thứ tự đã ghi
+func (r *Recorder) next(task string, input []byte) (recordEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := recordKey{task: task, input: string(input)}
+	queue := r.pending[key]
+	if len(queue) == 0 {
+		return recordEntry{}, false
+	}
+	r.pending[key] = queue[1:]
+	return queue[0], true
+}
+
+// Recorded bọc fn để ghi lại (chế độ record) hoặc phát lại (chế độ replay) kết quả của nó
+// task là tên ổn định của task trong bản ghi; input và output được serialize bằng codec
+// Ở chế độ replay fn không được gọi; input chưa được ghi trả về ErrNotRecorded
+func Recorded[In, Out any](
+	rec *Recorder,
+	task string,
+	inCodec Codec[In],
+	outCodec Codec[Out],
+	fn func(ctx context.Context, in In) (Out, error),
+) func(ctx context.Context, in In) (Out, error) {
+	return func(ctx context.Context, in In) (Out, error) {
+		var zero Out
+
+		input, err := inCodec.Encode(in)
+		if err != nil {
+			return zero, fmt.Errorf("record %s: encode input: %w", task, err)
+		}
+
+		if rec.replay {
+			entry, ok := rec.next(task, input)
+			if !ok {
+				return zero, fmt.Errorf("%w: task %s, input %s", ErrNotRecorded, task, input)
+			}
+			if entry.Err != nil {
+				return zero, entry.Err.decode()
+			}
+			out, err := outCodec.Decode(entry.Output)
+			if err != nil {
+				return zero, fmt.Errorf("replay %s: decode output: %w", task, err)
+			}
+			return out, nil
+		}
+
+		out, runErr := fn(ctx, in)
+		entry := recordEntry{Task: task, Input: input, Err: encodeError(runErr)}
+		if runErr == nil {
+			output, err := outCodec.Encode(out)
+			if err != nil {
+				return out, fmt.Errorf("record %s: encode output: %w", task, err)
+			}
+			entry.Output = output
+		}
+		rec.record(entry)
+		return out, runErr
+	}
+}
@@ -0,0 +1,65 @@
+package promise2
+
+import (
+	"time"
+
+	"github.com/phucps89/go-promise2/backoff"
+)
+
+// WithRateLimitRequeue đưa task bị rate limit (lỗi có Retry-After, xem backoff.DelayFromError)
+// trở lại queue sau delay được chỉ định thay vì settle promise
+// Khác WithRetry, worker không bị giữ trong lúc chờ nên các task khác vẫn chạy
+// policy chỉ dùng để giới hạn số lần đưa lại (ví dụ backoff.Limit, backoff.WithBudget);
+// khi policy dừng, promise reject với lỗi rate limit cuối cùng
+//
+//	pool := promise2.NewWorkerPool[*Response](4,
+//		promise2.WithRateLimitRequeue(backoff.Limit(&backoff.Constant{}, 5)))
+func WithRateLimitRequeue(policy backoff.Policy) PoolOption {
+	return func(c *poolConfig) {
+		c.requeue = policy
+	}
+}
+
+// requeueRateLimited đưa t lại queue nếu err là lỗi rate limit và policy còn cho phép
+// Trả về false nếu task phải settle với err như thường
+func (p *WorkerPool[T]) requeueRateLimited(t task[T], err error) bool {
+	if p.requeue == nil || err == nil || t.promise.State() != StatusPending {
+		return false
+	}
+	delay, ok := backoff.DelayFromError(err)
+	if !ok {
+		return false
+	}
+	if t.requeuePolicy == nil {
+		t.requeuePolicy = backoff.Fresh(p.requeue)
+	}
+	t.requeues++
+	if _, ok := t.requeuePolicy.Next(t.requeues); !ok {
+		return false
+	}
+
+	p.tags.requeued(t.tag)
+	go func() {
+		timer := time.NewTimer(max(delay, 0))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-p.done:
+			// Pool đã đóng thì không còn worker chạy lại, giữ lỗi rate limit
+			p.reject(t, err)
+			return
+		case <-t.promise.done:
+			// Promise đã settle trong lúc chờ (timeout, Cancel), chỉ trả lại tài nguyên
+			p.reject(t, err)
+			return
+		}
+
+		t.cancelled = p.queuedScope()
+		if p.deadlockTimeout > 0 {
+			t.promise.queued.Store(true)
+		}
+		p.enqueueTask(p.taskQueue, t)
+	}()
+	return true
+}
@@ -0,0 +1,97 @@
+package promise2
+
+// Ok tạo Result thành công
+func Ok[T any](val T) Result[T] {
+	return Result[T]{Value: val}
+}
+
+// Err tạo Result thất bại
+func Err[T any](err error) Result[T] {
+	return Result[T]{Err: err}
+}
+
+// Unwrap trả về giá trị và lỗi của Result
+func (r Result[T]) Unwrap() (T, error) {
+	return r.Value, r.Err
+}
+
+// UnwrapOr trả về giá trị của Result, hoặc def nếu Result lỗi
+func (r Result[T]) UnwrapOr(def T) T {
+	if r.Err != nil {
+		return def
+	}
+	return r.Value
+}
+
+// Map áp dụng fn cho giá trị nếu Result thành công, giữ nguyên lỗi nếu thất bại
+// Dùng MapResult khi cần đổi kiểu giá trị
+func (r Result[T]) Map(fn func(T) T) Result[T] {
+	if r.Err != nil {
+		return r
+	}
+	return Result[T]{Value: fn(r.Value)}
+}
+
+// AndThen gọi fn với giá trị nếu Result thành công, lỗi của fn làm Result thất bại
+func (r Result[T]) AndThen(fn func(T) (T, error)) Result[T] {
+	if r.Err != nil {
+		return r
+	}
+	val, err := fn(r.Value)
+	return Result[T]{Value: val, Err: err}
+}
+
+// OrElse gọi fn với lỗi nếu Result thất bại để khôi phục, giữ nguyên nếu thành công
+func (r Result[T]) OrElse(fn func(error) (T, error)) Result[T] {
+	if r.Err == nil {
+		return r
+	}
+	val, err := fn(r.Err)
+	return Result[T]{Value: val, Err: err}
+}
+
+// MapResult giống Result.AndThen nhưng đổi kiểu giá trị từ T sang U
+func MapResult[T, U any](r Result[T], fn func(T) (U, error)) Result[U] {
+	if r.Err != nil {
+		return Result[U]{Err: r.Err}
+	}
+	val, err := fn(r.Value)
+	return Result[U]{Value: val, Err: err}
+}
+
+// Values tách kết quả của AllSettled thành các giá trị thành công và các lỗi, giữ thứ tự
+func Values[T any](statuses []PromiseStatus[T]) ([]T, []error) {
+	var values []T
+	var errs []error
+	for _, s := range statuses {
+		if s.Status == StatusRejected {
+			errs = append(errs, s.Err)
+			continue
+		}
+		values = append(values, s.Value)
+	}
+	return values, errs
+}
+
+// MustValues trả về các giá trị của statuses, panic với AggregateError nếu có promise bị reject
+func MustValues[T any](statuses []PromiseStatus[T]) []T {
+	values, errs := Values(statuses)
+	if len(errs) > 0 {
+		panic(NewAggregateError(errs))
+	}
+	return values
+}
+
+// IndexedValues giống Values nhưng giữ index của từng promise trong input
+func IndexedValues[T any](statuses []PromiseStatus[T]) (map[int]T, map[int]error) {
+	values := make(map[int]T)
+	errs := make(map[int]error)
+	for i, s := range statuses {
+		if s.Status == StatusRejected {
+			errs[i] = s.Err
+			continue
+		}
+		values[i] = s.Value
+	}
+	return values, errs
+}
@@ -0,0 +1,173 @@
+package promise2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	"github.com/phucps89/go-promise2/backoff"
+)
+
+// idempotencyKeyCtx là key của context chứa idempotency key
+type idempotencyKeyCtx struct{}
+
+// WithIdempotencyKey gắn idempotency key do caller chọn vào ctx
+// SubmitCtx và Retry dùng key này thay vì tự sinh key mới
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+// IdempotencyKey trả về idempotency key của task hiện tại
+// Key giữ nguyên qua mọi lần retry của cùng một task logic,
+// nên có thể truyền cho API downstream để tránh side effect bị lặp lại
+func IdempotencyKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyCtx{}).(string)
+	return key, ok
+}
+
+// withIdempotencyKey đảm bảo ctx có idempotency key, sinh key ngẫu nhiên nếu chưa có
+func withIdempotencyKey(ctx context.Context) context.Context {
+	if _, ok := IdempotencyKey(ctx); ok {
+		return ctx
+	}
+
+	var buf [16]byte
+	rand.Read(buf[:])
+	return WithIdempotencyKey(ctx, hex.EncodeToString(buf[:]))
+}
+
+// attemptCtx là key của context chứa thông tin lần thử của task
+type attemptCtx struct{}
+
+// attemptInfo được retryLoop cập nhật trước mỗi lần chạy task
+type attemptInfo struct {
+	attempt atomic.Int32
+	first   atomic.Int64
+}
+
+// withAttemptInfo gắn thông tin lần thử vào ctx của task
+func withAttemptInfo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptCtx{}, &attemptInfo{})
+}
+
+// Attempt trả về lần thử hiện tại của task (bắt đầu từ 1)
+// Trả về 0 nếu ctx không phải ctx của task SubmitCtx hoặc Retry
+func Attempt(ctx context.Context) int {
+	info, ok := ctx.Value(attemptCtx{}).(*attemptInfo)
+	if !ok {
+		return 0
+	}
+	return int(info.attempt.Load())
+}
+
+// FirstAttemptAt trả về thời điểm lần thử đầu tiên của task bắt đầu
+// Trả về zero time nếu ctx không phải ctx của task SubmitCtx hoặc Retry
+func FirstAttemptAt(ctx context.Context) time.Time {
+	info, ok := ctx.Value(attemptCtx{}).(*attemptInfo)
+	if !ok || info.first.Load() == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, info.first.Load())
+}
+
+// WithRetry bật retry theo policy cho các task của pool (NewWorkerPool), một task (Submit)
+// hoặc function của NewPromise; không có tác dụng với NewPromiseWithExecutor
+// Task được chạy lại trên cùng worker sau delay của policy (có xét Retry-After qua
+// backoff.Delay); task panic hoặc bị reject do pool đóng/shed không được retry
+func WithRetry(policy backoff.Policy) Option {
+	return sharedOption{
+		promise: func(c *promiseConfig) { c.retry = policy },
+		pool:    func(c *poolConfig) { c.retry = policy },
+		task:    func(c *taskConfig) { c.retry = policy },
+	}
+}
+
+// Retry chạy fn, retry theo policy cho đến khi thành công, policy dừng hoặc ctx kết thúc
+// fn nhận ctx có idempotency key ổn định qua các lần retry (xem IdempotencyKey)
+// Tổng số lần thử giới hạn bằng backoff.Limit, lỗi nào được retry chọn bằng WithTransientErrors
+// (RetryN gói sẵn cả hai)
+func Retry[T any](ctx context.Context, policy backoff.Policy, fn func(ctx context.Context) (T, error)) *Promise[T] {
+	ctx = withAttemptInfo(withIdempotencyKey(ctx))
+
+	p := newPromise[T]()
+	p.node.setOp("Retry")
+
+	go func() {
+		p.settle(retryLoop(ctx, policy, nil, func() Result[T] {
+			// Lần thử panic trả về *PanicError và không được retry, như task của NewPromise/pool
+			return recoverPanic(func() (T, error) { return fn(ctx) })
+		}))
+	}()
+
+	return p
+}
+
+// RetryN chạy fn tối đa attempts lần (tối thiểu 1), chờ theo policy giữa các lần
+// (nil là thử lại ngay) cho đến khi thành công, hết lượt hoặc ctx kết thúc
+// retryIf chọn lỗi được retry; nil thì dùng WithTransientErrors của ctx nếu có, không thì
+// retry mọi lỗi trừ panic/cancel như Retry. Promise reject với lỗi của lần thử cuối
+//
+//	retryIf := func(err error) bool { return errors.Is(err, errBusy) }
+//	policy := &backoff.Exponential{Initial: 50 * time.Millisecond, Jitter: 0.2}
+//	user, err := promise2.RetryN(ctx, 5, policy, retryIf, fetchUser).Await(ctx)
+func RetryN[T any](ctx context.Context, attempts int, policy backoff.Policy, retryIf func(err error) bool, fn func(ctx context.Context) (T, error)) *Promise[T] {
+	if policy == nil {
+		policy = &backoff.Constant{}
+	}
+	if retryIf != nil {
+		ctx = WithTransientErrors(ctx, retryIf)
+	}
+
+	p := Retry(ctx, backoff.Limit(policy, max(attempts, 1)), fn)
+	p.node.setOp("RetryN")
+	return p
+}
+
+// retryLoop gọi run cho đến khi thành công hoặc không được retry nữa
+// Việc chờ giữa các lần thử dừng sớm khi ctx kết thúc hoặc stop được đóng
+func retryLoop[T any](ctx context.Context, policy backoff.Policy, stop <-chan struct{}, run func() Result[T]) Result[T] {
+	info, _ := ctx.Value(attemptCtx{}).(*attemptInfo)
+	// Policy của pool/WithRetry dùng chung giữa các task, policy có trạng thái cần bản riêng
+	policy = backoff.Fresh(policy)
+
+	for attempt := 1; ; attempt++ {
+		if info != nil {
+			info.attempt.Store(int32(attempt))
+			if attempt == 1 {
+				info.first.Store(time.Now().UnixNano())
+			}
+		}
+
+		result := run()
+		if result.Err == nil || policy == nil || !retryable(result.Err) || permanent(ctx, result.Err) {
+			return result
+		}
+
+		delay, ok := backoff.Delay(policy, attempt, result.Err)
+		if !ok {
+			return result
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result
+		case <-stop:
+			timer.Stop()
+			return result
+		}
+	}
+}
+
+// retryable cho biết lỗi có nên được retry hay không
+func retryable(err error) bool {
+	switch ClassOf(err) {
+	case ClassPanic, ClassPoolClosed, ClassShed, ClassCancelled:
+		return false
+	}
+	return true
+}
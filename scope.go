@@ -0,0 +1,63 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+)
+
+// RequestScope gom các Promise tạo trong phạm vi một request
+// Close hủy ctx của mọi Promise đã Spawn và chờ chúng kết thúc, nên không
+// công việc async nào của request sống lâu hơn handler
+type RequestScope struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Scope tạo RequestScope con của ctx
+//
+//	scope := promise2.Scope(r.Context())
+//	defer scope.Close()
+//	user := promise2.Spawn(scope, loadUser)
+func Scope(ctx context.Context) *RequestScope {
+	ctx, cancel := context.WithCancel(ctx)
+	return &RequestScope{ctx: ctx, cancel: cancel}
+}
+
+// Context trả về ctx của scope, bị hủy khi Close
+func (s *RequestScope) Context() context.Context {
+	return s.ctx
+}
+
+// Spawn chạy fn với ctx của scope trong một goroutine và trả về Promise
+// Sau khi scope đã Close, Promise reject ngay với ErrScopeClosed
+func Spawn[T any](s *RequestScope, fn func(ctx context.Context) (T, error)) *Promise[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return newSettledPromise(Result[T]{Err: ErrScopeClosed})
+	}
+
+	s.wg.Add(1)
+	p := NewPromise(func() (T, error) {
+		defer s.wg.Done()
+		return fn(s.ctx)
+	})
+	p.node.setOp("Spawn")
+	return p
+}
+
+// Close hủy ctx của scope và chờ mọi Promise đã Spawn kết thúc
+// Gọi nhiều lần an toàn
+func (s *RequestScope) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+}
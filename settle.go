@@ -0,0 +1,101 @@
+package promise2
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// UnsettledInfo mô tả một executor đã return mà không gọi resolve hoặc reject
+type UnsettledInfo struct {
+	Label string
+	// Caller là vị trí (file:line) gọi NewPromiseWithExecutor
+	Caller string
+}
+
+// WithUnsettledCheck kiểm tra executor đã settle Promise khi nó return hay chưa
+// Nếu onUnsettled là nil, Promise bị reject với ErrExecutorNeverSettled;
+// ngược lại onUnsettled được gọi (ví dụ để log) và Promise vẫn pending
+// Chỉ dùng cho executor settle đồng bộ, không dùng khi executor settle từ goroutine khác
+func WithUnsettledCheck(onUnsettled func(UnsettledInfo)) PromiseOption {
+	return func(c *promiseConfig) {
+		c.checkUnsettled = true
+		c.onUnsettled = onUnsettled
+	}
+}
+
+// reportUnsettled xử lý executor không settle theo cấu hình
+func (p *Promise[T]) reportUnsettled(onUnsettled func(UnsettledInfo), caller string) {
+	info := UnsettledInfo{Label: p.Label(), Caller: caller}
+	if onUnsettled != nil {
+		onUnsettled(info)
+		return
+	}
+
+	p.settle(Result[T]{Err: fmt.Errorf("%w (created at %s)", ErrExecutorNeverSettled, caller)})
+}
+
+// callerLocation trả về file:line của hàm gọi cách skip frames
+func callerLocation(skip int) string {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// SettleCall là một lần gọi resolve/reject trong executor
+type SettleCall struct {
+	// Kind là "resolve" hoặc "reject"
+	Kind string
+	// Caller là vị trí (file:line) gọi resolve/reject
+	Caller string
+}
+
+// DoubleSettleInfo mô tả executor gọi resolve/reject nhiều lần
+// First là lần gọi đã settle Promise, Second là lần gọi bị bỏ qua
+type DoubleSettleInfo struct {
+	Label  string
+	First  SettleCall
+	Second SettleCall
+}
+
+// WithDoubleSettleHook gọi hook mỗi khi executor gọi resolve/reject sau khi Promise đã settle
+// thay vì âm thầm bỏ qua, giúp bắt lỗi logic trong executor phức tạp
+func WithDoubleSettleHook(hook func(DoubleSettleInfo)) PromiseOption {
+	return func(c *promiseConfig) {
+		c.onDoubleSettle = hook
+	}
+}
+
+// executorSettler settle Promise cho resolve/reject của executor
+// và ghi lại call site khi cần báo double settle
+type executorSettler[T any] struct {
+	p        *Promise[T]
+	onDouble func(DoubleSettleInfo)
+
+	mu    sync.Mutex
+	first SettleCall
+}
+
+// settle settle Promise, báo hook nếu Promise đã được settle trước đó
+func (s *executorSettler[T]) settle(kind string, result Result[T]) {
+	if s.onDouble == nil {
+		s.p.settle(result)
+		return
+	}
+
+	// skip: callerLocation, settle, resolve/reject closure
+	call := SettleCall{Kind: kind, Caller: callerLocation(3)}
+
+	s.mu.Lock()
+	if s.p.settle(result) {
+		s.first = call
+		s.mu.Unlock()
+		return
+	}
+	first := s.first
+	s.mu.Unlock()
+
+	s.onDouble(DoubleSettleInfo{Label: s.p.Label(), First: first, Second: call})
+}
@@ -0,0 +1,67 @@
+package promise2
+
+import (
+	"context"
+	"time"
+)
+
+// ShadowReport so sánh một lần chạy của primary và candidate trong Shadow
+type ShadowReport[T any] struct {
+	Primary   Result[T]
+	Candidate Result[T]
+	// Match là true khi cả hai cùng lỗi, hoặc cùng thành công và compare trả về true
+	Match bool
+	// PrimaryLatency và CandidateLatency là thời gian chạy của từng bên
+	PrimaryLatency   time.Duration
+	CandidateLatency time.Duration
+}
+
+// LatencyDelta trả về candidate chậm hơn primary bao nhiêu (âm nếu nhanh hơn)
+func (r ShadowReport[T]) LatencyDelta() time.Duration {
+	return r.CandidateLatency - r.PrimaryLatency
+}
+
+// Shadow chạy song song primary và candidate nhưng chỉ trả về kết quả của primary,
+// để kiểm chứng bản viết lại của một async operation trên traffic thật
+// Promise settle ngay khi primary xong, không chờ candidate; report (có thể nil) được gọi
+// trên goroutine riêng khi cả hai đã xong. Panic của mỗi bên được tính là lỗi của bên đó
+func Shadow[T any](
+	ctx context.Context,
+	primary, candidate func(ctx context.Context) (T, error),
+	compare func(a, b T) bool,
+	report func(ShadowReport[T]),
+) *Promise[T] {
+	timed := func(fn func(ctx context.Context) (T, error)) (Result[T], time.Duration) {
+		start := time.Now()
+		result := recovered(RejectOnPanic(), func() (T, error) { return fn(ctx) })
+		return result, time.Since(start)
+	}
+
+	shadowDone := make(chan ShadowReport[T], 1)
+	go func() {
+		r := ShadowReport[T]{}
+		r.Candidate, r.CandidateLatency = timed(candidate)
+		shadowDone <- r
+	}()
+
+	q := NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
+		result, latency := timed(primary)
+		go func() {
+			r := <-shadowDone
+			r.Primary, r.PrimaryLatency = result, latency
+			r.Match = (result.Err == nil) == (r.Candidate.Err == nil) &&
+				(result.Err != nil || compare(result.Value, r.Candidate.Value))
+			if report != nil {
+				report(r)
+			}
+		}()
+
+		if result.Err != nil {
+			reject(result.Err)
+			return
+		}
+		resolve(result.Value)
+	})
+	q.node.setOp("Shadow")
+	return q
+}
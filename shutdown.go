@@ -0,0 +1,105 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ShutdownReport mô tả những gì xảy ra với các task trong lúc Shutdown
+type ShutdownReport struct {
+	// Completed là số task chạy xong trong lúc shutdown
+	Completed int
+	// Abandoned là số task bị reject mà không được chạy
+	Abandoned int
+	// Duration là thời gian shutdown
+	Duration time.Duration
+	// Errors chứa lỗi của các task chạy xong với lỗi, và ctx.Err() nếu hết hạn
+	Errors []error
+}
+
+// shutdownRecorder gom số liệu cho ShutdownReport
+type shutdownRecorder struct {
+	mu     sync.Mutex
+	report ShutdownReport
+}
+
+// Shutdown đóng pool như Close và trả về ShutdownReport
+// Nếu ctx kết thúc trước khi các task xong, task còn trong queue bị reject với
+// ErrPoolShutdown (bọc ErrTaskCancelled), ctx của task SubmitCtx đang chạy bị hủy, và Shutdown trả về
+// ngay mà không chờ các task đang chạy
+func (p *WorkerPool[T]) Shutdown(ctx context.Context) ShutdownReport {
+	start := time.Now()
+	rec := &shutdownRecorder{}
+	p.shutdown.Store(rec)
+
+	p.beginClose()
+
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	var expired error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		expired = ctx.Err()
+		p.expired.Store(true)
+		p.cancelQueuedScope()
+		p.cancelRunScope()
+		// Reject ngay task còn trong queue thay vì chờ worker lấy ra,
+		// để chúng được tính vào Abandoned trước khi tách recorder
+		p.rejectQueued()
+	}
+
+	p.shutdown.CompareAndSwap(rec, nil)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	report := rec.report
+	report.Duration = time.Since(start)
+	if expired != nil {
+		report.Errors = append(report.Errors, expired)
+	}
+	return report
+}
+
+// rejectQueued reject mọi task còn trong các queue đã đóng bởi beginClose
+func (p *WorkerPool[T]) rejectQueued() {
+	p.mu.RLock()
+	queues := append([]chan task[T]{p.taskQueue}, p.affinity...)
+	for _, q := range p.reserved {
+		queues = append(queues, q)
+	}
+	p.mu.RUnlock()
+
+	for _, q := range queues {
+		for t := range q {
+			p.reject(t, p.cancelledErr())
+		}
+	}
+}
+
+// recordShutdown ghi nhận task xong (hoặc bị bỏ) vào ShutdownReport nếu pool đang shutdown
+func (p *WorkerPool[T]) recordShutdown(err error, abandoned bool) {
+	rec := p.shutdown.Load()
+	if rec == nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	switch {
+	case abandoned:
+		rec.report.Abandoned++
+	case err != nil:
+		rec.report.Completed++
+		rec.report.Errors = append(rec.report.Errors, err)
+	default:
+		rec.report.Completed++
+	}
+}
@@ -0,0 +1,73 @@
+package promise2
+
+import "time"
+
+// ResultSink nhận thông tin mỗi lần Promise settle: label, thời gian từ lúc tạo
+// Promise đến khi settle và lỗi (nil nếu fulfilled)
+// Dùng để đưa số liệu ra hệ thống observability mà không phải sửa code nghiệp vụ
+type ResultSink func(label string, dur time.Duration, err error)
+
+// WithResultSink báo mỗi lần settle của Promise tạo bởi NewPromise/NewPromiseWithExecutor cho sink
+// Với NewWorkerPool giống WithPoolResultSink; với Submit chỉ áp dụng cho Promise của task đó
+func WithResultSink(sink ResultSink) Option {
+	return sharedOption{
+		promise: func(c *promiseConfig) { c.sink = sink },
+		pool:    func(c *poolConfig) { c.sink = sink },
+		task:    func(c *taskConfig) { c.sink = sink },
+	}
+}
+
+// WithPoolResultSink báo mỗi lần settle của Promise do pool trả về cho sink,
+// kể cả task bị reject trước khi chạy (pool đóng, bị shed, bị hủy)
+func WithPoolResultSink(sink ResultSink) PoolOption {
+	return func(c *poolConfig) {
+		c.sink = sink
+	}
+}
+
+// Tracer mở một span với label của Promise khi Promise được tạo (task: khi được submit)
+// và trả về hàm đóng span, được gọi với lỗi (nil nếu fulfilled) khi Promise settle
+// Dùng để nối Promise vào hệ thống tracing (OpenTelemetry, ...) mà không phụ thuộc thư viện cụ thể
+type Tracer func(label string) (end func(err error))
+
+// WithTracer mở span bằng tracer cho Promise tạo bởi NewPromise/NewPromiseWithExecutor,
+// combinator (qua WithOptions) hoặc Submit; với NewWorkerPool áp dụng cho mọi task được submit
+// Giống ResultSink, tracer không được gọi khi build với tag promise2lean
+func WithTracer(tracer Tracer) Option {
+	return sharedOption{
+		promise: func(c *promiseConfig) { c.tracer = tracer },
+		pool:    func(c *poolConfig) { c.tracer = tracer },
+		task:    func(c *taskConfig) { c.tracer = tracer },
+	}
+}
+
+// trace mở span bằng tracer và đóng span qua sink khi p settle
+// Gọi sau khi đã đặt label và sink của p
+func (p *Promise[T]) trace(tracer Tracer) {
+	if leanMode || tracer == nil {
+		return
+	}
+
+	end := tracer(p.Label())
+	sink := p.sink
+	p.sink = func(label string, dur time.Duration, err error) {
+		if sink != nil {
+			sink(label, dur, err)
+		}
+		end(err)
+	}
+}
+
+// newTaskPromise tạo Promise cho task của pool
+func (p *WorkerPool[T]) newTaskPromise() *Promise[T] {
+	promise := newPromise[T]()
+	promise.sink = p.sink
+	return promise
+}
+
+// settledPromise tạo Promise của pool đã có sẵn kết quả
+func (p *WorkerPool[T]) settledPromise(result Result[T]) *Promise[T] {
+	promise := p.newTaskPromise()
+	promise.settle(result)
+	return promise
+}
@@ -0,0 +1,120 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// submitterCtx là key của context chứa tên submitter
+type submitterCtx struct{}
+
+// WithSubmitter gắn tên submitter (subsystem gửi task) vào ctx
+// Task SubmitCtx với ctx này được thống kê riêng trong PoolStats.Submitters
+func WithSubmitter(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, submitterCtx{}, name)
+}
+
+// SubmitterFromContext trả về tên submitter đã gắn bằng WithSubmitter
+func SubmitterFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(submitterCtx{}).(string)
+	return name, ok
+}
+
+// SubmitterStats là thống kê task của một submitter
+type SubmitterStats struct {
+	Queued  int
+	Running int
+	// Completed là số task chạy xong thành công
+	Completed uint64
+	// Failed là số task chạy xong với lỗi
+	Failed uint64
+}
+
+// tagCounter giữ các bộ đếm của một submitter
+type tagCounter struct {
+	queued    atomic.Int32
+	running   atomic.Int32
+	completed atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// tagCounters giữ bộ đếm theo submitter, tag rỗng không được thống kê
+type tagCounters struct {
+	counters sync.Map // map[string]*tagCounter
+}
+
+// get trả về bộ đếm của tag, nil nếu tag rỗng
+func (c *tagCounters) get(tag string) *tagCounter {
+	if tag == "" {
+		return nil
+	}
+	if counter, ok := c.counters.Load(tag); ok {
+		return counter.(*tagCounter)
+	}
+	counter, _ := c.counters.LoadOrStore(tag, &tagCounter{})
+	return counter.(*tagCounter)
+}
+
+// enqueued ghi nhận task của tag vào queue
+func (c *tagCounters) enqueued(tag string) {
+	if counter := c.get(tag); counter != nil {
+		counter.queued.Add(1)
+	}
+}
+
+// dequeued ghi nhận task của tag rời queue mà không chạy (bị hủy, pool đóng)
+func (c *tagCounters) dequeued(tag string) {
+	if counter := c.get(tag); counter != nil {
+		counter.queued.Add(-1)
+	}
+}
+
+// started ghi nhận task của tag bắt đầu chạy
+func (c *tagCounters) started(tag string) {
+	if counter := c.get(tag); counter != nil {
+		counter.queued.Add(-1)
+		counter.running.Add(1)
+	}
+}
+
+// requeued ghi nhận task của tag đang chạy được đưa lại queue
+func (c *tagCounters) requeued(tag string) {
+	if counter := c.get(tag); counter != nil {
+		counter.running.Add(-1)
+		counter.queued.Add(1)
+	}
+}
+
+// finished ghi nhận task của tag chạy xong
+func (c *tagCounters) finished(tag string, err error) {
+	counter := c.get(tag)
+	if counter == nil {
+		return
+	}
+	counter.running.Add(-1)
+	if err != nil {
+		counter.failed.Add(1)
+	} else {
+		counter.completed.Add(1)
+	}
+}
+
+// snapshot trả về thống kê của mọi submitter, nil nếu chưa có task nào được tag
+func (c *tagCounters) snapshot() map[string]SubmitterStats {
+	var stats map[string]SubmitterStats
+	c.counters.Range(func(key, value any) bool {
+		if stats == nil {
+			stats = make(map[string]SubmitterStats)
+		}
+		counter := value.(*tagCounter)
+		stats[key.(string)] = SubmitterStats{
+			Queued:    int(counter.queued.Load()),
+			Running:   int(counter.running.Load()),
+			Completed: counter.completed.Load(),
+			Failed:    counter.failed.Load(),
+		}
+		return true
+	})
+	return stats
+}
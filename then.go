@@ -0,0 +1,66 @@
+package promise2
+
+import "context"
+
+// Then nối fn sau p và đổi kiểu kết quả từ T sang U, bổ sung cho method Then/Map
+// vốn chỉ trả về cùng kiểu T (Go không cho method có type parameter riêng)
+// Lỗi của p được chuyển thẳng, fn không chạy; chain gắn On/OnLoop vẫn giữ executor đó
+func Then[T, U any](p *Promise[T], fn func(T) (U, error)) *Promise[U] {
+	return derive(p, "Then", func(val T, err error) (U, error) {
+		if err != nil {
+			var zero U
+			return zero, err
+		}
+		return fn(val)
+	})
+}
+
+// MapTo giống Then cho fn không trả về lỗi
+func MapTo[T, U any](p *Promise[T], fn func(T) U) *Promise[U] {
+	q := Then(p, func(val T) (U, error) {
+		return fn(val), nil
+	})
+	q.node.setOp("MapTo")
+	return q
+}
+
+// FlatMap nối fn trả về Promise sau p, kết quả là Promise phẳng *Promise[U] thay vì
+// Promise[*Promise[U]]: settle khi Promise do fn trả về settle
+// Dùng để nối các bước bất đồng bộ (gọi HTTP rồi ghi DB); fn trả về nil thì reject với ErrNilPromise
+// Như các stage khác, stage bị hủy (WithContext) trước khi p settle thì fn không chạy;
+// bị hủy khi Promise của fn còn chạy thì việc hủy được chuyển tiếp vào Promise đó
+func FlatMap[T, U any](p *Promise[T], fn func(T) *Promise[U]) *Promise[U] {
+	q := newPromise[U]()
+	q.node.link("FlatMap", p.node)
+	q.runner = p.runner
+	q.upstream = p.consumer()
+
+	p.schedule(func() {
+		val, err := p.await(context.Background())
+		if q.State() != StatusPending {
+			return
+		}
+		if err != nil {
+			q.settle(Result[U]{Err: err})
+			return
+		}
+
+		next := fn(val)
+		if next == nil {
+			q.settle(Result[U]{Err: ErrNilPromise})
+			return
+		}
+		// q là một stage nối sau next: q bị hủy thì next cũng bị hủy nếu không còn stage nào khác dùng
+		release := next.consumer()
+		q.onSettle(func() { release(q.result.Err) })
+
+		// Chờ bằng callback để không giữ worker/EventLoop của On/OnLoop trong lúc next chạy
+		next.onSettle(func() {
+			go func() {
+				val, err := next.await(context.Background())
+				q.settle(Result[U]{Value: val, Err: err})
+			}()
+		})
+	})
+	return q
+}
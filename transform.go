@@ -0,0 +1,27 @@
+package promise2
+
+import "fmt"
+
+// WithResultTransform áp dụng fn cho kết quả của mọi task chạy trên pool trước khi
+// Promise settle, ví dụ để chuẩn hóa lỗi hoặc che giá trị nhạy cảm trước khi
+// chúng đến result sink, thống kê submitter hay caller
+// T phải trùng với kiểu của pool, nếu không NewWorkerPool sẽ panic
+func WithResultTransform[T any](fn func(Result[T]) Result[T]) PoolOption {
+	return func(c *poolConfig) {
+		c.transform = fn
+	}
+}
+
+// resultTransform lấy transform của WithResultTransform cho pool kiểu T
+func resultTransform[T any](cfg *poolConfig) func(Result[T]) Result[T] {
+	if cfg.transform == nil {
+		return nil
+	}
+
+	fn, ok := cfg.transform.(func(Result[T]) Result[T])
+	if !ok {
+		var zero T
+		panic(fmt.Sprintf("promise2: WithResultTransform type %T does not match WorkerPool[%T]", cfg.transform, zero))
+	}
+	return fn
+}
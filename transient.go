@@ -0,0 +1,49 @@
+package promise2
+
+import (
+	"context"
+	"errors"
+
+	"github.com/phucps89/go-promise2/backoff"
+)
+
+// TransientFunc trả về true nếu err là lỗi tạm thời (timeout, 5xx, ...) đáng để
+// thử lại hoặc chuyển sang nguồn khác, false nếu là lỗi vĩnh viễn
+type TransientFunc func(err error) bool
+
+// transientCtx là key của context chứa TransientFunc
+type transientCtx struct{}
+
+// WithTransientErrors gắn cách phân loại lỗi tạm thời/vĩnh viễn vào ctx
+// Any, Fallback, Retry và retry của SubmitCtx chạy với ctx này sẽ:
+//   - Any: reject ngay khi gặp lỗi vĩnh viễn thay vì chờ các promise khác
+//   - Fallback: chỉ chuyển sang nguồn tiếp theo khi lỗi là tạm thời
+//   - Retry/SubmitCtx: không retry lỗi vĩnh viễn
+func WithTransientErrors(ctx context.Context, transient TransientFunc) context.Context {
+	return context.WithValue(ctx, transientCtx{}, transient)
+}
+
+// IsTransient là TransientFunc mặc định: timeout, bị shed, lỗi mang Retry-After
+// hoặc lỗi có method Temporary() bool trả về true
+func IsTransient(err error) bool {
+	switch ClassOf(err) {
+	case ClassTimeout, ClassShed:
+		return true
+	}
+	if _, ok := backoff.DelayFromError(err); ok {
+		return true
+	}
+
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
+
+// permanent cho biết err là lỗi vĩnh viễn theo TransientFunc trong ctx
+// Khi ctx không có TransientFunc, mọi lỗi được coi là tạm thời (hành vi cũ)
+func permanent(ctx context.Context, err error) bool {
+	transient, ok := ctx.Value(transientCtx{}).(TransientFunc)
+	if !ok || transient == nil {
+		return false
+	}
+	return !transient(err)
+}
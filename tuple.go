@@ -0,0 +1,77 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+)
+
+// Tuple2 là cặp giá trị kết quả của Join2
+type Tuple2[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Promise2 là Promise của hai giá trị khác kiểu, Await trả về cả hai
+// Dùng cho trường hợp "lấy hai thứ song song" mà không cần định nghĩa struct riêng
+type Promise2[A, B any] struct {
+	p *Promise[Tuple2[A, B]]
+}
+
+// Join2 chờ cả pa và pb fulfill, giống All cho hai Promise khác kiểu
+// Nếu một trong hai reject, Promise2 reject ngay với lỗi bọc index và label như All
+func Join2[A, B any](ctx context.Context, pa *Promise[A], pb *Promise[B]) *Promise2[A, B] {
+	q := NewPromiseWithExecutor[Tuple2[A, B]](func(resolve func(Tuple2[A, B]), reject func(error)) {
+		var result Tuple2[A, B]
+		var errOnce sync.Once
+		var wg sync.WaitGroup
+		wg.Add(2)
+
+		fail := func(err error) {
+			errOnce.Do(func() {
+				reject(err)
+			})
+		}
+
+		go func() {
+			defer wg.Done()
+			val, err := pa.await(ctx)
+			if err != nil {
+				fail(indexedError(0, pa, err))
+				return
+			}
+			result.First = val
+		}()
+		go func() {
+			defer wg.Done()
+			val, err := pb.await(ctx)
+			if err != nil {
+				fail(indexedError(1, pb, err))
+				return
+			}
+			result.Second = val
+		}()
+
+		go func() {
+			wg.Wait()
+			resolve(result)
+		}()
+	})
+	q.node.link("Join2", pa.node, pb.node)
+	return &Promise2[A, B]{p: q}
+}
+
+// Await chờ kết quả và trả về cả hai giá trị
+func (p *Promise2[A, B]) Await(ctx context.Context) (A, B, error) {
+	t, err := p.p.Await(ctx)
+	return t.First, t.Second, err
+}
+
+// Promise trả về Promise của Tuple2 để dùng với Then/Map/All và các combinator khác
+func (p *Promise2[A, B]) Promise() *Promise[Tuple2[A, B]] {
+	return p.p
+}
+
+// Done trả về channel được đóng khi Promise2 settle
+func (p *Promise2[A, B]) Done() <-chan struct{} {
+	return p.p.Done()
+}
@@ -3,6 +3,8 @@ package promise2
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Result chứa kết quả hoặc lỗi của một task
@@ -11,138 +13,503 @@ type Result[T any] struct {
 	Err   error
 }
 
+// AnyPromise là interface không generic được implement bởi *Promise[T]
+// Cho phép registries, trackers, công cụ debug và WaitAll giữ các Promise khác kiểu
+type AnyPromise interface {
+	// StateAny trả về trạng thái hiện tại của Promise
+	StateAny() Status
+	// AwaitAny chờ kết quả, giá trị được trả về dưới dạng any
+	AwaitAny(ctx context.Context) (any, error)
+	// Done trả về channel được đóng khi Promise settle
+	Done() <-chan struct{}
+
+	debugInfo() *debugNode
+}
+
 // Promise là một wrapper cho async operation
+// Kết quả được lưu lại khi settle nên có thể Await nhiều lần
 type Promise[T any] struct {
-	resultChan chan Result[T]
-	once       sync.Once
+	done      chan struct{}
+	result    Result[T]
+	once      sync.Once
+	createdAt time.Time
+
+	mu    sync.Mutex
+	label string
+	node  *debugNode
+
+	// queued và watcher chỉ được dùng cho Promise của pool bật phát hiện deadlock
+	queued  atomic.Bool
+	watcher deadlockWatcher
+
+	// onAwait được gọi khi kết quả được Await (dùng để trả memory budget của pool)
+	onAwait func()
+	// sink được báo khi Promise settle (xem WithResultSink)
+	sink ResultSink
+	// callbacks được gọi sau khi Promise settle (xem onSettle), fired báo đã gọi xong
+	callbacks []func()
+	fired     bool
+	// runner chạy các bước Then/Map/Catch/Finally nối sau Promise (xem On), nil là goroutine mới
+	runner func(step func())
+	// lazy bắt đầu chạy Promise của NewLazyPromise, nil với Promise thường
+	lazy func()
+	// abort hủy ctx của công việc bên dưới (NewPromiseCtx, SubmitCtx), nil nếu không hủy được
+	abort context.CancelFunc
+	// upstream báo stage phía trước trong chain (Then, Map, ...) rằng stage này bị hủy,
+	// nil với Promise gốc; consumers là số stage nối sau chưa bị hủy (xem consumer)
+	upstream  func(err error)
+	consumers int
+}
+
+// newPromise tạo một Promise chưa settle
+func newPromise[T any]() *Promise[T] {
+	p := &Promise[T]{done: make(chan struct{})}
+	if leanMode {
+		return p
+	}
+	p.createdAt = time.Now()
+	if debugEnabled.Load() {
+		p.node = newDebugNode("Promise", p.createdAt, func(err error) bool {
+			return p.settle(Result[T]{Err: err})
+		})
+	}
+	return p
+}
+
+// settle lưu kết quả của Promise, chỉ lần gọi đầu tiên có hiệu lực
+func (p *Promise[T]) settle(result Result[T]) bool {
+	settled := false
+	p.once.Do(func() {
+		p.result = result
+		p.node.settled(result.Err)
+		// Báo sink trước khi đóng done để caller Await xong luôn thấy số liệu đã được ghi
+		if !leanMode && p.sink != nil {
+			p.sink(p.Label(), time.Since(p.createdAt), result.Err)
+		}
+		close(p.done)
+		settled = true
+
+		p.mu.Lock()
+		callbacks := p.callbacks
+		p.callbacks = nil
+		p.fired = true
+		p.mu.Unlock()
+		for _, fn := range callbacks {
+			fn()
+		}
+	})
+	return settled
+}
+
+// onSettle đăng ký fn được gọi trên goroutine settle Promise, ngay sau khi done đóng
+// Nếu Promise đã settle, fn được gọi ngay. fn phải ngắn và không block
+func (p *Promise[T]) onSettle(fn func()) {
+	p.Start()
+
+	p.mu.Lock()
+	if !p.fired {
+		p.callbacks = append(p.callbacks, fn)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	fn()
 }
 
 // NewPromise tạo một Promise mới
-func NewPromise[T any](fn func() (T, error)) *Promise[T] {
-	p := &Promise[T]{
-		resultChan: make(chan Result[T], 1),
+func NewPromise[T any](fn func() (T, error), opts ...Option) *Promise[T] {
+	cfg := newPromiseConfig(opts)
+	p := newPromise[T]()
+	p.node.setOp("NewPromise")
+	applyPromiseConfig(p, cfg)
+
+	go runPromise(p, cfg, fn)
+
+	return p
+}
+
+// NewLazyPromise giống NewPromise nhưng fn chỉ chạy khi Promise được dùng lần đầu
+// (Await, Done, Start, nối chain hay đưa vào combinator), nên Sequence và các nhánh
+// điều kiện thực sự hoãn được công việc. WithTimeout tính từ lúc bắt đầu chạy
+func NewLazyPromise[T any](fn func() (T, error), opts ...Option) *Promise[T] {
+	cfg := newPromiseConfig(opts)
+	timeout := cfg.timeout
+	cfg.timeout = 0
+	p := newPromise[T]()
+	p.node.setOp("NewLazyPromise")
+	applyPromiseConfig(p, cfg)
+
+	var once sync.Once
+	p.lazy = func() {
+		once.Do(func() {
+			p.expireAfter(timeout)
+			go runPromise(p, cfg, fn)
+		})
 	}
+	return p
+}
 
-	go func() {
-		val, err := fn()
-		p.resultChan <- Result[T]{Value: val, Err: err}
-	}()
+// runPromise chạy fn theo cfg (recover, retry) rồi settle p
+// Panic được recover thành *PanicError nếu cfg không có RecoverPolicy riêng
+func runPromise[T any](p *Promise[T], cfg promiseConfig, fn func() (T, error)) {
+	if cfg.recover == nil && cfg.retry == nil {
+		p.settle(recoverPanic(fn))
+		return
+	}
+
+	run := func() Result[T] {
+		if cfg.recover != nil {
+			return recovered(*cfg.recover, fn)
+		}
+		return recoverPanic(fn)
+	}
 
+	if cfg.retry != nil {
+		p.settle(retryLoop(context.Background(), cfg.retry, p.done, run))
+		return
+	}
+	p.settle(run())
+}
+
+// Start chạy function của Promise tạo bằng NewLazyPromise nếu chưa chạy
+// Không làm gì với các Promise khác, vốn đã chạy ngay khi được tạo
+func (p *Promise[T]) Start() *Promise[T] {
+	if p.lazy != nil {
+		p.lazy()
+	}
+	return p
+}
+
+// newSettledPromise tạo một Promise đã có sẵn kết quả, không cần goroutine
+func newSettledPromise[T any](result Result[T]) *Promise[T] {
+	p := newPromise[T]()
+	p.settle(result)
 	return p
 }
 
 // NewPromiseWithExecutor tạo một Promise với executor function
-// Executor nhận resolve và reject callbacks
+// Executor nhận resolve và reject callbacks; executor panic trước khi settle thì
+// Promise bị reject với *PanicError thay vì pending mãi
 func NewPromiseWithExecutor[T any](
 	executor func(resolve func(T), reject func(error)),
+	opts ...Option,
 ) *Promise[T] {
-	p := &Promise[T]{
-		resultChan: make(chan Result[T], 1),
+	cfg := newPromiseConfig(opts)
+	p := newPromise[T]()
+	p.node.setOp("NewPromiseWithExecutor")
+	applyPromiseConfig(p, cfg)
+
+	var caller string
+	if cfg.checkUnsettled {
+		caller = callerLocation(2)
 	}
 
+	settler := &executorSettler[T]{p: p, onDouble: cfg.onDoubleSettle}
+
 	go func() {
 		resolve := func(val T) {
-			p.once.Do(func() {
-				p.resultChan <- Result[T]{Value: val, Err: nil}
-			})
+			settler.settle("resolve", Result[T]{Value: val})
 		}
 
 		reject := func(err error) {
-			p.once.Do(func() {
-				p.resultChan <- Result[T]{Err: err}
-			})
+			settler.settle("reject", Result[T]{Err: err})
 		}
 
-		executor(resolve, reject)
+		if r := recoverPanic(func() (struct{}, error) {
+			executor(resolve, reject)
+			return struct{}{}, nil
+		}); r.Err != nil {
+			p.settle(Result[T]{Err: r.Err})
+		}
+
+		if cfg.checkUnsettled && p.State() == StatusPending {
+			p.reportUnsettled(cfg.onUnsettled, caller)
+		}
 	}()
 
 	return p
 }
 
+// Resolve trả về Promise đã fulfill với value mà không tạo goroutine
+// Dùng để stub dependency trong test hoặc trả ngay giá trị đã cache
+func Resolve[T any](value T) *Promise[T] {
+	p := newSettledPromise(Result[T]{Value: value})
+	p.node.setOp("Resolve")
+	return p
+}
+
+// Reject trả về Promise đã reject với err mà không tạo goroutine
+func Reject[T any](err error) *Promise[T] {
+	p := newSettledPromise(Result[T]{Err: err})
+	p.node.setOp("Reject")
+	return p
+}
+
+// State trả về trạng thái hiện tại của Promise
+func (p *Promise[T]) State() Status {
+	select {
+	case <-p.done:
+		if p.result.Err != nil {
+			return StatusRejected
+		}
+		return StatusFulfilled
+	default:
+		return StatusPending
+	}
+}
+
+// StateAny giống State, dùng cho AnyPromise
+func (p *Promise[T]) StateAny() Status {
+	return p.State()
+}
+
+// AwaitAny giống Await nhưng trả về giá trị dạng any, dùng cho AnyPromise
+func (p *Promise[T]) AwaitAny(ctx context.Context) (any, error) {
+	return p.Await(ctx)
+}
+
+// Done trả về channel được đóng khi Promise settle
+func (p *Promise[T]) Done() <-chan struct{} {
+	p.Start()
+	return p.done
+}
+
+// SetLabel đặt tên cho Promise để lỗi và công cụ debug dễ đọc hơn
+func (p *Promise[T]) SetLabel(label string) *Promise[T] {
+	if leanMode {
+		return p
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.label = label
+	p.node.setLabel(label)
+	return p
+}
+
+// Label trả về tên của Promise (rỗng nếu chưa đặt)
+func (p *Promise[T]) Label() string {
+	if leanMode {
+		return ""
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.label
+}
+
 // Await chờ kết quả của Promise
+// Nếu ctx bị cancel hoặc hết hạn trước, lỗi trả về là *AwaitError bọc ctx.Err();
+// Promise vẫn chạy tiếp và có thể Await lại (hủy cả chain dùng WithContext)
 func (p *Promise[T]) Await(ctx context.Context) (T, error) {
+	val, err := p.wait(ctx)
+	if rp, ok := err.(*repanicError); ok {
+		panic(rp.panic.Value)
+	}
+	return val, err
+}
+
+// await giống Await nhưng không panic lại với RepanicOnAwait mà trả về *PanicError
+// Dùng trong goroutine của thư viện (chain, combinator), nơi không có ai recover panic
+func (p *Promise[T]) await(ctx context.Context) (T, error) {
+	val, err := p.wait(ctx)
+	if rp, ok := err.(*repanicError); ok {
+		return val, rp.panic
+	}
+	return val, err
+}
+
+// wait chờ kết quả của Promise cho Await và await
+func (p *Promise[T]) wait(ctx context.Context) (T, error) {
+	p.Start()
+	if p.watcher != nil {
+		if err := p.watcher.watchAwait(ctx, p.done, &p.queued, p.Label()); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+
 	select {
-	case result := <-p.resultChan:
-		return result.Value, result.Err
+	case <-p.done:
+		return p.take()
 	case <-ctx.Done():
 		var zero T
-		return zero, ctx.Err()
+		err := &AwaitError{Label: p.Label(), Err: ctx.Err()}
+		if !leanMode {
+			err.Pending = time.Since(p.createdAt)
+		}
+		return zero, err
 	}
 }
 
-// Then chuỗi Promise - thực thi fn khi Promise hiện tại hoàn thành
-func (p *Promise[T]) Then(fn func(T) error) *Promise[T] {
-	return NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
-		go func() {
-			val, err := p.Await(context.Background())
-			if err != nil {
-				reject(err)
-				return
-			}
+// take trả về kết quả của Promise đã settle, lỗi repanic được giữ nguyên
+func (p *Promise[T]) take() (T, error) {
+	if p.onAwait != nil {
+		p.onAwait()
+	}
+	return p.result.Value, p.result.Err
+}
 
-			if err := fn(val); err != nil {
-				reject(err)
-				return
-			}
+// consume trả về kết quả của Promise đã settle như Await
+func (p *Promise[T]) consume() (T, error) {
+	val, err := p.take()
+	if rp, ok := err.(*repanicError); ok {
+		panic(rp.panic.Value)
+	}
+	return val, err
+}
+
+// derive tạo stage op nối sau p: step nhận kết quả của p và trả về kết quả của stage
+// Stage bị hủy (WithContext) trước khi p settle thì step không chạy
+// và việc hủy được chuyển tiếp lên p (xem cancelChain)
+func derive[T, U any](p *Promise[T], op string, step func(val T, err error) (U, error)) *Promise[U] {
+	q := newPromise[U]()
+	q.node.link(op, p.node)
+	q.runner = p.runner
+	q.upstream = p.consumer()
 
-			resolve(val)
-		}()
+	p.schedule(func() {
+		val, err := p.await(context.Background())
+		if q.State() != StatusPending {
+			return
+		}
+		out, err := step(val, err)
+		q.settle(Result[U]{Value: out, Err: err})
 	})
+	return q
 }
 
-// Map chuyển đổi giá trị của Promise
-func (p *Promise[T]) Map(fn func(T) (T, error)) *Promise[T] {
-	return NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
-		go func() {
-			val, err := p.Await(context.Background())
-			if err != nil {
-				reject(err)
-				return
-			}
+// consumer đăng ký một stage nối sau p, trả về hàm stage gọi khi bị hủy
+// p chỉ bị hủy khi mọi stage nối sau đều đã bị hủy, nên nhánh khác dùng chung p không bị ảnh hưởng
+func (p *Promise[T]) consumer() func(err error) {
+	p.mu.Lock()
+	p.consumers++
+	p.mu.Unlock()
 
-			newVal, err := fn(val)
-			if err != nil {
-				reject(err)
-				return
-			}
+	return func(err error) {
+		p.mu.Lock()
+		p.consumers--
+		last := p.consumers == 0
+		p.mu.Unlock()
+
+		if last {
+			p.cancelChain(err)
+		}
+	}
+}
 
-			resolve(newVal)
-		}()
+// cancelChain reject p với err nếu p chưa settle, rồi hủy công việc bên dưới và báo stage phía trước
+func (p *Promise[T]) cancelChain(err error) {
+	if !p.settle(Result[T]{Err: err}) {
+		return
+	}
+	if p.abort != nil {
+		p.abort()
+	}
+	if p.upstream != nil {
+		p.upstream(err)
+	}
+}
+
+// WithContext trả về stage settle theo p, hoặc reject với lỗi của ctx nếu ctx kết thúc trước
+// Khi đó chain phía trước bị hủy tới stage còn nhánh khác dùng: các stage chưa chạy bị bỏ qua
+// và Promise gốc chạy với ctx (NewPromiseCtx, SubmitCtx) bị hủy ctx
+// Caller Await trực tiếp một stage phía trước không được tính là nhánh, chỉ stage nối sau
+//
+//	user, err := fetch.Map(enrich).Then(save).WithContext(reqCtx).Await(reqCtx)
+func (p *Promise[T]) WithContext(ctx context.Context) *Promise[T] {
+	q := derive(p, "WithContext", func(val T, err error) (T, error) {
+		return val, err
+	})
+	stop := context.AfterFunc(ctx, func() {
+		q.cancelChain(ctx.Err())
 	})
+	q.onSettle(func() { stop() })
+	return q
 }
 
-// Catch xử lý lỗi của Promise
-func (p *Promise[T]) Catch(fn func(error) (T, error)) *Promise[T] {
-	return NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
-		go func() {
-			val, err := p.Await(context.Background())
-			if err == nil {
-				resolve(val)
-				return
-			}
+// Then chuỗi Promise - thực thi fn khi Promise hiện tại hoàn thành
+func (p *Promise[T]) Then(fn func(T) error) *Promise[T] {
+	return derive(p, "Then", func(val T, err error) (T, error) {
+		if err != nil {
+			return val, err
+		}
+		if err := fn(val); err != nil {
+			var zero T
+			return zero, err
+		}
+		return val, nil
+	})
+}
 
-			newVal, err := fn(err)
-			if err != nil {
-				reject(err)
-				return
-			}
+// Map chuyển đổi giá trị của Promise
+func (p *Promise[T]) Map(fn func(T) (T, error)) *Promise[T] {
+	return derive(p, "Map", func(val T, err error) (T, error) {
+		if err != nil {
+			return val, err
+		}
+		return fn(val)
+	})
+}
 
-			resolve(newVal)
-		}()
+// Catch xử lý lỗi của Promise
+func (p *Promise[T]) Catch(fn func(error) (T, error)) *Promise[T] {
+	return derive(p, "Catch", func(val T, err error) (T, error) {
+		if err == nil {
+			return val, nil
+		}
+		return fn(err)
 	})
 }
 
 // Finally thực thi fn dù Promise thành công hay thất bại
 func (p *Promise[T]) Finally(fn func()) *Promise[T] {
-	return NewPromiseWithExecutor[T](func(resolve func(T), reject func(error)) {
-		go func() {
-			val, err := p.Await(context.Background())
-			fn()
-			if err != nil {
-				reject(err)
-				return
-			}
-			resolve(val)
-		}()
+	return derive(p, "Finally", func(val T, err error) (T, error) {
+		fn()
+		return val, err
 	})
 }
+
+// Tap gọi fn với giá trị khi Promise fulfill (log, metrics) rồi trả lại nguyên kết quả
+func (p *Promise[T]) Tap(fn func(T)) *Promise[T] {
+	return derive(p, "Tap", func(val T, err error) (T, error) {
+		if err == nil {
+			fn(val)
+		}
+		return val, err
+	})
+}
+
+// TapErr gọi fn với lỗi khi Promise reject rồi trả lại nguyên kết quả
+func (p *Promise[T]) TapErr(fn func(error)) *Promise[T] {
+	return derive(p, "TapErr", func(val T, err error) (T, error) {
+		if err != nil {
+			fn(err)
+		}
+		return val, err
+	})
+}
+
+// WithTimeout trả về Promise settle theo p, hoặc reject với ErrTimeout nếu p chưa settle sau d
+// Nếu p chạy với ctx (NewPromiseCtx, NewCancelablePromise, pool.SubmitCtx), ctx đó bị hủy
+// khi hết hạn để dừng công việc; với Promise khác công việc vẫn chạy nhưng kết quả bị bỏ
+func (p *Promise[T]) WithTimeout(d time.Duration) *Promise[T] {
+	q := newPromise[T]()
+	q.node.link("WithTimeout", p.node)
+	q.runner = p.runner
+	q.onAwait = p.onAwait
+	q.upstream = p.consumer()
+
+	p.onSettle(func() { q.settle(p.result) })
+	q.expireAfter(d)
+	if p.abort != nil {
+		q.onSettle(func() {
+			if p.State() == StatusPending {
+				p.abort()
+			}
+		})
+	}
+	return q
+}
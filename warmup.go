@@ -0,0 +1,92 @@
+package promise2
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// WithWorkerWarmup chạy fn trên mỗi worker trước khi worker nhận task đầu tiên
+// (ví dụ nạp model hoặc cache theo worker). ctx của fn bị hủy khi pool đóng
+// Worker chỉ nhận task sau khi warmup của nó kết thúc; lỗi warmup được trả về qua Ready
+func WithWorkerWarmup(fn func(ctx context.Context, workerID int) error) PoolOption {
+	return func(c *poolConfig) {
+		c.warmup = fn
+	}
+}
+
+// warmupState theo dõi warmup của các workers
+type warmupState struct {
+	fn      func(ctx context.Context, workerID int) error
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending sync.WaitGroup
+	ready   chan struct{}
+
+	mu   sync.Mutex
+	errs []error
+}
+
+func newWarmupState(fn func(ctx context.Context, workerID int) error, workers int) *warmupState {
+	w := &warmupState{fn: fn, ready: make(chan struct{})}
+	if fn == nil {
+		close(w.ready)
+		return w
+	}
+
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.pending.Add(workers)
+	go func() {
+		w.pending.Wait()
+		close(w.ready)
+	}()
+	return w
+}
+
+// run chạy warmup cho worker id (không làm gì nếu pool không có warmup)
+func (w *warmupState) run(id int) {
+	if w.fn == nil {
+		return
+	}
+	defer w.pending.Done()
+
+	if err := w.fn(w.ctx, id); err != nil {
+		w.mu.Lock()
+		w.errs = append(w.errs, fmt.Errorf("warmup worker %d: %w", id, err))
+		w.mu.Unlock()
+	}
+}
+
+// stop hủy ctx của các warmup đang chạy
+func (w *warmupState) stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+// Ready chờ đến khi warmup của mọi worker kết thúc
+// Trả về lỗi nếu có warmup thất bại, hoặc ctx.Err() nếu ctx kết thúc trước
+// Pool không có WithWorkerWarmup luôn Ready ngay
+func (p *WorkerPool[T]) Ready(ctx context.Context) error {
+	select {
+	case <-p.warmup.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.warmup.err()
+}
+
+// err trả về lỗi của các warmup đã thất bại
+func (w *warmupState) err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch len(w.errs) {
+	case 0:
+		return nil
+	case 1:
+		return w.errs[0]
+	default:
+		return NewAggregateError(w.errs)
+	}
+}
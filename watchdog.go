@@ -0,0 +1,73 @@
+package promise2
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pendingNodes chứa debug node của các Promise chưa settle (chỉ khi debug bật), theo id
+var pendingNodes sync.Map
+
+// StuckPromise mô tả Promise pending lâu hơn ngưỡng của watchdog
+// Ancestry cho thấy toàn bộ chain từ task gốc tới continuation đang pending
+type StuckPromise struct {
+	Pending  time.Duration
+	Ancestry []PromiseFrame
+
+	kill func(err error) bool
+}
+
+// Kill reject Promise bị kẹt với ErrWatchdogKilled để giải phóng những ai đang Await nó
+// Công việc bên dưới không bị dừng; trả về false nếu Promise đã settle trước đó
+func (s StuckPromise) Kill() bool {
+	if s.kill == nil {
+		return false
+	}
+	return s.kill(ErrWatchdogKilled)
+}
+
+// StartWatchdog định kỳ kiểm tra các Promise được tạo khi debug bật (xem EnableDebug)
+// và gọi report một lần cho mỗi Promise pending lâu hơn threshold; dừng khi ctx kết thúc
+func StartWatchdog(ctx context.Context, threshold time.Duration, report func(StuckPromise)) {
+	interval := threshold / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		reported := make(map[uint64]bool)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			seen := make(map[uint64]bool, len(reported))
+			pendingNodes.Range(func(key, value any) bool {
+				id := key.(uint64)
+				node := value.(*debugNode)
+				seen[id] = true
+
+				pending := time.Since(node.created)
+				if reported[id] || pending < threshold {
+					return true
+				}
+				reported[id] = true
+				report(StuckPromise{Pending: pending, Ancestry: node.ancestry(), kill: node.kill})
+				return true
+			})
+
+			// Promise đã settle không cần nhớ nữa
+			for id := range reported {
+				if !seen[id] {
+					delete(reported, id)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,88 @@
+package promise2
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// WithinReport là kết quả của AllWithin
+type WithinReport[T any] struct {
+	// Statuses là trạng thái của từng promise theo thứ tự đầu vào, promise bị hủy
+	// có Status rejected và Err bọc ErrTimeout
+	Statuses []PromiseStatus[T]
+	// Completed là index các promise fulfill trước hạn
+	Completed []int
+	// Failed là index các promise reject trước hạn
+	Failed []int
+	// Cancelled là index các promise chưa settle khi hết hạn
+	Cancelled []int
+}
+
+// Values trả về giá trị của các promise fulfill trước hạn theo thứ tự đầu vào
+func (r WithinReport[T]) Values() []T {
+	values := make([]T, 0, len(r.Completed))
+	for _, i := range r.Completed {
+		values = append(values, r.Statuses[i].Value)
+	}
+	return values
+}
+
+// AllWithin chờ các promise trong tối đa d (hoặc tới khi ctx kết thúc) rồi fulfill với
+// báo cáo promise nào xong, lỗi hay bị hủy; không bao giờ reject
+// Promise chưa settle khi hết hạn bị reject với ErrTimeout giống WithTimeout: ai đang
+// Await nó nhận lỗi ngay, công việc bên dưới vẫn chạy nhưng kết quả bị bỏ
+// Dùng cho batch job có cửa sổ thời gian cố định
+func AllWithin[T any](ctx context.Context, d time.Duration, promises ...*Promise[T]) *Promise[WithinReport[T]] {
+	q := NewPromiseWithExecutor[WithinReport[T]](func(resolve func(WithinReport[T]), reject func(error)) {
+		remaining := atomic.Int64{}
+		remaining.Store(int64(len(promises)))
+		allSettled := make(chan struct{})
+		if len(promises) == 0 {
+			close(allSettled)
+		}
+		for _, p := range promises {
+			p.onSettle(func() {
+				if remaining.Add(-1) == 0 {
+					close(allSettled)
+				}
+			})
+		}
+
+		go func() {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+
+			cause := fmt.Errorf("%w: batch window of %s elapsed", ErrTimeout, d)
+			select {
+			case <-allSettled:
+			case <-timer.C:
+			case <-ctx.Done():
+				cause = fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+			}
+
+			report := WithinReport[T]{Statuses: make([]PromiseStatus[T], len(promises))}
+			for i, p := range promises {
+				// settle chỉ thành công với promise còn pending: đó là promise bị hủy
+				if p.settle(Result[T]{Err: cause}) {
+					report.Statuses[i] = PromiseStatus[T]{Status: StatusRejected, Err: cause}
+					report.Cancelled = append(report.Cancelled, i)
+					continue
+				}
+
+				val, err := p.await(context.Background())
+				if err != nil {
+					report.Statuses[i] = PromiseStatus[T]{Status: StatusRejected, Err: err}
+					report.Failed = append(report.Failed, i)
+					continue
+				}
+				report.Statuses[i] = PromiseStatus[T]{Status: StatusFulfilled, Value: val}
+				report.Completed = append(report.Completed, i)
+			}
+			resolve(report)
+		}()
+	})
+	q.node.link("AllWithin", nodesOf(promises)...)
+	return q
+}
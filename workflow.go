@@ -0,0 +1,179 @@
+package promise2
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// WorkflowEnd là tên bước đặc biệt báo workflow kết thúc
+const WorkflowEnd = ""
+
+// Workflow là state machine gồm các bước có tên, input/output có kiểu
+// và chuyển bước theo kết quả hoặc lỗi, tránh các chuỗi Then/Catch lồng nhau
+//
+//	wf := promise2.NewWorkflow("fetch")
+//	promise2.AddStep(wf, "fetch", fetchUser, func(u User, err error) string {
+//		if errors.Is(err, ErrNotFound) {
+//			return "create"
+//		}
+//		return "notify"
+//	})
+//	promise2.AddStep(wf, "create", createUser, promise2.Next[User]("notify"))
+//	promise2.AddStep(wf, "notify", notify, promise2.Next[string](promise2.WorkflowEnd))
+//	result, err := wf.Run(ctx, userID).Await(ctx)
+type Workflow struct {
+	start    string
+	steps    map[string]workflowStep
+	MaxSteps int
+}
+
+// workflowStep là một bước đã xóa kiểu để lưu trong Workflow
+type workflowStep struct {
+	inType reflect.Type
+	run    func(ctx context.Context, in any) (any, error)
+	next   func(out any, err error) string
+}
+
+// StepTrace ghi lại một bước đã chạy của Workflow
+type StepTrace struct {
+	Step     string
+	Started  time.Time
+	Duration time.Duration
+	Err      error
+	Next     string
+}
+
+// WorkflowResult là kết quả của Workflow: output của bước cuối và trace các bước
+type WorkflowResult struct {
+	Output any
+	Trace  []StepTrace
+}
+
+// WorkflowError xảy ra khi một bước lỗi mà transition không xử lý,
+// hoặc khi Workflow cấu hình sai (bước không tồn tại, sai kiểu input)
+type WorkflowError struct {
+	Step  string
+	Err   error
+	Trace []StepTrace
+}
+
+// Error trả về message kèm tên bước lỗi
+func (e *WorkflowError) Error() string {
+	return fmt.Sprintf("workflow step %q: %v", e.Step, e.Err)
+}
+
+// Unwrap trả về lỗi gốc
+func (e *WorkflowError) Unwrap() error {
+	return e.Err
+}
+
+// NewWorkflow tạo Workflow bắt đầu từ bước start
+func NewWorkflow(start string) *Workflow {
+	return &Workflow{
+		start:    start,
+		steps:    make(map[string]workflowStep),
+		MaxSteps: 1000,
+	}
+}
+
+// AddStep thêm bước name vào Workflow
+// next quyết định bước tiếp theo từ output và lỗi của bước (WorkflowEnd để kết thúc);
+// nếu bước lỗi và next trả về WorkflowEnd, Workflow bị reject với lỗi đó
+// next là nil tương đương kết thúc sau bước này
+func AddStep[In, Out any](w *Workflow, name string, fn func(ctx context.Context, in In) (Out, error), next func(out Out, err error) string) *Workflow {
+	w.steps[name] = workflowStep{
+		inType: reflect.TypeOf((*In)(nil)).Elem(),
+		run: func(ctx context.Context, in any) (any, error) {
+			typed, _ := in.(In)
+			return fn(ctx, typed)
+		},
+		next: func(out any, err error) string {
+			if next == nil {
+				return WorkflowEnd
+			}
+			typed, _ := out.(Out)
+			return next(typed, err)
+		},
+	}
+	return w
+}
+
+// Next tạo transition luôn chuyển tới bước step khi thành công
+// và kết thúc (reject) khi lỗi
+func Next[Out any](step string) func(out Out, err error) string {
+	return func(out Out, err error) string {
+		if err != nil {
+			return WorkflowEnd
+		}
+		return step
+	}
+}
+
+// Run chạy Workflow với input cho bước đầu tiên
+func (w *Workflow) Run(ctx context.Context, input any) *Promise[WorkflowResult] {
+	q := NewPromiseWithExecutor[WorkflowResult](func(resolve func(WorkflowResult), reject func(error)) {
+		var trace []StepTrace
+		fail := func(step string, err error) {
+			reject(&WorkflowError{Step: step, Err: err, Trace: trace})
+		}
+
+		current, value := w.start, input
+		for i := 0; ; i++ {
+			if i >= w.MaxSteps {
+				fail(current, fmt.Errorf("exceeded %d steps", w.MaxSteps))
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				fail(current, err)
+				return
+			}
+
+			step, ok := w.steps[current]
+			if !ok {
+				fail(current, fmt.Errorf("step is not defined"))
+				return
+			}
+			if !acceptsInput(step.inType, value) {
+				fail(current, fmt.Errorf("expects input %s, got %T", step.inType, value))
+				return
+			}
+
+			started := time.Now()
+			out, err := step.run(ctx, value)
+			next := step.next(out, err)
+			trace = append(trace, StepTrace{
+				Step:     current,
+				Started:  started,
+				Duration: time.Since(started),
+				Err:      err,
+				Next:     next,
+			})
+
+			if next == WorkflowEnd {
+				if err != nil {
+					fail(current, err)
+					return
+				}
+				resolve(WorkflowResult{Output: out, Trace: trace})
+				return
+			}
+
+			// Lỗi đã được transition xử lý: bước sau nhận output (zero value) của bước lỗi
+			current, value = next, out
+		}
+	})
+	q.node.setOp("Workflow")
+	return q
+}
+
+// acceptsInput kiểm tra value có dùng được làm input kiểu t hay không
+func acceptsInput(t reflect.Type, value any) bool {
+	if value == nil {
+		k := t.Kind()
+		return k == reflect.Interface || k == reflect.Pointer || k == reflect.Map ||
+			k == reflect.Slice || k == reflect.Func || k == reflect.Chan
+	}
+	return reflect.TypeOf(value).AssignableTo(t)
+}